@@ -4,7 +4,10 @@ go 1.14
 
 require (
 	github.com/go-sql-driver/mysql v1.5.0
+	github.com/golang/snappy v0.0.1
+	github.com/mattn/go-runewidth v0.0.7
 	github.com/olekukonko/tablewriter v0.0.4
+	github.com/prometheus/client_golang v1.7.1
 	github.com/stretchr/testify v1.6.1
 	github.com/zyguan/just v0.0.0-20200303164907-cac852552279
 	golang.org/x/sync v0.0.0-20200317015054-43a5402ce75a
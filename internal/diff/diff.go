@@ -0,0 +1,166 @@
+// Package diff computes line based differences and renders them in the unified diff format.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Op byte
+
+const (
+	Equal  Op = ' '
+	Delete Op = '-'
+	Insert Op = '+'
+)
+
+// Edit is a step of an edit script turning a into b. A is the index of the line in a (for Equal and Delete), and B is
+// the index of the line in b (for Equal and Insert).
+type Edit struct {
+	Op Op
+	A  int
+	B  int
+}
+
+// Edits returns a shortest edit script turning a sequence of n elements into a sequence of m elements by the Myers'
+// algorithm, eq reports whether the i-th element of the former equals to the j-th element of the latter.
+func Edits(n int, m int, eq func(i int, j int) bool) []Edit {
+	max := n + m
+	off := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int{}, v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && eq(x, y) {
+				x, y = x+1, y+1
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, off, n, m)
+			}
+		}
+	}
+	return nil
+}
+
+func backtrack(trace [][]int, off int, x int, y int) []Edit {
+	var edits []Edit
+	for d := len(trace) - 1; d > 0; d-- {
+		v, k := trace[d], x-y
+		var pk int
+		if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+			pk = k + 1
+		} else {
+			pk = k - 1
+		}
+		px := v[off+pk]
+		py := px - pk
+		for x > px && y > py {
+			x, y = x-1, y-1
+			edits = append(edits, Edit{Equal, x, y})
+		}
+		if x == px {
+			edits = append(edits, Edit{Insert, x, py})
+		} else {
+			edits = append(edits, Edit{Delete, px, y})
+		}
+		x, y = px, py
+	}
+	for x > 0 && y > 0 {
+		x, y = x-1, y-1
+		edits = append(edits, Edit{Equal, x, y})
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// Unified renders edits turning a into b in the unified diff format with the given number of context lines, it
+// returns an empty string if there is no difference.
+func Unified(a []string, b []string, edits []Edit, from string, to string, context int) string {
+	// hunks are ranges of edits, changes closer than 2*context lines share a hunk
+	var hunks [][2]int
+	for i, e := range edits {
+		if e.Op == Equal {
+			continue
+		}
+		start, stop := i-context, i+1+context
+		if start < 0 {
+			start = 0
+		}
+		if stop > len(edits) {
+			stop = len(edits)
+		}
+		if n := len(hunks); n > 0 && start <= hunks[n-1][1] {
+			hunks[n-1][1] = stop
+		} else {
+			hunks = append(hunks, [2]int{start, stop})
+		}
+	}
+	if len(hunks) == 0 {
+		return ""
+	}
+	out := new(strings.Builder)
+	fmt.Fprintf(out, "--- %s\n+++ %s\n", from, to)
+	for _, r := range hunks {
+		h := edits[r[0]:r[1]]
+		sa, la, sb, lb := -1, 0, -1, 0
+		for _, e := range h {
+			if e.Op != Insert {
+				if sa < 0 {
+					sa = e.A
+				}
+				la++
+			}
+			if e.Op != Delete {
+				if sb < 0 {
+					sb = e.B
+				}
+				lb++
+			}
+		}
+		if sa < 0 {
+			sa = h[0].A
+		}
+		if sb < 0 {
+			sb = h[0].B
+		}
+		fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(sa, la), hunkRange(sb, lb))
+		for _, e := range h {
+			switch e.Op {
+			case Equal:
+				out.WriteString(" " + a[e.A] + "\n")
+			case Delete:
+				out.WriteString("-" + a[e.A] + "\n")
+			case Insert:
+				out.WriteString("+" + b[e.B] + "\n")
+			}
+		}
+	}
+	return out.String()
+}
+
+// Lines is a shortcut of Unified for comparing lines by their text.
+func Lines(a []string, b []string, from string, to string, context int) string {
+	return Unified(a, b, Edits(len(a), len(b), func(i int, j int) bool { return a[i] == b[j] }), from, to, context)
+}
+
+func hunkRange(start int, n int) string {
+	if n == 0 {
+		// an empty range refers to the line before it
+		return fmt.Sprintf("%d,0", start)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
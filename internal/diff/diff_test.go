@@ -0,0 +1,57 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLines(t *testing.T) {
+	split := func(s string) []string {
+		if len(s) == 0 {
+			return nil
+		}
+		return strings.Split(s, ",")
+	}
+	for _, tt := range []struct {
+		a, b   string
+		expect string
+	}{
+		{"", "", ""},
+		{"a,b,c", "a,b,c", ""},
+		{"", "a", "--- a\n+++ b\n@@ -0,0 +1 @@\n+a\n"},
+		{"a", "", "--- a\n+++ b\n@@ -1 +0,0 @@\n-a\n"},
+		{"a,b,c", "a,x,c", "--- a\n+++ b\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n"},
+		{"1,2,3,4,5,6,7,8,9", "1,2,3,4,x,5,6,7,8", "--- a\n+++ b\n@@ -2,8 +2,8 @@\n 2\n 3\n 4\n+x\n 5\n 6\n 7\n 8\n-9\n"},
+		{"1,2,3,4,5,6,7,8,9,10,11,12", "1,x,3,4,5,6,7,8,9,10,y,12", "--- a\n+++ b\n@@ -1,5 +1,5 @@\n 1\n-2\n+x\n 3\n 4\n 5\n@@ -8,5 +8,5 @@\n 8\n 9\n 10\n-11\n+y\n 12\n"},
+		{"1,2,3,4,5,6,7,8,9", "0,1,2,3,4,5,6,7,8,9,10", "--- a\n+++ b\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -7,3 +8,4 @@\n 7\n 8\n 9\n+10\n"},
+	} {
+		require.Equal(t, tt.expect, Lines(split(tt.a), split(tt.b), "a", "b", 3), tt.a+" vs "+tt.b)
+	}
+	require.Equal(t, "--- a\n+++ b\n@@ -1 +0,0 @@\n-a\n@@ -3 +2,2 @@\n-c\n+a\n+d\n", Lines(split("a,b,c"), split("b,a,d"), "a", "b", 0))
+	require.Equal(t, "--- a\n+++ b\n@@ -0,0 +1 @@\n+0\n@@ -9,0 +11 @@\n+10\n", Lines(split("1,2,3,4,5,6,7,8,9"), split("0,1,2,3,4,5,6,7,8,9,10"), "a", "b", 0))
+}
+
+func TestEdits(t *testing.T) {
+	a, b := strings.Split("abcabba", ""), strings.Split("cbabac", "")
+	edits := Edits(len(a), len(b), func(i int, j int) bool { return a[i] == b[j] })
+	changes := 0
+	var ra, rb []string
+	for _, e := range edits {
+		switch e.Op {
+		case Equal:
+			require.Equal(t, a[e.A], b[e.B])
+			ra, rb = append(ra, a[e.A]), append(rb, b[e.B])
+		case Delete:
+			ra = append(ra, a[e.A])
+			changes++
+		case Insert:
+			rb = append(rb, b[e.B])
+			changes++
+		}
+	}
+	require.Equal(t, 5, changes)
+	require.Equal(t, a, ra)
+	require.Equal(t, b, rb)
+}
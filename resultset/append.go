@@ -0,0 +1,115 @@
+package resultset
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type AppendOptions struct {
+	// IgnoreNames only requires columns to have the same types.
+	IgnoreNames bool
+	// CompatibleTypes accepts columns of different types in the same family (numeric, temporal, JSON or string),
+	// the types of rs are kept.
+	CompatibleTypes bool
+}
+
+// Append appends copies of rows of other to rs, after checking that both have the same column count, names and types.
+func (rs *ResultSet) Append(other *ResultSet, opts ...AppendOptions) error {
+	var o AppendOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if rs.IsExecResult() || other.IsExecResult() {
+		return errors.New("cannot append exec results")
+	}
+	if len(rs.cols) != len(other.cols) {
+		return fmt.Errorf("column count mismatch: %d vs %d", len(rs.cols), len(other.cols))
+	}
+	for j, c := range rs.cols {
+		d := other.cols[j]
+		if !o.IgnoreNames && c.Name != d.Name {
+			return fmt.Errorf("column #%d name mismatch: %s vs %s", j, c.Name, d.Name)
+		}
+		if !strings.EqualFold(c.Type, d.Type) && !(o.CompatibleTypes && typeFamily(c.Type) == typeFamily(d.Type)) {
+			return fmt.Errorf("column %s type mismatch: %s vs %s", c.Name, c.Type, d.Type)
+		}
+	}
+	n, src := len(rs.data), other.copyRange(0, len(other.data))
+	for i, row := range src.data {
+		rs.data = append(rs.data, row)
+		for j := range src.cols {
+			if src.isNil(i, j) {
+				rs.markNil(n+i, j)
+			}
+		}
+	}
+	rs.truncated = rs.truncated || other.truncated
+	return nil
+}
+
+// AppendRow returns a new result set with a row of values appended, rs is left untouched. Values are converted like
+// expected values of AssertData: nil is NULL, strings, byte slices, Bin and fmt.Stringer are taken as they are, and
+// anything else is formatted by fmt.
+func (rs *ResultSet) AppendRow(values ...interface{}) (*ResultSet, error) {
+	if rs.IsExecResult() {
+		return nil, errors.New("cannot append rows to exec results")
+	}
+	if len(values) != len(rs.cols) {
+		return nil, fmt.Errorf("column count mismatch: %d vs %d", len(rs.cols), len(values))
+	}
+	out := rs.copyRange(0, len(rs.data))
+	i := len(out.data)
+	row := make([][]byte, len(values))
+	for j, v := range values {
+		switch x := v.(type) {
+		case nil:
+			out.markNil(i, j)
+		case string:
+			row[j] = []byte(x)
+		case []byte:
+			row[j] = append([]byte{}, x...)
+		case Bin:
+			row[j] = x.Bytes()
+		case fmt.Stringer:
+			row[j] = []byte(x.String())
+		default:
+			row[j] = []byte(fmt.Sprintf("%v", x))
+		}
+	}
+	out.data = append(out.data, row)
+	return out, nil
+}
+
+// Concat stacks result sets with compatible schemas into a new one, inputs are left untouched.
+func Concat(rss ...*ResultSet) (*ResultSet, error) {
+	return ConcatWithOptions(AppendOptions{}, rss...)
+}
+
+// ConcatWithOptions is like Concat but checks schemas as Append does with opts, e.g. IgnoreNames and CompatibleTypes
+// only require columns to be positionally compatible.
+func ConcatWithOptions(opts AppendOptions, rss ...*ResultSet) (*ResultSet, error) {
+	if len(rss) == 0 {
+		return nil, errors.New("nothing to concat")
+	}
+	out := rss[0].copyRange(0, len(rss[0].data))
+	for _, rs := range rss[1:] {
+		if err := out.Append(rs, opts); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func typeFamily(t string) string {
+	switch {
+	case isIntegerType(t) || isFloatType(t) || isDecimalType(t):
+		return "numeric"
+	case isTimeType(t):
+		return "temporal"
+	case isJSONType(t):
+		return "json"
+	default:
+		return "string"
+	}
+}
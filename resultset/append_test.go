@@ -0,0 +1,104 @@
+package resultset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendConcat(t *testing.T) {
+	schema := `"columns":[{"name":"id","type":"INT"},{"name":"v","type":"VARCHAR"}]`
+	parse := func(rows string) *ResultSet {
+		rs, err := FromJSON([]byte(`{` + schema + `,"rows":` + rows + `}`))
+		require.NoError(t, err)
+		return rs
+	}
+	full := parse(`[[1,"a"],[2,null],[3,""],[4,null],[5,"e"]]`)
+	p1, p2, p3 := parse(`[[1,"a"],[2,null]]`), parse(`[[3,""],[4,null]]`), parse(`[[5,"e"]]`)
+
+	merged, err := Concat(p1, p2, p3)
+	require.NoError(t, err)
+	require.Equal(t, 2, p1.NRows())
+	require.Equal(t, full.DataDigest(DigestOptions{}), merged.DataDigest(DigestOptions{}))
+	raw, err := merged.Encode()
+	require.NoError(t, err)
+	var decoded ResultSet
+	require.NoError(t, decoded.Decode(raw))
+	require.Equal(t, full.DataDigest(DigestOptions{}), decoded.DataDigest(DigestOptions{}))
+
+	merged.data[0][1][0] = 'x'
+	v, _ := p1.RawValue(0, 1)
+	require.Equal(t, "a", string(v))
+
+	require.NoError(t, p1.Append(p2))
+	require.Equal(t, 4, p1.NRows())
+	require.True(t, p1.isNil(3, 1))
+	p1.data[2][0][0] = '9'
+	v, _ = p2.RawValue(0, 0)
+	require.Equal(t, "3", string(v))
+
+	renamed := p3.RenameColumn("v", "w")
+	require.EqualError(t, p1.Append(renamed), "column #1 name mismatch: v vs w")
+	require.NoError(t, p1.Append(renamed, AppendOptions{IgnoreNames: true}))
+
+	other, err := FromJSON([]byte(`{"columns":[{"name":"id","type":"INT"},{"name":"v","type":"INT"}],"rows":[]}`))
+	require.NoError(t, err)
+	require.EqualError(t, p1.Append(other), "column v type mismatch: VARCHAR vs INT")
+	narrow, err := full.Project("id")
+	require.NoError(t, err)
+	require.EqualError(t, p1.Append(narrow), "column count mismatch: 2 vs 1")
+	exec := &ResultSet{exec: ExecResult{RowsAffected: 1}}
+	require.EqualError(t, p1.Append(exec), "cannot append exec results")
+	require.EqualError(t, exec.Append(p1), "cannot append exec results")
+	_, err = Concat()
+	require.Error(t, err)
+}
+
+func TestConcatWithOptions(t *testing.T) {
+	parse := func(js string) *ResultSet {
+		rs, err := FromJSON([]byte(js))
+		require.NoError(t, err)
+		return rs
+	}
+	a := parse(`{"columns":[{"name":"id","type":"INT"},{"name":"v","type":"VARCHAR"}],"rows":[[1,"a"]]}`)
+	b := parse(`{"columns":[{"name":"x","type":"BIGINT"},{"name":"y","type":"TEXT"}],"rows":[[2,null]]}`)
+	c := parse(`{"columns":[{"name":"id","type":"DECIMAL"},{"name":"v","type":"CHAR"}],"rows":[[3,"c"],[4,"d"]]}`)
+
+	opts := AppendOptions{IgnoreNames: true, CompatibleTypes: true}
+	_, err := Concat(a, b)
+	require.EqualError(t, err, "column #0 name mismatch: id vs x")
+	out, err := ConcatWithOptions(opts, a, b, c)
+	require.NoError(t, err)
+	require.Equal(t, 1, a.NRows())
+	require.Equal(t, 4, out.NRows())
+	require.Equal(t, "INT", out.ColumnDef(0).Type)
+	require.Equal(t, []interface{}{"1", "2", "3", "4"}, column(out, 0))
+	require.True(t, out.isNil(1, 1))
+
+	same, err := ConcatWithOptions(opts, a)
+	require.NoError(t, err)
+	require.Equal(t, a.DataDigest(DigestOptions{}), same.DataDigest(DigestOptions{}))
+
+	d := parse(`{"columns":[{"name":"id","type":"DATETIME"},{"name":"v","type":"VARCHAR"}],"rows":[]}`)
+	_, err = ConcatWithOptions(opts, a, b, d)
+	require.EqualError(t, err, "column id type mismatch: INT vs DATETIME")
+	e := parse(`{"columns":[{"name":"id","type":"INT"}],"rows":[]}`)
+	_, err = ConcatWithOptions(opts, a, e)
+	require.EqualError(t, err, "column count mismatch: 2 vs 1")
+}
+
+func TestAppendRow(t *testing.T) {
+	rs := New([]ColumnDef{{Name: "id", Type: "INT"}, {Name: "v", Type: "VARCHAR"}, {Name: "b", Type: "BIT"}})
+	r1, err := rs.AppendRow(1, "a", BinBool(true))
+	require.NoError(t, err)
+	r2, err := r1.AppendRow(int64(2), nil, []byte{0})
+	require.NoError(t, err)
+	require.Equal(t, 0, rs.NRows())
+	require.Equal(t, 1, r1.NRows())
+	require.NoError(t, r2.AssertData(Rows{{1, "a", BinBool(true)}, {2, nil, []byte{0}}}))
+
+	_, err = r2.AppendRow(3, "c")
+	require.EqualError(t, err, "column count mismatch: 3 vs 2")
+	_, err = (&ResultSet{}).AppendRow()
+	require.Error(t, err)
+}
@@ -0,0 +1,138 @@
+package resultset
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Builder constructs result sets from Go values, typically as inline expectations in tests.
+type Builder struct {
+	rs  *ResultSet
+	err error
+}
+
+// NewBuilder returns a builder of a result set with the given columns.
+func NewBuilder(cols ...ColumnDef) *Builder {
+	return &Builder{rs: New(cols)}
+}
+
+// AddRow appends a row of values, one per column. Values are stored the way ReadFromRows reads them from a MySQL
+// server: nil is NULL, integers and bools are formatted in decimal, floats in the shortest form, time.Time as per the
+// column type (DATE, TIME, YEAR or DATETIME with the column scale as fractional digits), strings, byte slices and Bin
+// are taken as they are, and anything else is formatted by fmt. Numbers in DECIMAL columns are padded to the column
+// scale. The first error is kept and reported by Build.
+func (b *Builder) AddRow(values ...interface{}) *Builder {
+	if b.err != nil {
+		return b
+	}
+	i := len(b.rs.data)
+	if len(values) != len(b.rs.cols) {
+		b.err = fmt.Errorf("row %d: column count mismatch: %d vs %d", i, len(b.rs.cols), len(values))
+		return b
+	}
+	row := make([][]byte, len(values))
+	for j, v := range values {
+		if v == nil {
+			b.rs.markNil(i, j)
+			continue
+		}
+		row[j] = builderValue(b.rs.cols[j], v)
+	}
+	b.rs.data = append(b.rs.data, row)
+	return b
+}
+
+// Build returns the result set built so far, or the first error of AddRow.
+func (b *Builder) Build() (*ResultSet, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.rs.clone(), nil
+}
+
+func builderValue(def ColumnDef, v interface{}) []byte {
+	switch x := v.(type) {
+	case string:
+		return []byte(x)
+	case []byte:
+		return append([]byte{}, x...)
+	case Bin:
+		return x.Bytes()
+	case bool:
+		if x {
+			return []byte("1")
+		}
+		return []byte("0")
+	case int:
+		return builderInt(def, strconv.AppendInt(nil, int64(x), 10))
+	case int8:
+		return builderInt(def, strconv.AppendInt(nil, int64(x), 10))
+	case int16:
+		return builderInt(def, strconv.AppendInt(nil, int64(x), 10))
+	case int32:
+		return builderInt(def, strconv.AppendInt(nil, int64(x), 10))
+	case int64:
+		return builderInt(def, strconv.AppendInt(nil, x, 10))
+	case uint:
+		return builderInt(def, strconv.AppendUint(nil, uint64(x), 10))
+	case uint8:
+		return builderInt(def, strconv.AppendUint(nil, uint64(x), 10))
+	case uint16:
+		return builderInt(def, strconv.AppendUint(nil, uint64(x), 10))
+	case uint32:
+		return builderInt(def, strconv.AppendUint(nil, uint64(x), 10))
+	case uint64:
+		return builderInt(def, strconv.AppendUint(nil, x, 10))
+	case float32:
+		return builderFloat(def, float64(x), 32)
+	case float64:
+		return builderFloat(def, x, 64)
+	case time.Time:
+		return []byte(builderTime(def, x))
+	case fmt.Stringer:
+		return []byte(x.String())
+	default:
+		return []byte(fmt.Sprintf("%v", x))
+	}
+}
+
+func builderInt(def ColumnDef, digits []byte) []byte {
+	if isDecimalType(def.Type) && def.HasPrecisionScale && def.Scale > 0 {
+		digits = append(append(digits, '.'), strings.Repeat("0", int(def.Scale))...)
+	}
+	return digits
+}
+
+func builderFloat(def ColumnDef, x float64, bits int) []byte {
+	if isDecimalType(def.Type) && def.HasPrecisionScale {
+		return strconv.AppendFloat(nil, x, 'f', int(def.Scale), bits)
+	}
+	return strconv.AppendFloat(nil, x, 'f', -1, bits)
+}
+
+func builderTime(def ColumnDef, t time.Time) string {
+	switch strings.ToUpper(def.Type) {
+	case "DATE":
+		return t.Format("2006-01-02")
+	case "TIME":
+		return t.Format("15:04:05") + fraction(t, def)
+	case "YEAR":
+		return t.Format("2006")
+	default:
+		return t.Format("2006-01-02 15:04:05") + fraction(t, def)
+	}
+}
+
+// fraction renders fractional seconds of t with as many digits as the scale of def, it's empty for a zero scale.
+func fraction(t time.Time, def ColumnDef) string {
+	if !def.HasPrecisionScale || def.Scale <= 0 {
+		return ""
+	}
+	n := int(def.Scale)
+	if n > 9 {
+		n = 9
+	}
+	return "." + fmt.Sprintf("%09d", t.Nanosecond())[:n]
+}
@@ -0,0 +1,57 @@
+package resultset
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilder(t *testing.T) {
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	rs, err := NewBuilder(
+		ColumnDef{Name: "i", Type: "BIGINT"},
+		ColumnDef{Name: "f", Type: "DOUBLE"},
+		ColumnDef{Name: "d", Type: "DECIMAL", Precision: 10, Scale: 2, HasPrecisionScale: true},
+		ColumnDef{Name: "s", Type: "VARCHAR"},
+		ColumnDef{Name: "b", Type: "VARBINARY"},
+		ColumnDef{Name: "dt", Type: "DATETIME", Scale: 3, HasPrecisionScale: true},
+		ColumnDef{Name: "day", Type: "DATE"},
+	).
+		AddRow(int64(-1), 1.5, 2.5, "a", []byte{0, 1}, ts, ts).
+		AddRow(uint8(2), float32(0.25), 3, "", nil, ts, ts).
+		AddRow(true, nil, nil, nil, BinBool(false), nil, nil).
+		Build()
+	require.NoError(t, err)
+	require.NoError(t, rs.AssertData(Rows{
+		{"-1", "1.5", "2.50", "a", []byte{0, 1}, "2020-01-02 03:04:05.123", "2020-01-02"},
+		{"2", "0.25", "3.00", "", nil, "2020-01-02 03:04:05.123", "2020-01-02"},
+		{"1", nil, nil, nil, []byte{0}, nil, nil},
+	}))
+
+	_, err = NewBuilder(ColumnDef{Name: "i", Type: "INT"}).AddRow(1, 2).AddRow(3).Build()
+	require.EqualError(t, err, "row 0: column count mismatch: 1 vs 2")
+}
+
+func TestBuilderWithMySQLDataSource(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	rows, err := db.Query(`select 1 as i, -2.5e0 as f, cast(3.5 as decimal(10,2)) as d, 'x' as s, null as n,
+		cast('2020-01-02 03:04:05.678' as datetime(3)) as dt, cast('2020-01-02' as date) as day`)
+	require.NoError(t, err)
+	actual, err := ReadFromRows(rows)
+	require.NoError(t, err)
+
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 678000000, time.UTC)
+	expect, err := NewBuilder(
+		ColumnDef{Name: "i", Type: "BIGINT"},
+		ColumnDef{Name: "f", Type: "DOUBLE"},
+		ColumnDef{Name: "d", Type: "DECIMAL", Precision: 10, Scale: 2, HasPrecisionScale: true},
+		ColumnDef{Name: "s", Type: "VARCHAR"},
+		ColumnDef{Name: "n", Type: "BINARY"},
+		ColumnDef{Name: "dt", Type: "DATETIME", Scale: 3, HasPrecisionScale: true},
+		ColumnDef{Name: "day", Type: "DATE"},
+	).AddRow(1, -2.5, 3.5, "x", nil, ts, ts).Build()
+	require.NoError(t, err)
+	require.Equal(t, expect.DataDigest(DigestOptions{}), actual.DataDigest(DigestOptions{}))
+}
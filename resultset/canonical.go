@@ -0,0 +1,117 @@
+package resultset
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func jsonSemanticMapper(next func(i int, j int, raw []byte, def ColumnDef) []byte) func(i int, j int, raw []byte, def ColumnDef) []byte {
+	return func(i int, j int, raw []byte, def ColumnDef) []byte {
+		if raw != nil && isJSONType(def.Type) {
+			if canonical, ok := canonicalJSON(raw); ok {
+				raw = canonical
+			}
+		}
+		if next != nil {
+			raw = next(i, j, raw, def)
+		}
+		return raw
+	}
+}
+
+// canonicalJSON re-encodes a JSON document with sorted object keys, no insignificant whitespace and numbers in their
+// shortest form, so that logically equal documents have identical encodings.
+func canonicalJSON(raw []byte) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return nil, false
+	}
+	buf := new(bytes.Buffer)
+	writeCanonicalJSON(buf, v)
+	return buf.Bytes(), true
+}
+
+func writeCanonicalJSON(buf *bytes.Buffer, v interface{}) {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalJSON(buf, k)
+			buf.WriteByte(':')
+			writeCanonicalJSON(buf, x[k])
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, e := range x {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalJSON(buf, e)
+		}
+		buf.WriteByte(']')
+	case json.Number:
+		buf.WriteString(canonicalNumber(string(x)))
+	case string:
+		writeJSONString(buf, x)
+	default:
+		out, _ := json.Marshal(x)
+		buf.Write(out)
+	}
+}
+
+// canonicalNumber renders an integral number by its exact digits however it's written (e.g. 1e2, 100.0 and 100 are
+// all 100), other numbers by the shortest form of their float64 values.
+func canonicalNumber(s string) string {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return s
+	}
+	if n, ok := integralDigits(s); ok {
+		return n
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// integralDigits returns the decimal digits of a JSON number if it's an integer, it's meant to be called on numbers
+// within the float64 range, so that the digits are bounded.
+func integralDigits(s string) (string, bool) {
+	neg := strings.HasPrefix(s, "-")
+	s, exp := strings.TrimPrefix(s, "-"), 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return "", false
+		}
+		s, exp = s[:i], e
+	}
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		s, exp = s[:i]+s[i+1:], exp-(len(s)-i-1)
+	}
+	digits := strings.TrimLeft(s, "0")
+	if len(digits) == 0 {
+		return "0", true
+	}
+	trimmed := strings.TrimRight(digits, "0")
+	exp += len(digits) - len(trimmed)
+	if exp < 0 {
+		return "", false
+	}
+	if neg {
+		trimmed = "-" + trimmed
+	}
+	return trimmed + strings.Repeat("0", exp), true
+}
@@ -0,0 +1,41 @@
+package resultset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestJSONSemantic(t *testing.T) {
+	newRS := func(doc string, text string) *ResultSet {
+		rs := New([]ColumnDef{{Name: "j", Type: "JSON"}, {Name: "t", Type: "TEXT"}})
+		rs.data = [][][]byte{{[]byte(doc), []byte(text)}}
+		return rs
+	}
+	rs1 := newRS(`{"a": 1, "b": [1.0, {"y": "z", "x": null}], "c": 1.50}`, `{"a": 1}`)
+	rs2 := newRS(`{"c":1.5,"b":[1,{"x":null,"y":"z"}],"a":1}`, `{"a": 1}`)
+	require.NotEqual(t, rs1.DataDigest(DigestOptions{}), rs2.DataDigest(DigestOptions{}))
+	require.Equal(t, rs1.DataDigest(DigestOptions{JSONSemantic: true}), rs2.DataDigest(DigestOptions{JSONSemantic: true}))
+	require.Equal(t, rs1.DataDigest(DigestOptions{JSONSemantic: true, Sort: true}), rs2.DataDigest(DigestOptions{JSONSemantic: true, Sort: true}))
+
+	// non-JSON columns are untouched
+	rs3 := newRS(`{"a": 1}`, `{"a":1}`)
+	rs4 := newRS(`{"a":1}`, `{"a": 1}`)
+	require.NotEqual(t, rs3.DataDigest(DigestOptions{JSONSemantic: true}), rs4.DataDigest(DigestOptions{JSONSemantic: true}))
+
+	// different values remain different
+	rs5 := newRS(`{"a": 2}`, `{"a": 1}`)
+	require.NotEqual(t, rs3.DataDigest(DigestOptions{JSONSemantic: true}), rs5.DataDigest(DigestOptions{JSONSemantic: true}))
+
+	for in, out := range map[string]string{
+		`[1e2, -0, 12345678901234567890, 0.1, "a"]`:                          `[100,0,12345678901234567890,0.1,"a"]`,
+		`[1e20, 100000000000000000000, 1E+2, 100.0, -2.50e1, 0.0e-5, 15e-1]`: `[100000000000000000000,100000000000000000000,100,100,-25,0,1.5]`,
+		` true `: `true`,
+	} {
+		canonical, ok := canonicalJSON([]byte(in))
+		require.True(t, ok)
+		require.Equal(t, out, string(canonical))
+	}
+	_, ok := canonicalJSON([]byte(`{"a":`))
+	require.False(t, ok)
+}
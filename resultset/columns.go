@@ -0,0 +1,150 @@
+package resultset
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ColumnInfo describes a column as far as database/sql reports it. Unsigned is known for integer columns read with a
+// sized integer scan type only (see HasUnsigned), which go-sql-driver/mysql uses for NOT NULL columns. The original
+// column name and the charset are not exposed by database/sql, so they are not available.
+type ColumnInfo struct {
+	Name        string
+	Type        string
+	TypeCode    byte
+	HasTypeCode bool
+	Nullable    bool
+	HasNullable bool
+	Unsigned    bool
+	HasUnsigned bool
+}
+
+func (c ColumnDef) Info() ColumnInfo {
+	info := ColumnInfo{
+		Name:        c.Name,
+		Type:        c.Type,
+		Nullable:    c.Nullable,
+		HasNullable: c.HasNullable,
+		Unsigned:    c.Unsigned,
+		HasUnsigned: c.HasUnsigned,
+	}
+	info.TypeCode, info.HasTypeCode = TypeCode(c.Type)
+	return info
+}
+
+func (rs *ResultSet) Columns() []ColumnInfo {
+	cols := make([]ColumnInfo, len(rs.cols))
+	for j, c := range rs.cols {
+		cols[j] = c.Info()
+	}
+	return cols
+}
+
+// ColumnIndex returns the position of the first column whose name matches case-insensitively.
+func (rs *ResultSet) ColumnIndex(name string) (int, bool) {
+	for j, c := range rs.cols {
+		if strings.EqualFold(c.Name, name) {
+			return j, true
+		}
+	}
+	return -1, false
+}
+
+// RawValueByName is like RawValue but locates the column by name. It reports false if either the row or the column
+// does not exist and returns a nil slice for NULL. When several columns share a name, the first one wins; use
+// Columns and RawValue to reach the others.
+func (rs *ResultSet) RawValueByName(i int, name string) ([]byte, bool) {
+	j, ok := rs.ColumnIndex(name)
+	if !ok {
+		return nil, false
+	}
+	return rs.RawValue(i, j)
+}
+
+// ValueByName returns the cell as a string, whether it is NULL, and whether the cell exists at all.
+func (rs *ResultSet) ValueByName(i int, name string) (string, bool, bool) {
+	raw, ok := rs.RawValueByName(i, name)
+	if !ok {
+		return "", false, false
+	}
+	if raw == nil {
+		return "", true, true
+	}
+	return string(raw), false, true
+}
+
+// Project returns a new result set holding copies of the named columns in the given order.
+func (rs *ResultSet) Project(names ...string) (*ResultSet, error) {
+	if rs.IsExecResult() {
+		return nil, errors.New("cannot project columns of an exec result")
+	}
+	idx := make([]int, len(names))
+	for k, name := range names {
+		j, ok := rs.ColumnIndex(name)
+		if !ok {
+			return nil, fmt.Errorf("cannot project unknown column %s", name)
+		}
+		idx[k] = j
+	}
+	return rs.ProjectIndex(idx...)
+}
+
+// ProjectIndex is like Project but picks columns by their positions.
+func (rs *ResultSet) ProjectIndex(idx ...int) (*ResultSet, error) {
+	if rs.IsExecResult() {
+		return nil, errors.New("cannot project columns of an exec result")
+	}
+	cols := make([]ColumnDef, len(idx))
+	for k, j := range idx {
+		if j < 0 || j >= len(rs.cols) {
+			return nil, fmt.Errorf("cannot project column #%d: out of range", j)
+		}
+		cols[k] = rs.cols[j]
+	}
+	out := New(cols)
+	out.truncated = rs.truncated
+	for i, row := range rs.data {
+		projected := make([][]byte, len(idx))
+		for k, j := range idx {
+			if rs.isNil(i, j) {
+				out.markNil(i, k)
+			} else if row[j] != nil {
+				projected[k] = append([]byte{}, row[j]...)
+			}
+		}
+		out.data = append(out.data, projected)
+	}
+	return out, nil
+}
+
+// Transpose returns a new result set with rows and columns swapped: the first column named "column" holds names of
+// the original columns, and the i-th row of the original result becomes the column "row_i". Declared types of the
+// values are lost, all columns are typed VARCHAR.
+func (rs *ResultSet) Transpose() (*ResultSet, error) {
+	if rs.IsExecResult() {
+		return nil, errors.New("cannot transpose an exec result")
+	}
+	cols := make([]ColumnDef, len(rs.data)+1)
+	cols[0] = ColumnDef{Name: "column", Type: "VARCHAR"}
+	for i := range rs.data {
+		cols[i+1] = ColumnDef{Name: "row_" + strconv.Itoa(i+1), Type: "VARCHAR"}
+	}
+	out := New(cols)
+	out.truncated = rs.truncated
+	out.data = make([][][]byte, len(rs.cols))
+	for j, c := range rs.cols {
+		row := make([][]byte, len(cols))
+		row[0] = []byte(c.Name)
+		for i := range rs.data {
+			if rs.isNil(i, j) {
+				out.markNil(j, i+1)
+			} else {
+				row[i+1] = append([]byte{}, rs.data[i][j]...)
+			}
+		}
+		out.data[j] = row
+	}
+	return out, nil
+}
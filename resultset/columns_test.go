@@ -0,0 +1,129 @@
+package resultset
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumns(t *testing.T) {
+	rs := &ResultSet{cols: []ColumnDef{
+		{Name: "id", Type: "BIGINT", Nullable: false, HasNullable: true, Unsigned: true, HasUnsigned: true},
+		{Name: "Name", Type: "VARCHAR", Nullable: true, HasNullable: true},
+		{Name: "raw", Type: "BLOB"},
+		{Name: "name", Type: "WHATEVER"},
+	}}
+	cols := rs.Columns()
+	require.Equal(t, ColumnInfo{Name: "id", Type: "BIGINT", TypeCode: TypeLongLong, HasTypeCode: true, HasNullable: true,
+		Unsigned: true, HasUnsigned: true}, cols[0])
+	require.Equal(t, TypeVarString, cols[1].TypeCode)
+	require.Equal(t, TypeBlob, cols[2].TypeCode)
+	require.False(t, cols[3].HasTypeCode)
+
+	j, ok := rs.ColumnIndex("NAME")
+	require.True(t, ok)
+	require.Equal(t, 1, j)
+	_, ok = rs.ColumnIndex("missing")
+	require.False(t, ok)
+
+	raw, err := rs.Encode()
+	require.NoError(t, err)
+	var rs2 ResultSet
+	require.NoError(t, rs2.Decode(raw))
+	require.Equal(t, cols, rs2.Columns())
+}
+
+func TestValueByName(t *testing.T) {
+	rs := New([]ColumnDef{{Name: "id"}, {Name: "v"}, {Name: "V"}})
+	rs.data = append(rs.data, [][]byte{[]byte("1"), nil, []byte("b")})
+	rs.markNil(0, 1)
+
+	raw, ok := rs.RawValueByName(0, "ID")
+	require.True(t, ok)
+	require.Equal(t, []byte("1"), raw)
+	raw, ok = rs.RawValueByName(0, "v")
+	require.True(t, ok)
+	require.Nil(t, raw)
+	_, ok = rs.RawValueByName(0, "x")
+	require.False(t, ok)
+	_, ok = rs.RawValueByName(1, "id")
+	require.False(t, ok)
+
+	v, null, ok := rs.ValueByName(0, "id")
+	require.Equal(t, "1", v)
+	require.False(t, null)
+	require.True(t, ok)
+	_, null, ok = rs.ValueByName(0, "V")
+	require.True(t, null)
+	require.True(t, ok)
+	_, null, ok = rs.ValueByName(0, "x")
+	require.False(t, null)
+	require.False(t, ok)
+
+	raw, err := rs.Encode()
+	require.NoError(t, err)
+	var rs2 ResultSet
+	require.NoError(t, rs2.Decode(raw))
+	_, null, ok = rs2.ValueByName(0, "v")
+	require.True(t, null && ok)
+}
+
+func TestProject(t *testing.T) {
+	rs, err := FromJSON([]byte(`{"columns":[{"name":"id","type":"INT"},{"name":"ts","type":"TIMESTAMP"},{"name":"v","type":"VARCHAR"}],
+		"rows":[[2,"2020-01-01 00:00:00","b"],[1,"2020-01-01 00:00:01",null]]}`))
+	require.NoError(t, err)
+
+	p, err := rs.Project("V", "id")
+	require.NoError(t, err)
+	require.Equal(t, []ColumnInfo{rs.Columns()[2], rs.Columns()[0]}, p.Columns())
+	expect, err := FromJSON([]byte(`{"columns":[{"name":"v","type":"VARCHAR"},{"name":"id","type":"INT"}],"rows":[["b",2],[null,1]]}`))
+	require.NoError(t, err)
+	require.Equal(t, expect.DataDigest(DigestOptions{}), p.DataDigest(DigestOptions{}))
+
+	raw, err := p.Encode()
+	require.NoError(t, err)
+	var decoded ResultSet
+	require.NoError(t, decoded.Decode(raw))
+	require.Equal(t, expect.DataDigest(DigestOptions{}), decoded.DataDigest(DigestOptions{}))
+
+	p.data[0][0][0] = 'x'
+	v, _ := rs.RawValue(0, 2)
+	require.Equal(t, "b", string(v))
+
+	require.NoError(t, p.SortBy(SortKey{Column: "id"}))
+	require.Equal(t, []interface{}{nil, "x"}, column(p, 0))
+
+	p, err = rs.ProjectIndex(1)
+	require.NoError(t, err)
+	require.Equal(t, 1, p.NCols())
+
+	_, err = rs.Project("nope")
+	require.EqualError(t, err, "cannot project unknown column nope")
+	_, err = rs.ProjectIndex(3)
+	require.Error(t, err)
+	_, err = (&ResultSet{}).Project("id")
+	require.EqualError(t, err, "cannot project columns of an exec result")
+}
+
+func TestTranspose(t *testing.T) {
+	rs, err := FromJSON([]byte(`{"columns":[{"name":"id","type":"INT"},{"name":"v","type":"VARCHAR"}],"rows":[[1,"a"],[2,null]]}`))
+	require.NoError(t, err)
+	tr, err := rs.Transpose()
+	require.NoError(t, err)
+	require.Equal(t, []ColumnDef{{Name: "column", Type: "VARCHAR"}, {Name: "row_1", Type: "VARCHAR"}, {Name: "row_2", Type: "VARCHAR"}},
+		[]ColumnDef{tr.ColumnDef(0), tr.ColumnDef(1), tr.ColumnDef(2)})
+	require.NoError(t, tr.AssertData(Rows{{"id", "1", "2"}, {"v", "a", nil}}))
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, tr.PrettyPrintMarkdown(buf))
+	require.Equal(t, "| column | row_1 | row_2 |\n| --- | --- | --- |\n| id | 1 | 2 |\n| v | a | NULL |\n", buf.String())
+	raw, err := tr.Encode()
+	require.NoError(t, err)
+	var decoded ResultSet
+	require.NoError(t, decoded.Decode(raw))
+	require.Equal(t, tr.DataDigest(DigestOptions{}), decoded.DataDigest(DigestOptions{}))
+
+	_, err = (&ResultSet{}).Transpose()
+	require.Error(t, err)
+}
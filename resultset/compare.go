@@ -0,0 +1,74 @@
+package resultset
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TypeMismatchError reports a column declared with different types in compared result sets.
+type TypeMismatchError struct {
+	Index  int
+	Column string
+	Expect string
+	Actual string
+}
+
+func (e *TypeMismatchError) Error() string {
+	return fmt.Sprintf("column %s type mismatch: expect %s, got %s", e.Column, e.Expect, e.Actual)
+}
+
+// DataMismatchError reports compared result sets having different data digests.
+type DataMismatchError struct {
+	Expect string
+	Actual string
+}
+
+func (e *DataMismatchError) Error() string {
+	return fmt.Sprintf("data mismatch: expect digest %s, got %s", e.Expect, e.Actual)
+}
+
+// CompareColumnTypes returns a *TypeMismatchError for the first column whose declared types (case-insensitively)
+// differ in expect and actual, which should have the same number of columns.
+func CompareColumnTypes(expect *ResultSet, actual *ResultSet) error {
+	for j, c := range expect.cols {
+		if j >= len(actual.cols) {
+			break
+		}
+		if d := actual.cols[j]; !strings.EqualFold(c.Type, d.Type) {
+			return &TypeMismatchError{Index: j, Column: c.Name, Expect: c.Type, Actual: d.Type}
+		}
+	}
+	return nil
+}
+
+// CompareResultSets checks whether actual equals to expect by their data digests computed by opts. With
+// opts.CompareTypes, a type mismatch is reported as a *TypeMismatchError, while a data mismatch is always reported as
+// a *DataMismatchError.
+func CompareResultSets(expect *ResultSet, actual *ResultSet, opts DigestOptions) error {
+	if expect.IsExecResult() != actual.IsExecResult() {
+		return fmt.Errorf("result type mismatch: expect %s, got %s", expect, actual)
+	}
+	if expect.IsExecResult() {
+		return nil
+	}
+	if expect.NCols() != actual.NCols() {
+		return fmt.Errorf("column count mismatch: expect %d, got %d", expect.NCols(), actual.NCols())
+	}
+	if opts.CompareTypes {
+		if err := CompareColumnTypes(expect, actual); err != nil {
+			return err
+		}
+	}
+	if h1, h2 := expect.DataDigest(opts), actual.DataDigest(opts); h1 != h2 {
+		return &DataMismatchError{Expect: h1, Actual: h2}
+	}
+	return nil
+}
+
+func (rs *ResultSet) encodeTypesTo(w io.Writer) {
+	for _, c := range rs.cols {
+		t := strings.ToUpper(c.Type)
+		fmt.Fprintf(w, "%d:%s;", len(t), t)
+	}
+}
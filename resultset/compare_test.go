@@ -0,0 +1,43 @@
+package resultset
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareResultSets(t *testing.T) {
+	parse := func(js string) *ResultSet {
+		rs, err := FromJSON([]byte(js))
+		require.NoError(t, err)
+		return rs
+	}
+	a := parse(`{"columns":[{"name":"id","type":"INT"},{"name":"v","type":"VARCHAR"}],"rows":[[1,"a"],[2,"b"]]}`)
+	b := parse(`{"columns":[{"name":"id","type":"BIGINT"},{"name":"v","type":"varchar"}],"rows":[[1,"a"],[2,"b"]]}`)
+	c := parse(`{"columns":[{"name":"id","type":"BIGINT"},{"name":"v","type":"VARCHAR"}],"rows":[[2,"b"],[1,"a"]]}`)
+
+	require.NoError(t, CompareResultSets(a, b, DigestOptions{}))
+	require.Equal(t, a.DataDigest(DigestOptions{}), b.DataDigest(DigestOptions{}))
+	require.NotEqual(t, a.DataDigest(DigestOptions{CompareTypes: true}), b.DataDigest(DigestOptions{CompareTypes: true}))
+	require.NotEqual(t, a.DataDigest(DigestOptions{CompareTypes: true, Sort: true}), b.DataDigest(DigestOptions{CompareTypes: true, Sort: true}))
+	require.Equal(t, b.DataDigest(DigestOptions{CompareTypes: true, Sort: true}), c.DataDigest(DigestOptions{CompareTypes: true, Sort: true}))
+
+	err := CompareResultSets(a, b, DigestOptions{CompareTypes: true})
+	var terr *TypeMismatchError
+	require.True(t, errors.As(err, &terr))
+	require.Equal(t, &TypeMismatchError{Index: 0, Column: "id", Expect: "INT", Actual: "BIGINT"}, terr)
+	require.EqualError(t, err, "column id type mismatch: expect INT, got BIGINT")
+
+	err = CompareResultSets(b, c, DigestOptions{CompareTypes: true})
+	var derr *DataMismatchError
+	require.True(t, errors.As(err, &derr))
+	require.NoError(t, CompareResultSets(b, c, DigestOptions{CompareTypes: true, Sort: true}))
+
+	exec := &ResultSet{exec: ExecResult{RowsAffected: 1}}
+	require.NoError(t, CompareResultSets(exec, exec, DigestOptions{CompareTypes: true}))
+	require.Error(t, CompareResultSets(a, exec, DigestOptions{}))
+	ids, err := a.Project("id")
+	require.NoError(t, err)
+	require.EqualError(t, CompareResultSets(a, ids, DigestOptions{}), "column count mismatch: expect 2, got 1")
+}
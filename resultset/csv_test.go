@@ -0,0 +1,121 @@
+package resultset
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCSV(t *testing.T) {
+	rs := ResultSet{
+		cols: []ColumnDef{{Name: "id", Type: "INT"}, {Name: "note", Type: "VARCHAR"}, {Name: "bin", Type: "VARBINARY"}},
+		data: [][][]byte{
+			{[]byte("1"), []byte("a,b"), []byte{0xde, 0xad}},
+			{[]byte("2"), []byte(`say "hi"`), nil},
+			{[]byte("3"), []byte("line1\nline2"), []byte{}},
+			{[]byte("4"), []byte("x\ty"), []byte("ok")},
+		},
+	}
+	rs.markNil(1, 2)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, rs.WriteCSV(buf, CSVOptions{Header: true}))
+	require.Equal(t, strings.Join([]string{
+		"id,note,bin",
+		`1,"a,b",dead`,
+		`2,"say ""hi""",\N`,
+		"3,\"line1\nline2\",",
+		"4,x\ty,6f6b",
+		"",
+	}, "\n"), buf.String())
+
+	buf.Reset()
+	require.NoError(t, rs.WriteCSV(buf, CSVOptions{Delimiter: '\t', NullString: "NULL", EncodeBinary: func(raw []byte) string { return string(raw) }}))
+	require.Equal(t, strings.Join([]string{
+		"1\ta,b\t\xde\xad",
+		"2\t\"say \"\"hi\"\"\"\tNULL",
+		"3\t\"line1\nline2\"\t",
+		"4\t\"x\ty\"\tok",
+		"",
+	}, "\n"), buf.String())
+}
+
+func TestWriteCSVExecResult(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rs := ResultSet{exec: ExecResult{RowsAffected: 3, HasRowsAffected: true}}
+	require.NoError(t, rs.WriteCSV(buf, CSVOptions{Header: true}))
+	require.Equal(t, "rows_affected,last_insert_id\n3,\\N\n", buf.String())
+}
+
+func TestFromCSV(t *testing.T) {
+	rs := ResultSet{
+		cols: []ColumnDef{{Name: "id", Type: "INT"}, {Name: "note", Type: "VARCHAR"}, {Name: "bin", Type: "VARBINARY"}},
+		data: [][][]byte{
+			{[]byte("1"), []byte("a,b"), []byte{0xde, 0xad}},
+			{[]byte("2"), []byte(`say "hi"`), nil},
+			{[]byte("3"), []byte("line1\nline2"), []byte{}},
+			{[]byte("4"), nil, []byte("ok")},
+		},
+	}
+	rs.markNil(1, 2)
+	rs.markNil(3, 1)
+
+	for _, opts := range []CSVOptions{{Header: true}, {Delimiter: '\t', NullString: "NULL"}} {
+		buf := new(bytes.Buffer)
+		require.NoError(t, rs.WriteCSV(buf, opts))
+		out, err := FromCSV(buf, CSVReadOptions{
+			Header:     opts.Header,
+			Delimiter:  opts.Delimiter,
+			NullString: opts.NullString,
+			Types:      []string{"INT", "", "VARBINARY"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, rs.DataDigest(DigestOptions{CompareTypes: true}), out.DataDigest(DigestOptions{CompareTypes: true}))
+		ok, diffs := rs.Equal(out, CompareOptions{})
+		require.True(t, ok, "%v", diffs)
+		if opts.Header {
+			require.Equal(t, "note", out.ColumnDef(1).Name)
+		} else {
+			require.Equal(t, "column_2", out.ColumnDef(1).Name)
+		}
+	}
+
+	// type hints make numeric ordering and float tolerance work
+	live := ResultSet{cols: []ColumnDef{{Name: "n", Type: "INT"}, {Name: "f", Type: "DOUBLE"}}, data: [][][]byte{
+		{[]byte("9"), []byte("0.30000000000000004")},
+		{[]byte("10"), []byte("2.5")},
+	}}
+	csvData := "n,f\n10,2.5\n9,0.3\n"
+	sorted := DigestOptions{SortKeys: []SortKey{{Column: "n"}}}
+	out, err := FromCSV(strings.NewReader(csvData), CSVReadOptions{Header: true, Types: []string{"INT", "DOUBLE"}})
+	require.NoError(t, err)
+	ok, _ := live.Equal(out, CompareOptions{Digest: sorted, FloatTolerance: 1e-9})
+	require.True(t, ok)
+	out, err = FromCSV(strings.NewReader(csvData), CSVReadOptions{Header: true})
+	require.NoError(t, err)
+	ok, _ = live.Equal(out, CompareOptions{Digest: sorted, FloatTolerance: 1e-9})
+	require.False(t, ok)
+
+	_, err = FromCSV(strings.NewReader("a,b\n1,2\n3\n"), CSVReadOptions{Header: true})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "line 3")
+	_, err = FromCSV(strings.NewReader("a\n00\nzz\n"), CSVReadOptions{Header: true, Types: []string{"BLOB"}})
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "record 3: decode a: "), err.Error())
+	_, err = FromCSV(strings.NewReader("zz\n"), CSVReadOptions{Types: []string{"BLOB"}})
+	require.True(t, strings.HasPrefix(err.Error(), "record 1: decode column_1: "), err.Error())
+	_, err = FromCSV(strings.NewReader(""), CSVReadOptions{Header: true})
+	require.EqualError(t, err, "header is missing")
+	_, err = FromCSV(strings.NewReader("1,2\n"), CSVReadOptions{Types: []string{"INT", "INT", "INT"}})
+	require.EqualError(t, err, "there are 3 types for 2 columns")
+
+	// an empty result without header needs types to tell its columns
+	_, err = FromCSV(strings.NewReader(""), CSVReadOptions{})
+	require.EqualError(t, err, "columns are missing, an empty input without header requires types")
+	empty, err := FromCSV(strings.NewReader(""), CSVReadOptions{Types: []string{"INT", "VARCHAR"}})
+	require.NoError(t, err)
+	require.Equal(t, 2, empty.NCols())
+	require.Equal(t, 0, empty.NRows())
+}
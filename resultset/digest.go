@@ -0,0 +1,87 @@
+package resultset
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"strings"
+)
+
+// DigestAlgorithm names the hash used by DataDigest. Digests of algorithms other than DigestSHA1 are prefixed by the
+// algorithm name (e.g. "sha256:ab12..."), so digests of different algorithms never look alike. SHA-1 digests keep
+// the plain form for compatibility.
+type DigestAlgorithm string
+
+const (
+	DigestSHA1    DigestAlgorithm = "sha1"
+	DigestSHA256  DigestAlgorithm = "sha256"
+	DigestFNV128a DigestAlgorithm = "fnv128a"
+)
+
+// DigestAlgorithmOf returns the algorithm a digest is computed by.
+func DigestAlgorithmOf(digest string) DigestAlgorithm {
+	if i := strings.IndexByte(digest, ':'); i > 0 {
+		return DigestAlgorithm(digest[:i])
+	}
+	return DigestSHA1
+}
+
+// Valid reports whether the algorithm is known, the empty one stands for DigestSHA1.
+func (a DigestAlgorithm) Valid() bool {
+	switch a {
+	case "", DigestSHA1, DigestSHA256, DigestFNV128a:
+		return true
+	default:
+		return false
+	}
+}
+
+// New returns a new hash of the algorithm, it panics if the algorithm is unknown (see Valid).
+func (a DigestAlgorithm) New() hash.Hash {
+	switch a {
+	case "", DigestSHA1:
+		return sha1.New()
+	case DigestSHA256:
+		return sha256.New()
+	case DigestFNV128a:
+		return fnv.New128a()
+	default:
+		panic(fmt.Sprintf("unknown digest algorithm: %s", string(a)))
+	}
+}
+
+// Format renders a hash sum as a digest of the algorithm.
+func (a DigestAlgorithm) Format(sum []byte) string {
+	if a == "" || a == DigestSHA1 {
+		return hex.EncodeToString(sum)
+	}
+	return string(a) + ":" + hex.EncodeToString(sum)
+}
+
+// RowDigests digests every row independently with the same normalizations (Filter, Mapper, JSONSemantic and Hash)
+// as DataDigest, Sort and SortKeys are ignored. An exec result has no row digests.
+func (rs *ResultSet) RowDigests(opts DigestOptions) []string {
+	if rs.IsExecResult() {
+		return nil
+	}
+	if opts.JSONSemantic {
+		opts.Mapper = jsonSemanticMapper(opts.Mapper)
+	}
+	digests := make([]string, len(rs.data))
+	for i := range rs.data {
+		digests[i] = opts.Hash.Format(rs.rowDigest(i, opts))
+	}
+	return digests
+}
+
+// RowDigestCounts returns the multiset of RowDigests, it maps a row digest to the number of rows having it.
+func (rs *ResultSet) RowDigestCounts(opts DigestOptions) map[string]int {
+	counts := make(map[string]int)
+	for _, d := range rs.RowDigests(opts) {
+		counts[d]++
+	}
+	return counts
+}
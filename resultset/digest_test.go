@@ -0,0 +1,77 @@
+package resultset
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestAlgorithm(t *testing.T) {
+	rs, err := FromJSON([]byte(`{"columns":[{"name":"a","type":"INT"}],"rows":[[2],[1],[null]]}`))
+	require.NoError(t, err)
+
+	def := rs.DataDigest(DigestOptions{})
+	require.Len(t, def, 40)
+	require.Equal(t, def, rs.DataDigest(DigestOptions{Hash: DigestSHA1}))
+	require.Equal(t, DigestSHA1, DigestAlgorithmOf(def))
+
+	for _, tt := range []struct {
+		alg DigestAlgorithm
+		n   int
+	}{{DigestSHA256, 64}, {DigestFNV128a, 32}} {
+		for _, sorted := range []bool{false, true} {
+			d := rs.DataDigest(DigestOptions{Hash: tt.alg, Sort: sorted})
+			require.True(t, strings.HasPrefix(d, string(tt.alg)+":"), d)
+			require.Len(t, d, len(tt.alg)+1+tt.n)
+			require.Equal(t, tt.alg, DigestAlgorithmOf(d))
+		}
+	}
+	require.NotEqual(t, rs.DataDigest(DigestOptions{Hash: DigestSHA256}), rs.DataDigest(DigestOptions{Hash: DigestSHA256, Sort: true}))
+	require.Panics(t, func() { rs.DataDigest(DigestOptions{Hash: "md5"}) })
+	require.True(t, DigestAlgorithm("").Valid())
+	require.True(t, DigestFNV128a.Valid())
+	require.False(t, DigestAlgorithm("md5").Valid())
+}
+
+func TestRowDigests(t *testing.T) {
+	rs := New([]ColumnDef{{Name: "a", Type: "INT"}, {Name: "j", Type: "JSON"}})
+	rs.data = [][][]byte{
+		{[]byte("1"), []byte(`{"x": 1, "y": 2}`)},
+		{[]byte("2"), nil},
+		{[]byte("1"), []byte(`{"y":2,"x":1}`)},
+		{[]byte("3"), []byte("")},
+	}
+	rs.markNil(1, 1)
+
+	ds := rs.RowDigests(DigestOptions{Sort: true})
+	require.Len(t, ds, 4)
+	require.NotEqual(t, ds[0], ds[2])
+	require.NotEqual(t, ds[1], ds[3])
+
+	ds = rs.RowDigests(DigestOptions{JSONSemantic: true, Hash: DigestSHA256})
+	require.Equal(t, ds[0], ds[2])
+	require.True(t, strings.HasPrefix(ds[0], "sha256:"))
+	require.Equal(t, map[string]int{ds[0]: 2, ds[1]: 1, ds[3]: 1}, rs.RowDigestCounts(DigestOptions{JSONSemantic: true, Hash: DigestSHA256}))
+
+	raw, err := rs.Encode()
+	require.NoError(t, err)
+	var decoded ResultSet
+	require.NoError(t, decoded.Decode(raw))
+	require.Equal(t, rs.RowDigests(DigestOptions{}), decoded.RowDigests(DigestOptions{}))
+
+	require.Nil(t, (&ResultSet{exec: ExecResult{RowsAffected: 1}}).RowDigests(DigestOptions{}))
+}
+
+func TestDataDigestShapeOnly(t *testing.T) {
+	opts := DigestOptions{ShapeOnly: true}
+	a, b := syntheticResultSet(3), syntheticResultSet(3)
+	b.data[0][1] = []byte("changed")
+	require.NotEqual(t, a.DataDigest(DigestOptions{}), b.DataDigest(DigestOptions{}))
+	require.Equal(t, a.DataDigest(opts), b.DataDigest(opts))
+	require.NotEqual(t, a.DataDigest(opts), syntheticResultSet(4).DataDigest(opts))
+	require.NotEqual(t, a.DataDigest(opts), a.RenameColumn("v", "w").DataDigest(opts))
+	require.NotEqual(t, a.DataDigest(opts), a.DataDigest(DigestOptions{ShapeOnly: true, CompareTypes: true}))
+	b.truncated = true
+	require.NotEqual(t, a.DataDigest(opts), b.DataDigest(opts))
+}
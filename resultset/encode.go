@@ -0,0 +1,384 @@
+package resultset
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+
+	"github.com/golang/snappy"
+)
+
+// Compression selects how EncodeWith compresses the payload.
+type Compression byte
+
+const (
+	CompressionGzip Compression = iota
+	CompressionNone
+	CompressionSnappy
+)
+
+func (c Compression) String() string {
+	switch c {
+	case CompressionGzip:
+		return "gzip"
+	case CompressionNone:
+		return "none"
+	case CompressionSnappy:
+		return "snappy"
+	default:
+		return fmt.Sprintf("compression(%d)", byte(c))
+	}
+}
+
+// Payloads are wrapped in a versioned envelope: encodeMagic, a version byte and then version specific fields. The
+// version 1 envelope has a compression byte and a flags byte. Payloads without the magic are legacy gzip streams,
+// which always start with the gzip magic 0x1f 0x8b.
+const (
+	encodeMagic   = "\xffRS"
+	encodeVersion = 1
+)
+
+// encodeFlagChecksum marks a payload followed by a big endian CRC32 (IEEE) of the bytes between the envelope and the
+// checksum itself. Payloads without the flag are decoded without verification.
+const encodeFlagChecksum byte = 0x01
+
+// ErrUnsupportedVersion is returned when decoding a payload encoded by a newer version of the package.
+var ErrUnsupportedVersion = errors.New("unsupported encoding version")
+
+// ErrCorruptPayload is returned when decoding a payload which doesn't match its checksum, e.g. a truncated one.
+var ErrCorruptPayload = errors.New("corrupt result set payload")
+
+type EncodeOptions struct {
+	Compression Compression
+}
+
+func (rs *ResultSet) Encode() ([]byte, error) {
+	return rs.EncodeWith(EncodeOptions{})
+}
+
+// EncodeWith encodes rs with the given options, they are recorded in the payload so that Decode needs no options.
+func (rs *ResultSet) EncodeWith(opts EncodeOptions) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := rs.EncodeToWith(buf, opts); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeTo writes the column definitions first and then rows in chunks, so that the encoder never buffers more than a
+// chunk of rows.
+func (rs *ResultSet) EncodeTo(w io.Writer) error {
+	return rs.EncodeToWith(w, EncodeOptions{})
+}
+
+// EncodeToWith is the streaming version of EncodeWith.
+func (rs *ResultSet) EncodeToWith(w io.Writer, opts EncodeOptions) error {
+	switch opts.Compression {
+	case CompressionGzip, CompressionNone, CompressionSnappy:
+	default:
+		return fmt.Errorf("unknown compression: %s", opts.Compression)
+	}
+	if _, err := io.WriteString(w, encodeMagic+string([]byte{encodeVersion, byte(opts.Compression), encodeFlagChecksum})); err != nil {
+		return err
+	}
+	crc := crc32.NewIEEE()
+	if err := rs.encodeCompressedTo(io.MultiWriter(w, crc), opts.Compression); err != nil {
+		return err
+	}
+	var sum [crc32.Size]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	_, err := w.Write(sum[:])
+	return err
+}
+
+func (rs *ResultSet) encodeCompressedTo(w io.Writer, c Compression) error {
+	switch c {
+	case CompressionNone:
+		return rs.encodeTo(w)
+	case CompressionSnappy:
+		sw := snappy.NewBufferedWriter(w)
+		if err := rs.encodeTo(sw); err != nil {
+			sw.Close()
+			return err
+		}
+		return sw.Close()
+	default:
+		zw := gzip.NewWriter(w)
+		if err := rs.encodeTo(zw); err != nil {
+			zw.Close()
+			return err
+		}
+		return zw.Close()
+	}
+}
+
+// encodeChunkRows is the number of rows EncodeTo encodes at a time.
+const encodeChunkRows = 1024
+
+// encodedHeader leads an encoded result set. Payloads written before chunked encoding carry all rows in Data and Nils
+// of the header, while chunked payloads set Chunked and follow the header with encodedChunks holding Rows in total.
+type encodedHeader struct {
+	Cols    []ColumnDef
+	Data    [][][]byte
+	Nils    []uint64
+	Exec    ExecResult
+	Chunked bool
+	Rows    int
+	// Truncated is missing in payloads written before truncation was recorded, which are decoded as not truncated.
+	Truncated bool
+}
+
+type encodedChunk struct {
+	Data [][][]byte
+	Nils []uint64
+}
+
+func (rs *ResultSet) encodeTo(w io.Writer) error {
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(encodedHeader{Cols: rs.cols, Exec: rs.exec, Chunked: true, Rows: len(rs.data), Truncated: rs.truncated}); err != nil {
+		return err
+	}
+	chunk := &ResultSet{cols: rs.cols}
+	for i := 0; i < len(rs.data); i += encodeChunkRows {
+		end := i + encodeChunkRows
+		if end > len(rs.data) {
+			end = len(rs.data)
+		}
+		chunk.data, chunk.nils = rs.data[i:end], chunk.nils[:0]
+		for k := range chunk.data {
+			for j := range rs.cols {
+				if rs.isNil(i+k, j) {
+					chunk.markNil(k, j)
+				}
+			}
+		}
+		if err := enc.Encode(encodedChunk{chunk.data, chunk.nils}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rs *ResultSet) Decode(raw []byte) error {
+	return rs.DecodeFrom(bytes.NewReader(raw))
+}
+
+// DecodeFrom reads a payload written by EncodeTo or EncodeToWith, r is consumed to the end.
+func (rs *ResultSet) DecodeFrom(r io.Reader) error {
+	pr, cr, err := openPayload(r)
+	if err != nil {
+		return err
+	}
+	if cr == nil {
+		return rs.decodeFrom(pr)
+	}
+	out := new(ResultSet)
+	err = out.decodeFrom(pr)
+	if err = verifyChecksum(cr, err); err != nil {
+		return err
+	}
+	rs.cols, rs.data, rs.nils, rs.exec, rs.truncated = out.cols, out.data, out.nils, out.exec, out.truncated
+	return nil
+}
+
+// ColumnDefs lists column definitions of an encoded result set.
+type ColumnDefs []ColumnDef
+
+func (cs ColumnDefs) Names() []string {
+	names := make([]string, len(cs))
+	for j, c := range cs {
+		names[j] = c.Name
+	}
+	return names
+}
+
+// DecodeHeader reads only the leading metadata of an encoded result set: its columns, the number of rows and whether
+// it's an exec result. Rows are neither decoded nor verified against the checksum, except that payloads written before
+// chunked encoding carry rows in their header, which are then decoded in order to count them.
+func DecodeHeader(raw []byte) (ColumnDefs, int, bool, error) {
+	pr, _, err := openPayload(bytes.NewReader(raw))
+	if err != nil {
+		return nil, 0, false, err
+	}
+	var hdr encodedHeader
+	if err = gob.NewDecoder(pr).Decode(&hdr); err != nil {
+		return nil, 0, false, err
+	}
+	if err = hdr.check(); err != nil {
+		return nil, 0, false, err
+	}
+	n := hdr.Rows
+	if !hdr.Chunked {
+		n = len(hdr.Data)
+	}
+	return ColumnDefs(hdr.Cols), n, len(hdr.Cols) == 0, nil
+}
+
+// openPayload parses the envelope of a payload and returns the (decompressed) gob stream in it. The returned
+// checksumReader is the one the stream is read through if the payload carries a checksum, otherwise it's nil.
+func openPayload(r io.Reader) (io.Reader, *checksumReader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(encodeMagic))
+	if err != nil || string(magic) != encodeMagic {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, nil, nil
+	}
+	br.Discard(len(encodeMagic))
+	version, err := br.ReadByte()
+	if err != nil {
+		return nil, nil, err
+	}
+	if version != encodeVersion {
+		return nil, nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+	var hdr [2]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		return nil, nil, err
+	}
+	c, flags := Compression(hdr[0]), hdr[1]
+	if flags&^encodeFlagChecksum != 0 {
+		return nil, nil, fmt.Errorf("%w: v%d with flags %#x", ErrUnsupportedVersion, version, flags)
+	}
+	if flags&encodeFlagChecksum == 0 {
+		pr, err := decompress(br, c)
+		return pr, nil, err
+	}
+	cr := &checksumReader{r: br, crc: crc32.NewIEEE()}
+	pr, err := decompress(cr, c)
+	if err != nil {
+		return nil, nil, verifyChecksum(cr, err)
+	}
+	return pr, cr, nil
+}
+
+func decompress(r io.Reader, c Compression) (io.Reader, error) {
+	switch c {
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionNone:
+		return r, nil
+	case CompressionSnappy:
+		return snappy.NewReader(r), nil
+	default:
+		return nil, fmt.Errorf("unknown compression: %s", c)
+	}
+}
+
+// verifyChecksum reads cr to the end and returns the error of a decoding reading through it. A broken stream is
+// usually reported by the decompressor or gob first, the checksum error is preferred if the payload turns out to be
+// corrupted.
+func verifyChecksum(cr *checksumReader, err error) error {
+	if _, cerr := io.Copy(ioutil.Discard, cr); cerr != nil && (err == nil || errors.Is(cerr, ErrCorruptPayload)) {
+		return cerr
+	}
+	return err
+}
+
+// checksumReader passes through all but the trailing checksum of r, and verifies the checksum once r reaches EOF.
+type checksumReader struct {
+	r    io.Reader
+	crc  hash.Hash32
+	tail []byte
+	err  error
+}
+
+func (cr *checksumReader) Read(p []byte) (int, error) {
+	if cr.err != nil {
+		return 0, cr.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for {
+		n, err := cr.r.Read(p)
+		// hold back the last crc32.Size bytes read so far, they might be the checksum
+		buf := make([]byte, 0, len(cr.tail)+n)
+		buf = append(append(buf, cr.tail...), p[:n]...)
+		k := len(buf) - crc32.Size
+		if k < 0 {
+			k = 0
+		}
+		copy(p, buf[:k])
+		cr.crc.Write(p[:k])
+		cr.tail = buf[k:]
+		if err == io.EOF {
+			cr.err = io.EOF
+			if len(cr.tail) < crc32.Size {
+				cr.err = fmt.Errorf("%w: missing checksum", ErrCorruptPayload)
+			} else if expect, actual := binary.BigEndian.Uint32(cr.tail), cr.crc.Sum32(); expect != actual {
+				cr.err = fmt.Errorf("%w: checksum %08x, expect %08x", ErrCorruptPayload, actual, expect)
+			}
+			return k, cr.err
+		}
+		if err != nil {
+			cr.err = err
+			return k, err
+		}
+		if k > 0 {
+			return k, nil
+		}
+	}
+}
+
+func (hdr *encodedHeader) check() error {
+	if hdr.Rows < 0 {
+		return fmt.Errorf("bad row count: %d", hdr.Rows)
+	}
+	return nil
+}
+
+func (rs *ResultSet) decodeFrom(r io.Reader) error {
+	dec := gob.NewDecoder(r)
+	var hdr encodedHeader
+	if err := dec.Decode(&hdr); err != nil {
+		return err
+	}
+	if err := hdr.check(); err != nil {
+		return err
+	}
+	out := &ResultSet{cols: hdr.Cols, data: hdr.Data, nils: hdr.Nils, exec: hdr.Exec, truncated: hdr.Truncated}
+	if hdr.Chunked {
+		// the row count comes from the payload, rows are preallocated up to a chunk and grown as they arrive
+		n := hdr.Rows
+		if n > encodeChunkRows {
+			n = encodeChunkRows
+		}
+		out.data = make([][][]byte, 0, n)
+		for len(out.data) < hdr.Rows {
+			var chunk encodedChunk
+			if err := dec.Decode(&chunk); err != nil {
+				return err
+			}
+			if len(chunk.Data) == 0 || len(out.data)+len(chunk.Data) > hdr.Rows {
+				return fmt.Errorf("bad chunk of %d rows after %d/%d rows", len(chunk.Data), len(out.data), hdr.Rows)
+			}
+			tmp := &ResultSet{cols: hdr.Cols, nils: chunk.Nils}
+			n := len(out.data)
+			for i, row := range chunk.Data {
+				out.data = append(out.data, row)
+				for j := range hdr.Cols {
+					if tmp.isNil(i, j) {
+						out.markNil(n+i, j)
+					}
+				}
+			}
+		}
+	}
+	// drain the rest, so that checksums of compressed streams (e.g. gzip's) are verified
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		return err
+	}
+	rs.cols, rs.data, rs.nils, rs.exec, rs.truncated = out.cols, out.data, out.nils, out.exec, out.truncated
+	return nil
+}
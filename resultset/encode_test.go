@@ -0,0 +1,193 @@
+package resultset
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeWith(t *testing.T) {
+	for i := range rss {
+		rs := &rss[i]
+		for _, c := range []Compression{CompressionGzip, CompressionNone, CompressionSnappy} {
+			t.Run(c.String()+"#"+strconv.Itoa(i), func(t *testing.T) {
+				raw, err := rs.EncodeWith(EncodeOptions{Compression: c})
+				require.NoError(t, err)
+				require.Equal(t, encodeMagic+string([]byte{encodeVersion, byte(c), encodeFlagChecksum}), string(raw[:6]))
+				var out ResultSet
+				require.NoError(t, out.Decode(raw))
+				require.Equal(t, rs.DataDigest(DigestOptions{}), out.DataDigest(DigestOptions{}))
+				require.Equal(t, rs.ExecResult(), out.ExecResult())
+				require.NoError(t, Diff(rs, &out, DiffOptions{CheckPrecision: true, CheckSchema: true}))
+			})
+		}
+	}
+
+	big := syntheticResultSet(encodeChunkRows * 2)
+	sizes := make(map[Compression]int)
+	for _, c := range []Compression{CompressionGzip, CompressionNone, CompressionSnappy} {
+		raw, err := big.EncodeWith(EncodeOptions{Compression: c})
+		require.NoError(t, err)
+		sizes[c] = len(raw)
+	}
+	require.Less(t, sizes[CompressionGzip], sizes[CompressionSnappy])
+	require.Less(t, sizes[CompressionSnappy], sizes[CompressionNone])
+
+	_, err := big.EncodeWith(EncodeOptions{Compression: 9})
+	require.EqualError(t, err, "unknown compression: compression(9)")
+}
+
+func TestDecodeCorrupted(t *testing.T) {
+	rs := syntheticResultSet(100)
+	for _, c := range []Compression{CompressionGzip, CompressionNone, CompressionSnappy} {
+		raw, err := rs.EncodeWith(EncodeOptions{Compression: c})
+		require.NoError(t, err)
+		var out ResultSet
+		for _, n := range []int{len(raw) / 3, len(raw) / 2, len(raw) - 1} {
+			err = out.Decode(raw[:n])
+			require.True(t, errors.Is(err, ErrCorruptPayload), "%s %d/%d: %v", c, n, len(raw), err)
+		}
+		corrupted := append([]byte{}, raw...)
+		corrupted[len(corrupted)/2] ^= 0xff
+		require.True(t, errors.Is(out.Decode(corrupted), ErrCorruptPayload), c.String())
+		corrupted = append([]byte{}, raw...)
+		corrupted[len(corrupted)-1] ^= 0xff
+		require.True(t, errors.Is(out.Decode(corrupted), ErrCorruptPayload), c.String())
+
+		// payloads without the checksum flag are decoded without verification
+		plain := append([]byte{}, raw[:len(raw)-4]...)
+		plain[5] = 0
+		out = ResultSet{}
+		require.NoError(t, out.Decode(plain), c.String())
+		require.Equal(t, rs.DataDigest(DigestOptions{}), out.DataDigest(DigestOptions{}))
+	}
+	raw, err := rs.Encode()
+	require.NoError(t, err)
+	corrupted := append([]byte{}, raw...)
+	corrupted[len(corrupted)-5] ^= 0xff
+	var out ResultSet
+	require.Error(t, out.Decode(corrupted))
+	require.EqualError(t, out.Decode([]byte(encodeMagic+"\x01\x09\x00")), "unknown compression: compression(9)")
+	require.Error(t, out.Decode([]byte("junk")))
+	require.Error(t, out.Decode(nil))
+}
+
+func TestDecodeBadRowCount(t *testing.T) {
+	encodeHeader := func(hdr encodedHeader) []byte {
+		buf := new(bytes.Buffer)
+		zw := gzip.NewWriter(buf)
+		require.NoError(t, gob.NewEncoder(zw).Encode(hdr))
+		require.NoError(t, zw.Close())
+		return buf.Bytes()
+	}
+	cols := []ColumnDef{{Name: "a", Type: "INT"}}
+	var out ResultSet
+	raw := encodeHeader(encodedHeader{Cols: cols, Chunked: true, Rows: -5})
+	require.EqualError(t, out.Decode(raw), "bad row count: -5")
+	_, _, _, err := DecodeHeader(raw)
+	require.EqualError(t, err, "bad row count: -5")
+
+	// a huge row count without rows fails on the missing chunk instead of allocating for it
+	raw = encodeHeader(encodedHeader{Cols: cols, Chunked: true, Rows: math.MaxInt32})
+	require.Error(t, out.Decode(raw))
+}
+
+func TestDecodeVersions(t *testing.T) {
+	var out ResultSet
+	err := out.Decode([]byte(encodeMagic + "\x02\x00\x00"))
+	require.True(t, errors.Is(err, ErrUnsupportedVersion))
+	require.EqualError(t, err, "unsupported encoding version: 2")
+	err = out.Decode([]byte(encodeMagic + "\x01\x00\x80"))
+	require.True(t, errors.Is(err, ErrUnsupportedVersion))
+	require.Error(t, out.Decode([]byte(encodeMagic+"\x01")))
+
+	raw, err := rss[3].Encode()
+	require.NoError(t, err)
+	require.Equal(t, encodeMagic+"\x01\x00\x01", string(raw[:6]))
+}
+
+func TestDecodeLegacyFixtures(t *testing.T) {
+	expect := map[string]*ResultSet{
+		"exec":  &rss[1],
+		"text":  &rss[3],
+		"mixed": syntheticResultSet(3000),
+	}
+	files, err := filepath.Glob("testdata/legacy-*.bin")
+	require.NoError(t, err)
+	require.Len(t, files, 2*len(expect))
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".bin")
+		name = name[strings.LastIndexByte(name, '-')+1:]
+		rs := expect[name]
+		require.NotNil(t, rs, file)
+		raw, err := ioutil.ReadFile(file)
+		require.NoError(t, err)
+		var out ResultSet
+		require.NoError(t, out.Decode(raw), file)
+		require.Equal(t, rs.IsExecResult(), out.IsExecResult(), file)
+		require.Equal(t, rs.ExecResult(), out.ExecResult(), file)
+		require.NoError(t, Diff(rs, &out, DiffOptions{CheckPrecision: true, CheckSchema: true}), file)
+		require.Equal(t, rs.DataDigest(DigestOptions{}), out.DataDigest(DigestOptions{}), file)
+	}
+}
+
+func TestDecodeHeader(t *testing.T) {
+	rs := syntheticResultSet(encodeChunkRows + 1)
+	for _, c := range []Compression{CompressionGzip, CompressionNone, CompressionSnappy} {
+		raw, err := rs.EncodeWith(EncodeOptions{Compression: c})
+		require.NoError(t, err)
+		cols, n, isExec, err := DecodeHeader(raw)
+		require.NoError(t, err, c.String())
+		require.Equal(t, []string{"id", "v"}, cols.Names())
+		require.Equal(t, "BIGINT", cols[0].Type)
+		require.Equal(t, rs.NRows(), n)
+		require.False(t, isExec)
+	}
+
+	raw, err := rss[1].Encode()
+	require.NoError(t, err)
+	cols, n, isExec, err := DecodeHeader(raw)
+	require.NoError(t, err)
+	require.Empty(t, cols)
+	require.Zero(t, n)
+	require.True(t, isExec)
+
+	files, err := filepath.Glob("testdata/legacy*-mixed.bin")
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	for _, file := range files {
+		raw, err := ioutil.ReadFile(file)
+		require.NoError(t, err)
+		cols, n, _, err := DecodeHeader(raw)
+		require.NoError(t, err, file)
+		require.Len(t, cols, 2, file)
+		require.Equal(t, 3000, n, file)
+	}
+
+	_, _, _, err = DecodeHeader([]byte(encodeMagic + "\x02\x00\x00"))
+	require.True(t, errors.Is(err, ErrUnsupportedVersion))
+}
+
+func TestEncodeTruncated(t *testing.T) {
+	rs := syntheticResultSet(10)
+	complete := rs.DataDigest(DigestOptions{})
+	rs.truncated = true
+	require.NotEqual(t, complete, rs.DataDigest(DigestOptions{}))
+	require.NotEqual(t, rs.clone().DataDigest(DigestOptions{Sort: true}), syntheticResultSet(10).DataDigest(DigestOptions{Sort: true}))
+
+	raw, err := rs.Encode()
+	require.NoError(t, err)
+	var out ResultSet
+	require.NoError(t, out.Decode(raw))
+	require.True(t, out.Truncated())
+	require.Equal(t, rs.DataDigest(DigestOptions{}), out.DataDigest(DigestOptions{}))
+}
@@ -0,0 +1,214 @@
+package resultset
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type CompareOptions struct {
+	// Digest provides the normalizations (Filter, Mapper, JSONSemantic) applied to cells before they are compared,
+	// rows are ordered by Sort or SortKeys like DataDigest does, and column types are compared if CompareTypes is set.
+	// With ShapeOnly, only numbers of rows and columns and column names are compared.
+	Digest DigestOptions
+	// FloatTolerance treats values of float columns as equal if they differ by no more than the given amount.
+	FloatTolerance float64
+	// NullAsEmpty treats NULL as an empty value.
+	NullAsEmpty bool
+}
+
+type CellDiffKind string
+
+const (
+	CellValueMismatch   CellDiffKind = "value"
+	ColumnTypeMismatch  CellDiffKind = "column type"
+	ColumnNameMismatch  CellDiffKind = "column name"
+	ColumnCountMismatch CellDiffKind = "column count"
+	RowCountMismatch    CellDiffKind = "row count"
+	ResultKindMismatch  CellDiffKind = "result kind"
+	TruncationMismatch  CellDiffKind = "truncation"
+	// InvalidOptions reports digest options that cannot be honoured (see DigestOptions.Validate), Expect and Actual
+	// are the errors of both sides, empty if the side is fine.
+	InvalidOptions CellDiffKind = "invalid options"
+)
+
+// CellDiff is a difference found by Equal. Besides cells of different values, it may report the shape of compared
+// result sets, in which case Expect and Actual describe both sides, e.g. their numbers of rows.
+type CellDiff struct {
+	Kind CellDiffKind
+	// Row is the index of the row in the compared order, -1 if it's not about a row.
+	Row int
+	// Column is the name of the column, empty if it's not about a column.
+	Column string
+	// Expect and Actual are values of the cell (Null for NULL) or descriptions of both sides.
+	Expect string
+	Actual string
+}
+
+func (d CellDiff) String() string {
+	switch d.Kind {
+	case CellValueMismatch:
+		return fmt.Sprintf("row %d column %s: expect %s, got %s", d.Row, d.Column, cellDiffText(d.Expect), cellDiffText(d.Actual))
+	case ColumnTypeMismatch:
+		return fmt.Sprintf("column %s type mismatch: expect %s, got %s", d.Column, d.Expect, d.Actual)
+	case InvalidOptions:
+		return fmt.Sprintf("invalid options: %s", strings.Join(nonEmpty(d.Expect, d.Actual), "; "))
+	default:
+		return fmt.Sprintf("%s mismatch: expect %s, got %s", d.Kind, d.Expect, d.Actual)
+	}
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func nonEmpty(xs ...string) []string {
+	var ys []string
+	for _, x := range xs {
+		if len(x) > 0 {
+			ys = append(ys, x)
+		}
+	}
+	return ys
+}
+
+func cellDiffText(v string) string {
+	if v == Null {
+		return "NULL"
+	}
+	return strconv.Quote(v)
+}
+
+// Equal compares rs (the expected one) with other (the actual one) row by row and reports all differences. Without
+// FloatTolerance or NullAsEmpty, it agrees with comparing DataDigest computed by opts.Digest. Exec results are always
+// equal to each other. Options that cannot be honoured for either side are reported as an InvalidOptions difference
+// instead of being ignored.
+func (rs *ResultSet) Equal(other *ResultSet, opts CompareOptions) (bool, []CellDiff) {
+	if rs.IsExecResult() != other.IsExecResult() {
+		return false, []CellDiff{{Kind: ResultKindMismatch, Row: -1, Expect: rs.String(), Actual: other.String()}}
+	}
+	if rs.IsExecResult() {
+		return true, nil
+	}
+	if rs.NCols() != other.NCols() {
+		return false, []CellDiff{{Kind: ColumnCountMismatch, Row: -1,
+			Expect: strconv.Itoa(rs.NCols()), Actual: strconv.Itoa(other.NCols())}}
+	}
+	e1, e2 := opts.Digest.Validate(rs), opts.Digest.Validate(other)
+	if e1 != nil || e2 != nil {
+		return false, []CellDiff{{Kind: InvalidOptions, Row: -1, Expect: errorText(e1), Actual: errorText(e2)}}
+	}
+	var diffs []CellDiff
+	if opts.Digest.CompareTypes {
+		for j, c := range rs.cols {
+			if d := other.cols[j]; !strings.EqualFold(c.Type, d.Type) {
+				diffs = append(diffs, CellDiff{Kind: ColumnTypeMismatch, Row: -1, Column: c.Name, Expect: c.Type, Actual: d.Type})
+			}
+		}
+	}
+	if rs.truncated != other.truncated {
+		diffs = append(diffs, CellDiff{Kind: TruncationMismatch, Row: -1,
+			Expect: strconv.FormatBool(rs.truncated), Actual: strconv.FormatBool(other.truncated)})
+	}
+
+	if opts.Digest.ShapeOnly {
+		for j, c := range rs.cols {
+			if d := other.cols[j]; c.Name != d.Name {
+				diffs = append(diffs, CellDiff{Kind: ColumnNameMismatch, Row: -1, Column: c.Name, Expect: c.Name, Actual: d.Name})
+			}
+		}
+		if len(rs.data) != len(other.data) {
+			diffs = append(diffs, CellDiff{Kind: RowCountMismatch, Row: -1,
+				Expect: strconv.Itoa(len(rs.data)), Actual: strconv.Itoa(len(other.data))})
+		}
+		return len(diffs) == 0, diffs
+	}
+
+	dopts := opts.Digest
+	if dopts.JSONSemantic {
+		dopts.Mapper = jsonSemanticMapper(dopts.Mapper)
+	}
+	a, oa, _ := rs.comparedOrder(dopts)
+	b, ob, _ := other.comparedOrder(dopts)
+	for k := 0; k < len(oa) && k < len(ob); k++ {
+		i1, i2 := oa[k], ob[k]
+		for j, c := range a.cols {
+			in1 := dopts.Filter == nil || dopts.Filter(i1, j, a.data[i1][j], a.cols[j])
+			in2 := dopts.Filter == nil || dopts.Filter(i2, j, b.data[i2][j], b.cols[j])
+			if !in1 && !in2 {
+				continue
+			}
+			if in1 && in2 && a.cellEqual(i1, b, i2, j, dopts.Mapper, opts) {
+				continue
+			}
+			diffs = append(diffs, CellDiff{Kind: CellValueMismatch, Row: k, Column: c.Name,
+				Expect: a.cellValue(i1, j), Actual: b.cellValue(i2, j)})
+		}
+	}
+	if len(oa) != len(ob) {
+		diffs = append(diffs, CellDiff{Kind: RowCountMismatch, Row: -1,
+			Expect: strconv.Itoa(len(oa)), Actual: strconv.Itoa(len(ob))})
+	}
+	return len(diffs) == 0, diffs
+}
+
+// comparedOrder returns the result set to compare (sorted by opts.SortKeys if required) along with indexes of its
+// rows in the compared order and digests of rows in the same order. opts.Mapper should have been resolved, and
+// unresolvable SortKeys leave rows in the original order (callers validate opts if that matters).
+func (rs *ResultSet) comparedOrder(opts DigestOptions) (*ResultSet, []int, [][]byte) {
+	if len(opts.SortKeys) > 0 && !opts.Sort {
+		sorted := rs.clone()
+		if err := sorted.SortBy(opts.SortKeys...); err == nil {
+			rs = sorted
+		}
+	}
+	order := make([]int, len(rs.data))
+	digests := make([][]byte, len(rs.data))
+	for i := range rs.data {
+		order[i], digests[i] = i, rs.rowDigest(i, opts)
+	}
+	if opts.Sort {
+		sort.SliceStable(order, func(x, y int) bool { return bytes.Compare(digests[order[x]], digests[order[y]]) < 0 })
+	}
+	sorted := make([][]byte, len(order))
+	for k, i := range order {
+		sorted[k] = digests[i]
+	}
+	return rs, order, sorted
+}
+
+func (rs *ResultSet) cellValue(i int, j int) string {
+	if rs.isNil(i, j) {
+		return Null
+	}
+	return string(rs.data[i][j])
+}
+
+func (rs *ResultSet) cellEqual(i int, other *ResultSet, k int, j int, mapper func(i int, j int, raw []byte, def ColumnDef) []byte, opts CompareOptions) bool {
+	v1, v2 := rs.data[i][j], other.data[k][j]
+	if mapper != nil {
+		v1, v2 = mapper(i, j, v1, rs.cols[j]), mapper(k, j, v2, other.cols[j])
+	}
+	n1, n2 := rs.isNil(i, j), other.isNil(k, j)
+	if opts.NullAsEmpty {
+		n1, n2 = false, false
+	}
+	if n1 || n2 {
+		return n1 == n2 && bytes.Equal(v1, v2)
+	}
+	if bytes.Equal(v1, v2) {
+		return true
+	}
+	if opts.FloatTolerance > 0 && isFloatType(rs.cols[j].Type) {
+		f1, err1 := strconv.ParseFloat(string(v1), 64)
+		f2, err2 := strconv.ParseFloat(string(v2), 64)
+		return err1 == nil && err2 == nil && math.Abs(f1-f2) <= opts.FloatTolerance
+	}
+	return false
+}
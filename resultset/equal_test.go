@@ -0,0 +1,90 @@
+package resultset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEqual(t *testing.T) {
+	parse := func(js string) *ResultSet {
+		rs, err := FromJSON([]byte(js))
+		require.NoError(t, err)
+		return rs
+	}
+	schema := `"columns":[{"name":"id","type":"INT"},{"name":"f","type":"DOUBLE"},{"name":"v","type":"VARCHAR"}]`
+	a := parse(`{` + schema + `,"rows":[[1,0.5,"a"],[2,1.25,null],[3,2,"c"]]}`)
+	b := parse(`{` + schema + `,"rows":[[1,0.5,"a"],[2,1.2500001,""],[3,2,"x"]]}`)
+
+	ok, diffs := a.Equal(a, CompareOptions{})
+	require.True(t, ok)
+	require.Empty(t, diffs)
+
+	ok, diffs = a.Equal(b, CompareOptions{})
+	require.False(t, ok)
+	require.Equal(t, []CellDiff{
+		{Kind: CellValueMismatch, Row: 1, Column: "f", Expect: "1.25", Actual: "1.2500001"},
+		{Kind: CellValueMismatch, Row: 1, Column: "v", Expect: Null, Actual: ""},
+		{Kind: CellValueMismatch, Row: 2, Column: "v", Expect: "c", Actual: "x"},
+	}, diffs)
+	require.Equal(t, `row 1 column v: expect NULL, got ""`, diffs[1].String())
+
+	ok, diffs = a.Equal(b, CompareOptions{FloatTolerance: 1e-6, NullAsEmpty: true})
+	require.False(t, ok)
+	require.Equal(t, []CellDiff{{Kind: CellValueMismatch, Row: 2, Column: "v", Expect: "c", Actual: "x"}}, diffs)
+
+	// normalizations and ordering of digests apply
+	shuffled := parse(`{` + schema + `,"rows":[[3,2,"c"],[1,0.5,"a"],[2,1.25,null]]}`)
+	ok, _ = a.Equal(shuffled, CompareOptions{})
+	require.False(t, ok)
+	ok, _ = a.Equal(shuffled, CompareOptions{Digest: DigestOptions{Sort: true}})
+	require.True(t, ok)
+	ok, _ = a.Equal(shuffled, CompareOptions{Digest: DigestOptions{SortKeys: []SortKey{{Column: "id"}}}})
+	require.True(t, ok)
+	skipV := func(i int, j int, raw []byte, def ColumnDef) bool { return def.Name != "v" }
+	ok, diffs = a.Equal(b, CompareOptions{Digest: DigestOptions{Filter: skipV}})
+	require.False(t, ok)
+	require.Len(t, diffs, 1)
+
+	// shapes
+	_, diffs = a.Equal(parse(`{`+schema+`,"rows":[[1,0.5,"a"]]}`), CompareOptions{})
+	require.Equal(t, []CellDiff{{Kind: RowCountMismatch, Row: -1, Expect: "3", Actual: "1"}}, diffs)
+	require.Equal(t, "row count mismatch: expect 3, got 1", diffs[0].String())
+	_, diffs = a.Equal(parse(`{"columns":[{"name":"id","type":"INT"}],"rows":[]}`), CompareOptions{})
+	require.Equal(t, []CellDiff{{Kind: ColumnCountMismatch, Row: -1, Expect: "3", Actual: "1"}}, diffs)
+	_, diffs = a.Equal(&ResultSet{exec: ExecResult{RowsAffected: 1}}, CompareOptions{})
+	require.Equal(t, "result kind mismatch: expect 3 rows in set, got 1 rows affected", diffs[0].String())
+	ok, _ = (&ResultSet{}).Equal(&ResultSet{exec: ExecResult{RowsAffected: 1}}, CompareOptions{})
+	require.True(t, ok)
+	typed := parse(`{"columns":[{"name":"id","type":"BIGINT"},{"name":"f","type":"DOUBLE"},{"name":"v","type":"VARCHAR"}],"rows":[[1,0.5,"a"],[2,1.25,null],[3,2,"c"]]}`)
+	ok, _ = a.Equal(typed, CompareOptions{})
+	require.True(t, ok)
+	_, diffs = a.Equal(typed, CompareOptions{Digest: DigestOptions{CompareTypes: true}})
+	require.Equal(t, "column id type mismatch: expect INT, got BIGINT", diffs[0].String())
+	shape := CompareOptions{Digest: DigestOptions{ShapeOnly: true}}
+	ok, _ = a.Equal(b, shape)
+	require.True(t, ok)
+	renamed := a.RenameColumn("v", "w")
+	_, diffs = a.Equal(renamed, shape)
+	require.Equal(t, []CellDiff{{Kind: ColumnNameMismatch, Row: -1, Column: "v", Expect: "v", Actual: "w"}}, diffs)
+	_, diffs = a.Equal(a.Head(2), shape)
+	require.Equal(t, []CellDiff{{Kind: RowCountMismatch, Row: -1, Expect: "3", Actual: "2"}}, diffs)
+	truncated := a.clone()
+	truncated.truncated = true
+	_, diffs = a.Equal(truncated, CompareOptions{})
+	require.Equal(t, []CellDiff{{Kind: TruncationMismatch, Row: -1, Expect: "false", Actual: "true"}}, diffs)
+}
+
+func TestEqualAgreesWithDataDigest(t *testing.T) {
+	for _, x := range rss {
+		for _, y := range rss {
+			for _, opts := range []DigestOptions{{}, {Sort: true}, {ShapeOnly: true}} {
+				if x.IsExecResult() || y.IsExecResult() || x.NCols() != y.NCols() {
+					continue
+				}
+				ok, _ := x.Equal(&y, CompareOptions{Digest: opts})
+				require.Equal(t, x.DataDigest(opts) == y.DataDigest(opts), ok)
+			}
+		}
+	}
+}
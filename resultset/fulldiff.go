@@ -0,0 +1,82 @@
+package resultset
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/zyguan/sqlz/internal/diff"
+)
+
+// fullDiffContext is the number of unchanged rows shown around changed ones.
+const fullDiffContext = 3
+
+// FullDiff compares rs (the expected one) with other (the actual one) and renders rows that differ in the unified diff
+// format, it returns an empty string if they are equal. Rows are compared with the same normalizations as DataDigest
+// using opts, rows are ordered by their digests before comparing when opts.Sort is set, and by opts.SortKeys when
+// given (check them by opts.Validate first, unresolvable keys leave rows unsorted). The first line of the diff lists
+// columns, it differs only if opts.CompareTypes is set and types differ.
+func (rs *ResultSet) FullDiff(other *ResultSet, opts DigestOptions) string {
+	if rs.IsExecResult() || other.IsExecResult() {
+		a, b := rs.diffExecLine(), other.diffExecLine()
+		if a == b {
+			return ""
+		}
+		return diff.Lines([]string{a}, []string{b}, "expect", "actual", fullDiffContext)
+	}
+	if rs.DataDigest(opts) == other.DataDigest(opts) {
+		return ""
+	}
+	a, da := rs.diffLines(opts)
+	b, db := other.diffLines(opts)
+	eq := func(i int, j int) bool {
+		if i == 0 || j == 0 {
+			return i == j && (!opts.CompareTypes || CompareColumnTypes(rs, other) == nil)
+		}
+		return bytes.Equal(da[i-1], db[j-1])
+	}
+	return diff.Unified(a, b, diff.Edits(len(a), len(b), eq), "expect", "actual", fullDiffContext)
+}
+
+func (rs *ResultSet) diffExecLine() string {
+	if !rs.IsExecResult() {
+		return rs.String()
+	}
+	line := rs.String()
+	if rs.exec.HasLastInsertId {
+		line += ", last insert id " + strconv.FormatInt(rs.exec.LastInsertId, 10)
+	}
+	return line
+}
+
+// diffLines renders the column line followed by rows, along with digests of rows in the same order.
+func (rs *ResultSet) diffLines(opts DigestOptions) ([]string, [][]byte) {
+	if opts.JSONSemantic {
+		opts.Mapper = jsonSemanticMapper(opts.Mapper)
+	}
+	rs, order, digests := rs.comparedOrder(opts)
+
+	hdr := make([]string, len(rs.cols))
+	for j, c := range rs.cols {
+		hdr[j] = c.Name + " " + c.Type
+	}
+	lines := []string{strings.Join(hdr, " | ")}
+	cells := make([]string, len(rs.cols))
+	for _, i := range order {
+		for j, v := range rs.data[i] {
+			switch {
+			case rs.isNil(i, j):
+				cells[j] = "NULL"
+			case len(v) == 0:
+				cells[j] = "''"
+			case isBinaryType(rs.cols[j].Type):
+				cells[j] = "0x" + hex.EncodeToString(v)
+			default:
+				cells[j] = string(v)
+			}
+		}
+		lines = append(lines, strings.Join(cells, " | "))
+	}
+	return lines, digests
+}
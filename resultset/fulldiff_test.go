@@ -0,0 +1,59 @@
+package resultset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFullDiff(t *testing.T) {
+	schema := `"columns":[{"name":"id","type":"INT"},{"name":"v","type":"VARCHAR"}]`
+	parse := func(rows string) *ResultSet {
+		rs, err := FromJSON([]byte(`{` + schema + `,"rows":` + rows + `}`))
+		require.NoError(t, err)
+		return rs
+	}
+	a := parse(`[[1,"a"],[2,null],[3,"c"],[4,"d"],[5,"e"],[6,"f"],[7,"g"],[8,"h"],[9,"i"]]`)
+	b := parse(`[[1,"a"],[2,""],[3,"c"],[4,"d"],[5,"e"],[6,"f"],[7,"g"],[8,"h"],[9,"i"],[10,"j"]]`)
+
+	require.Empty(t, a.FullDiff(a, DigestOptions{}))
+	require.Equal(t, `--- expect
++++ actual
+@@ -1,6 +1,6 @@
+ id INT | v VARCHAR
+ 1 | a
+-2 | NULL
++2 | ''
+ 3 | c
+ 4 | d
+ 5 | e
+@@ -8,3 +8,4 @@
+ 7 | g
+ 8 | h
+ 9 | i
++10 | j
+`, a.FullDiff(b, DigestOptions{}))
+
+	shuffled := parse(`[[9,"i"],[8,"h"],[7,"g"],[6,"f"],[5,"e"],[4,"d"],[3,"c"],[2,null],[1,"a"]]`)
+	require.NotEmpty(t, a.FullDiff(shuffled, DigestOptions{}))
+	require.Empty(t, a.FullDiff(shuffled, DigestOptions{Sort: true}))
+	require.Empty(t, a.FullDiff(shuffled, DigestOptions{SortKeys: []SortKey{{Column: "id"}}}))
+
+	c := parse(`[[1,"a"],[2,null],[3,"c"],[4,"d"],[5,"e"],[6,"f"],[7,"g"],[8,"h"],[9,"i"]]`)
+	c.cols[1].Type = "TEXT"
+	require.Empty(t, a.FullDiff(c, DigestOptions{}))
+	require.Equal(t, `--- expect
++++ actual
+@@ -1,4 +1,4 @@
+-id INT | v VARCHAR
++id INT | v TEXT
+ 1 | a
+ 2 | NULL
+ 3 | c
+`, a.FullDiff(c, DigestOptions{CompareTypes: true}))
+
+	e1, e2 := &ResultSet{exec: ExecResult{RowsAffected: 1}}, &ResultSet{exec: ExecResult{RowsAffected: 2}}
+	require.Empty(t, e1.FullDiff(e1, DigestOptions{}))
+	require.Contains(t, e1.FullDiff(e2, DigestOptions{}), "-1 rows affected\n+2 rows affected\n")
+	require.Contains(t, e1.FullDiff(a, DigestOptions{}), "+9 rows in set\n")
+}
@@ -0,0 +1,252 @@
+package resultset
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+const maxSafeInteger = 1 << 53
+
+type JSONOptions struct {
+	// Objects emits an array of objects keyed by column name instead of a column list plus a row matrix. Duplicate
+	// column names are made unique by appending "_2", "_3", ... in column order.
+	Objects bool
+}
+
+type jsonColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func (rs *ResultSet) MarshalJSON() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := rs.WriteJSON(buf, JSONOptions{}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (rs *ResultSet) WriteJSON(w io.Writer, opts JSONOptions) error {
+	buf := new(bytes.Buffer)
+	if rs.IsExecResult() {
+		buf.WriteString(`{"rows_affected":`)
+		writeJSONInt(buf, rs.exec.RowsAffected, rs.exec.HasRowsAffected)
+		buf.WriteString(`,"last_insert_id":`)
+		writeJSONInt(buf, rs.exec.LastInsertId, rs.exec.HasLastInsertId)
+		buf.WriteString("}")
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+	if opts.Objects {
+		names := uniqueNames(rs.cols)
+		buf.WriteString("[")
+		for i, row := range rs.data {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("{")
+			for j := range row {
+				if j > 0 {
+					buf.WriteString(",")
+				}
+				writeJSONString(buf, names[j])
+				buf.WriteString(":")
+				rs.writeJSONValue(buf, i, j)
+			}
+			buf.WriteString("}")
+		}
+		buf.WriteString("]")
+	} else {
+		cols := make([]jsonColumn, len(rs.cols))
+		for j, c := range rs.cols {
+			cols[j] = jsonColumn{c.Name, c.Type}
+		}
+		hdr, err := json.Marshal(cols)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(`{"columns":`)
+		buf.Write(hdr)
+		buf.WriteString(`,"rows":[`)
+		for i, row := range rs.data {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("[")
+			for j := range row {
+				if j > 0 {
+					buf.WriteString(",")
+				}
+				rs.writeJSONValue(buf, i, j)
+			}
+			buf.WriteString("]")
+		}
+		buf.WriteString("]}")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func FromJSON(data []byte) (*ResultSet, error) {
+	var tmp struct {
+		Columns []jsonColumn        `json:"columns"`
+		Rows    [][]json.RawMessage `json:"rows"`
+	}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return nil, err
+	}
+	if len(tmp.Columns) == 0 {
+		return nil, errors.New("columns are missing")
+	}
+	cols := make([]ColumnDef, len(tmp.Columns))
+	for j, c := range tmp.Columns {
+		cols[j] = ColumnDef{Name: c.Name, Type: c.Type}
+	}
+	rs := New(cols)
+	for i, vs := range tmp.Rows {
+		if len(vs) != len(cols) {
+			return nil, fmt.Errorf("there are %d values at row %d, expect %d", len(vs), i, len(cols))
+		}
+		row := make([][]byte, len(cols))
+		for j, v := range vs {
+			raw, isNil, err := decodeJSONValue(v, cols[j])
+			if err != nil {
+				return nil, fmt.Errorf("decode %q#%d: %v", cols[j].Name, i, err)
+			}
+			row[j] = raw
+			if isNil {
+				rs.markNil(i, j)
+			}
+		}
+		rs.data = append(rs.data, row)
+	}
+	return rs, nil
+}
+
+func (rs *ResultSet) writeJSONValue(buf *bytes.Buffer, i int, j int) {
+	if rs.isNil(i, j) {
+		buf.WriteString("null")
+		return
+	}
+	raw, t := rs.data[i][j], rs.cols[j].Type
+	switch {
+	case isIntegerType(t):
+		if x, err := strconv.ParseInt(string(raw), 10, 64); err == nil && -maxSafeInteger <= x && x <= maxSafeInteger {
+			buf.Write(raw)
+			return
+		}
+	case isFloatType(t):
+		if x, err := strconv.ParseFloat(string(raw), 64); err == nil && !math.IsInf(x, 0) && !math.IsNaN(x) && json.Valid(raw) {
+			buf.Write(raw)
+			return
+		}
+	case isDecimalType(t):
+		if _, err := strconv.ParseFloat(string(raw), 64); err == nil && json.Valid(raw) && significantDigits(raw) <= 15 {
+			buf.Write(raw)
+			return
+		}
+	case isBinaryType(t) || !utf8.Valid(raw):
+		writeJSONString(buf, hex.EncodeToString(raw))
+		return
+	}
+	writeJSONString(buf, string(raw))
+}
+
+func decodeJSONValue(v json.RawMessage, def ColumnDef) ([]byte, bool, error) {
+	v = bytes.TrimSpace(v)
+	if len(v) == 0 {
+		return nil, false, errors.New("empty value")
+	}
+	switch v[0] {
+	case 'n':
+		return nil, true, json.Unmarshal(v, new(interface{}))
+	case 't', 'f':
+		var b bool
+		if err := json.Unmarshal(v, &b); err != nil {
+			return nil, false, err
+		}
+		if b {
+			return []byte("1"), false, nil
+		}
+		return []byte("0"), false, nil
+	case '"':
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			return nil, false, err
+		}
+		if isBinaryType(def.Type) {
+			raw, err := hex.DecodeString(s)
+			return raw, false, err
+		}
+		return []byte(s), false, nil
+	default:
+		var n json.Number
+		if err := json.Unmarshal(v, &n); err != nil {
+			return nil, false, err
+		}
+		return []byte(n.String()), false, nil
+	}
+}
+
+func significantDigits(raw []byte) int {
+	n, leading := 0, true
+	for _, c := range raw {
+		if c == 'e' || c == 'E' {
+			break
+		}
+		if c < '0' || c > '9' {
+			continue
+		}
+		if leading && c == '0' {
+			continue
+		}
+		leading = false
+		n++
+	}
+	return n
+}
+
+func uniqueNames(cols []ColumnDef) []string {
+	names := make([]string, len(cols))
+	used := make(map[string]bool, len(cols))
+	for j, c := range cols {
+		used[c.Name] = true
+		names[j] = c.Name
+	}
+	seen := make(map[string]bool, len(cols))
+	for j, c := range cols {
+		if !seen[c.Name] {
+			seen[c.Name] = true
+			continue
+		}
+		for k := 2; ; k++ {
+			name := c.Name + "_" + strconv.Itoa(k)
+			if !used[name] {
+				used[name] = true
+				names[j] = name
+				break
+			}
+		}
+	}
+	return names
+}
+
+func writeJSONInt(buf *bytes.Buffer, x int64, ok bool) {
+	if ok {
+		buf.WriteString(strconv.FormatInt(x, 10))
+	} else {
+		buf.WriteString("null")
+	}
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	bs, _ := json.Marshal(s)
+	buf.Write(bs)
+}
@@ -0,0 +1,53 @@
+package resultset
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteJSON(t *testing.T) {
+	rs := ResultSet{
+		cols: []ColumnDef{
+			{Name: "id", Type: "BIGINT"},
+			{Name: "v", Type: "DOUBLE"},
+			{Name: "d", Type: "DECIMAL"},
+			{Name: "v", Type: "VARCHAR"},
+			{Name: "b", Type: "VARBINARY"},
+		},
+		data: [][][]byte{
+			{[]byte("1"), []byte("2.5"), []byte("3.14"), []byte(`"q"`), []byte{0x01, 0xff}},
+			{[]byte("18446744073709551615"), []byte("1e20"), []byte("12345678901234567.89"), nil, nil},
+		},
+	}
+	rs.markNil(1, 3)
+
+	js, err := json.Marshal(&rs)
+	require.NoError(t, err)
+	require.Equal(t, `{"columns":[{"name":"id","type":"BIGINT"},{"name":"v","type":"DOUBLE"},{"name":"d","type":"DECIMAL"},{"name":"v","type":"VARCHAR"},{"name":"b","type":"VARBINARY"}],`+
+		`"rows":[[1,2.5,3.14,"\"q\"","01ff"],["18446744073709551615",1e20,"12345678901234567.89",null,""]]}`, string(js))
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, rs.WriteJSON(buf, JSONOptions{Objects: true}))
+	require.Equal(t, `[{"id":1,"v":2.5,"d":3.14,"v_2":"\"q\"","b":"01ff"},{"id":"18446744073709551615","v":1e20,"d":"12345678901234567.89","v_2":null,"b":""}]`, buf.String())
+
+	rs2, err := FromJSON(js)
+	require.NoError(t, err)
+	require.Equal(t, rs.DataDigest(DigestOptions{}), rs2.DataDigest(DigestOptions{}))
+	require.NoError(t, Diff(&rs, rs2, DiffOptions{}))
+
+	exec := ResultSet{exec: ExecResult{RowsAffected: 2, HasRowsAffected: true}}
+	js, err = json.Marshal(&exec)
+	require.NoError(t, err)
+	require.Equal(t, `{"rows_affected":2,"last_insert_id":null}`, string(js))
+
+	_, err = FromJSON([]byte(`{"columns":[{"name":"a","type":"INT"}],"rows":[[1,2]]}`))
+	require.Error(t, err)
+}
+
+func TestUniqueNames(t *testing.T) {
+	cols := []ColumnDef{{Name: "a"}, {Name: "a_2"}, {Name: "a"}, {Name: "a"}}
+	require.Equal(t, []string{"a", "a_2", "a_3", "a_4"}, uniqueNames(cols))
+}
@@ -0,0 +1,78 @@
+package resultset
+
+import "fmt"
+
+// Null is the sentinel ToMaps uses for NULL cells when MapOptions.KeepNull is set, FromMaps treats it as NULL as
+// well.
+const Null = "\x00NULL\x00"
+
+type MapOptions struct {
+	// KeepNull stores NULL cells as Null instead of leaving their keys absent.
+	KeepNull bool
+}
+
+// ToMaps converts rows to maps keyed by column names. Duplicated names are suffixed with _2, _3 and so on.
+func (rs *ResultSet) ToMaps(opts ...MapOptions) []map[string]string {
+	if rs.IsExecResult() {
+		return nil
+	}
+	var o MapOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	names := uniqueNames(rs.cols)
+	out := make([]map[string]string, 0, len(rs.data))
+	rs.EachRow(func(i int, row Row) error {
+		m := make(map[string]string, len(names))
+		for j, name := range names {
+			v, _ := row.ByIndex(j)
+			if v != nil {
+				m[name] = string(v)
+			} else if o.KeepNull {
+				m[name] = Null
+			}
+		}
+		out = append(out, m)
+		return nil
+	})
+	return out
+}
+
+// FromMaps builds a result set with the given columns. Missing keys and Null values become NULL, while keys which
+// don't match any column are reported as errors. Only the first of columns sharing a name gets the value.
+func FromMaps(cols []ColumnInfo, rows []map[string]string) (*ResultSet, error) {
+	defs := make([]ColumnDef, len(cols))
+	index := make(map[string]int, len(cols))
+	for j, c := range cols {
+		defs[j] = ColumnDef{
+			Name:        c.Name,
+			Type:        c.Type,
+			Nullable:    c.Nullable,
+			HasNullable: c.HasNullable,
+			Unsigned:    c.Unsigned,
+			HasUnsigned: c.HasUnsigned,
+		}
+		if _, ok := index[c.Name]; !ok {
+			index[c.Name] = j
+		}
+	}
+	rs := New(defs)
+	for i, m := range rows {
+		for k := range m {
+			if _, ok := index[k]; !ok {
+				return nil, fmt.Errorf("unknown column %q at row %d", k, i)
+			}
+		}
+		row := make([][]byte, len(defs))
+		for j, c := range defs {
+			v, ok := m[c.Name]
+			if !ok || v == Null || index[c.Name] != j {
+				rs.markNil(i, j)
+				continue
+			}
+			row[j] = []byte(v)
+		}
+		rs.data = append(rs.data, row)
+	}
+	return rs, nil
+}
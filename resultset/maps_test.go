@@ -0,0 +1,57 @@
+package resultset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaps(t *testing.T) {
+	cols := []ColumnInfo{{Name: "id", Type: "INT"}, {Name: "v", Type: "VARCHAR"}}
+	rs, err := FromMaps(cols, []map[string]string{
+		{"id": "1", "v": ""},
+		{"id": "2"},
+		{"id": "3", "v": Null},
+		{"id": "4", "v": "x"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 4, rs.NRows())
+	v, ok := rs.RawValue(0, 1)
+	require.True(t, ok)
+	require.NotNil(t, v)
+	require.Empty(t, v)
+	for i := 1; i <= 2; i++ {
+		v, ok = rs.RawValue(i, 1)
+		require.True(t, ok)
+		require.Nil(t, v)
+	}
+
+	require.Equal(t, []map[string]string{
+		{"id": "1", "v": ""},
+		{"id": "2"},
+		{"id": "3"},
+		{"id": "4", "v": "x"},
+	}, rs.ToMaps())
+	kept := rs.ToMaps(MapOptions{KeepNull: true})
+	require.Equal(t, Null, kept[1]["v"])
+	require.Equal(t, "", kept[0]["v"])
+
+	rs2, err := FromMaps(cols, kept)
+	require.NoError(t, err)
+	require.Equal(t, rs.DataDigest(DigestOptions{}), rs2.DataDigest(DigestOptions{}))
+	require.NoError(t, Diff(rs, rs2, DiffOptions{}))
+
+	raw, err := rs.Encode()
+	require.NoError(t, err)
+	var rs3 ResultSet
+	require.NoError(t, rs3.Decode(raw))
+	require.Equal(t, kept, rs3.ToMaps(MapOptions{KeepNull: true}))
+
+	_, err = FromMaps(cols, []map[string]string{{"id": "1", "x": "2"}})
+	require.EqualError(t, err, `unknown column "x" at row 0`)
+
+	dup := New([]ColumnDef{{Name: "a"}, {Name: "a"}})
+	dup.data = [][][]byte{{[]byte("1"), []byte("2")}}
+	require.Equal(t, []map[string]string{{"a": "1", "a_2": "2"}}, dup.ToMaps())
+	require.Nil(t, (&ResultSet{exec: ExecResult{RowsAffected: 1}}).ToMaps())
+}
@@ -0,0 +1,349 @@
+package resultset
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/olekukonko/tablewriter"
+)
+
+type PrettyPrintOptions struct {
+	// MaxColWidth truncates longer cells with an ellipsis, zero means no limit.
+	MaxColWidth int
+	// MaxRows stops printing after the given number of rows and notes how many are left, zero means no limit.
+	MaxRows int
+	// NullString is shown for NULL cells, defaults to "NULL".
+	NullString string
+	// RowNumbers prepends a "#" column holding the 1-based row index.
+	RowNumbers bool
+	// BorderStyle is one of "ascii" (the default), "unicode" or "markdown".
+	BorderStyle string
+	// HexBinary shows cells of binary columns and cells that aren't valid UTF-8 as hex literals like 0x1A2B, values
+	// longer than maxHexBytes are cut and followed by their length.
+	HexBinary bool
+	// EscapeControl escapes control characters in cells, e.g. a newline is shown as \n.
+	EscapeControl bool
+}
+
+// maxHexBytes is the number of bytes shown by a hex literal before it's cut.
+const maxHexBytes = 32
+
+func (o *PrettyPrintOptions) fillDefaults() {
+	if len(o.NullString) == 0 {
+		o.NullString = "NULL"
+	}
+}
+
+func (rs *ResultSet) PrettyPrintTo(w io.Writer, opts PrettyPrintOptions) error {
+	opts.fillDefaults()
+	switch opts.BorderStyle {
+	case "", "ascii":
+		return rs.prettyPrintGrid(w, opts, asciiGrid)
+	case "unicode":
+		return rs.prettyPrintGrid(w, opts, unicodeGrid)
+	case "markdown":
+		return rs.PrettyPrintMarkdown(w, opts)
+	default:
+		return fmt.Errorf("unknown border style: %q", opts.BorderStyle)
+	}
+}
+
+// gridStyle describes how prettyPrintGrid draws a table.
+type gridStyle struct {
+	// rules are the top, middle and bottom rules, each of which is made up of the left, middle and right joints and the
+	// horizontal line.
+	rules [3][4]string
+	bar   string
+	// title formats column names, which are centered then. Column names are left-aligned as they are if it's nil.
+	title func(name string) string
+	// alignNumbers right-aligns cells that look like decimal numbers.
+	alignNumbers bool
+}
+
+var (
+	asciiGrid = gridStyle{
+		rules:        [3][4]string{{"+", "+", "+", "-"}, {"+", "+", "+", "-"}, {"+", "+", "+", "-"}},
+		bar:          "|",
+		title:        tablewriter.Title,
+		alignNumbers: true,
+	}
+	unicodeGrid = gridStyle{
+		rules: [3][4]string{{"┌", "┬", "┐", "─"}, {"├", "┼", "┤", "─"}, {"└", "┴", "┘", "─"}},
+		bar:   "│",
+	}
+	decimalText = regexp.MustCompile(`^-?(?:\d{1,3}(?:,\d{3})*|\d+)(?:\.\d+)?$`)
+)
+
+// prettyPrintGrid draws a table measured by DisplayWidth, so that it's aligned the same way regardless of the locale.
+func (rs *ResultSet) prettyPrintGrid(w io.Writer, opts PrettyPrintOptions, style gridStyle) error {
+	hdr, rows, more := rs.prettyCells(opts)
+	padHeader := padRight
+	if style.title != nil {
+		titles := make([]string, len(hdr))
+		for j, h := range hdr {
+			titles[j] = style.title(h)
+		}
+		hdr, padHeader = titles, padCenter
+	}
+	widths := make([]int, len(hdr))
+	measure := func(cells []string) {
+		for j, c := range cells {
+			for _, line := range strings.Split(c, "\n") {
+				if n := DisplayWidth(line); n > widths[j] {
+					widths[j] = n
+				}
+			}
+		}
+	}
+	measure(hdr)
+	for _, row := range rows {
+		measure(row)
+	}
+	b := new(strings.Builder)
+	writeRule := func(rule [4]string) {
+		b.WriteString(rule[0])
+		for j, n := range widths {
+			if j > 0 {
+				b.WriteString(rule[1])
+			}
+			b.WriteString(strings.Repeat(rule[3], n+2))
+		}
+		b.WriteString(rule[2] + "\n")
+	}
+	writeRow := func(cells []string, pad func(line string, width int) string) {
+		lines, height := make([][]string, len(cells)), 1
+		for j, c := range cells {
+			lines[j] = strings.Split(c, "\n")
+			if len(lines[j]) > height {
+				height = len(lines[j])
+			}
+		}
+		for k := 0; k < height; k++ {
+			b.WriteString(style.bar)
+			for j, n := range widths {
+				line := ""
+				if k < len(lines[j]) {
+					line = lines[j][k]
+				}
+				b.WriteString(" " + pad(line, n) + " " + style.bar)
+			}
+			b.WriteString("\n")
+		}
+	}
+	padCell := func(line string, width int) string {
+		if style.alignNumbers && decimalText.MatchString(strings.TrimSpace(line)) {
+			return strings.Repeat(" ", width-DisplayWidth(line)) + line
+		}
+		return padRight(line, width)
+	}
+	writeRule(style.rules[0])
+	writeRow(hdr, padHeader)
+	writeRule(style.rules[1])
+	for _, row := range rows {
+		writeRow(row, padCell)
+	}
+	writeRule(style.rules[2])
+	if more > 0 {
+		fmt.Fprintf(b, "... (%d more rows)\n", more)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func padRight(s string, width int) string {
+	if n := width - DisplayWidth(s); n > 0 {
+		return s + strings.Repeat(" ", n)
+	}
+	return s
+}
+
+func padCenter(s string, width int) string {
+	if n := width - DisplayWidth(s); n > 0 {
+		return strings.Repeat(" ", n/2) + s + strings.Repeat(" ", n-n/2)
+	}
+	return s
+}
+
+func (rs *ResultSet) PrettyPrintMarkdown(w io.Writer, opts ...PrettyPrintOptions) error {
+	var o PrettyPrintOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	o.fillDefaults()
+	hdr, rows, more := rs.prettyCells(o)
+	b := new(strings.Builder)
+	writeRow := func(cells []string) {
+		b.WriteString("|")
+		for _, c := range cells {
+			b.WriteString(" ")
+			b.WriteString(escapeMarkdown(c))
+			b.WriteString(" |")
+		}
+		b.WriteString("\n")
+	}
+	writeRow(hdr)
+	b.WriteString("|")
+	for range hdr {
+		b.WriteString(" --- |")
+	}
+	b.WriteString("\n")
+	for _, row := range rows {
+		writeRow(row)
+	}
+	if len(rows) == 0 && more == 0 {
+		b.WriteString("\n(0 rows)\n")
+	} else if more > 0 {
+		fmt.Fprintf(b, "\n... (%d more rows)\n", more)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (rs *ResultSet) prettyCells(opts PrettyPrintOptions) ([]string, [][]string, int) {
+	if rs.IsExecResult() {
+		row := []string{opts.NullString, opts.NullString}
+		if rs.exec.HasRowsAffected {
+			row[0] = strconv.FormatInt(rs.exec.RowsAffected, 10)
+		}
+		if rs.exec.HasLastInsertId {
+			row[1] = strconv.FormatInt(rs.exec.LastInsertId, 10)
+		}
+		return []string{"RowsAffected", "LastInsertId"}, [][]string{row}, 0
+	}
+	hdr := make([]string, len(rs.cols))
+	for j, c := range rs.cols {
+		hdr[j] = c.Name
+	}
+	n, more := len(rs.data), 0
+	if opts.MaxRows > 0 && n > opts.MaxRows {
+		n, more = opts.MaxRows, n-opts.MaxRows
+	}
+	off := 0
+	if opts.RowNumbers {
+		hdr, off = append([]string{"#"}, hdr...), 1
+	}
+	rows := make([][]string, n)
+	for i, r := range rs.data[:n] {
+		row := make([]string, off+len(r))
+		if opts.RowNumbers {
+			row[0] = strconv.Itoa(i + 1)
+		}
+		for j, v := range r {
+			if rs.isNil(i, j) {
+				row[off+j] = opts.NullString
+			} else {
+				row[off+j] = truncateText(opts.cellText(rs.cols[j].Type, v), opts.MaxColWidth)
+			}
+		}
+		rows[i] = row
+	}
+	return hdr, rows, more
+}
+
+func (o *PrettyPrintOptions) cellText(t string, v []byte) string {
+	if o.HexBinary && (isBinaryType(t) || !utf8.Valid(v)) {
+		if len(v) > maxHexBytes {
+			return fmt.Sprintf("0x%X... (%d bytes)", v[:maxHexBytes], len(v))
+		}
+		return fmt.Sprintf("0x%X", v)
+	}
+	if o.EscapeControl {
+		return escapeControl(string(v))
+	}
+	return string(v)
+}
+
+func escapeControl(s string) string {
+	if strings.IndexFunc(s, unicode.IsControl) < 0 {
+		return s
+	}
+	b := new(strings.Builder)
+	for _, r := range s {
+		switch {
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case unicode.IsControl(r):
+			fmt.Fprintf(b, `\x%02x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// displayWidth measures strings regardless of the locale, i.e. characters of ambiguous width are narrow.
+var displayWidth = &runewidth.Condition{}
+
+// DisplayWidth returns the number of terminal cells taken by s, East Asian wide characters take 2 cells while
+// combining marks and control characters take none.
+func DisplayWidth(s string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return displayWidth.StringWidth(s)
+		}
+	}
+	return len(s)
+}
+
+// truncateText cuts s to fit in width cells (see DisplayWidth) with a trailing ellipsis, which takes one cell.
+func truncateText(s string, width int) string {
+	if width <= 0 || DisplayWidth(s) <= width {
+		return s
+	}
+	b, n := new(strings.Builder), 0
+	for _, r := range s {
+		if n += displayWidth.RuneWidth(r); n > width-1 {
+			break
+		}
+		b.WriteRune(r)
+	}
+	return b.String() + "…"
+}
+
+var markdownEscaper = strings.NewReplacer("|", `\|`, "`", "\\`", "\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+
+func escapeMarkdown(s string) string { return markdownEscaper.Replace(s) }
+
+func (rs *ResultSet) PrettyPrintVertical(w io.Writer, opts ...PrettyPrintOptions) error {
+	var o PrettyPrintOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	o.fillDefaults()
+	// rows are already numbered by their separators
+	o.RowNumbers = false
+	hdr, rows, more := rs.prettyCells(o)
+	width := 0
+	for _, name := range hdr {
+		if n := DisplayWidth(name); n > width {
+			width = n
+		}
+	}
+	indent := strings.Repeat(" ", width+2)
+	b := new(strings.Builder)
+	for i, row := range rows {
+		fmt.Fprintf(b, "*************************** %d. row ***************************\n", i+1)
+		for j, v := range row {
+			b.WriteString(strings.Repeat(" ", width-DisplayWidth(hdr[j])))
+			b.WriteString(hdr[j])
+			b.WriteString(": ")
+			b.WriteString(strings.ReplaceAll(v, "\n", "\n"+indent))
+			b.WriteString("\n")
+		}
+	}
+	if more > 0 {
+		fmt.Fprintf(b, "... (%d more rows)\n", more)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,296 @@
+package resultset
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrettyPrintMarkdown(t *testing.T) {
+	rs := ResultSet{
+		cols: []ColumnDef{{Name: "id", Type: "INT"}, {Name: "a|b", Type: "TEXT"}},
+		data: [][][]byte{
+			{[]byte("1"), []byte("x|`y`")},
+			{[]byte("2"), nil},
+			{[]byte("3"), []byte("line1\nline2 is rather long")},
+		},
+	}
+	rs.markNil(1, 1)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, rs.PrettyPrintMarkdown(buf, PrettyPrintOptions{MaxColWidth: 12}))
+	require.Equal(t, strings.Join([]string{
+		`| id | a\|b |`,
+		`| --- | --- |`,
+		"| 1 | x\\|\\`y\\` |",
+		`| 2 | NULL |`,
+		`| 3 | line1<br>line2 … |`,
+		``,
+	}, "\n"), buf.String())
+
+	buf.Reset()
+	empty := ResultSet{cols: rs.cols}
+	require.NoError(t, empty.PrettyPrintMarkdown(buf))
+	require.Equal(t, "| id | a\\|b |\n| --- | --- |\n\n(0 rows)\n", buf.String())
+}
+
+func TestPrettyPrintVertical(t *testing.T) {
+	rs := ResultSet{
+		cols: []ColumnDef{{Name: "id", Type: "INT"}, {Name: "comment", Type: "TEXT"}},
+		data: [][][]byte{
+			{[]byte("1"), []byte("first\nsecond")},
+			{[]byte("2"), nil},
+		},
+	}
+	rs.markNil(1, 1)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, rs.PrettyPrintVertical(buf))
+	require.Equal(t, strings.Join([]string{
+		"*************************** 1. row ***************************",
+		"     id: 1",
+		"comment: first",
+		"         second",
+		"*************************** 2. row ***************************",
+		"     id: 2",
+		"comment: NULL",
+		"",
+	}, "\n"), buf.String())
+
+	buf.Reset()
+	empty := ResultSet{cols: rs.cols}
+	require.NoError(t, empty.PrettyPrintVertical(buf))
+	require.Empty(t, buf.String())
+}
+
+func TestPrettyPrintTo(t *testing.T) {
+	rs := ResultSet{
+		cols: []ColumnDef{{Name: "id", Type: "INT"}, {Name: "v", Type: "TEXT"}},
+		data: [][][]byte{
+			{[]byte("1"), []byte("中文字符很长")},
+			{[]byte("2"), nil},
+			{[]byte("3"), []byte("c")},
+		},
+	}
+	rs.markNil(1, 1)
+
+	buf := new(bytes.Buffer)
+	rs.PrettyPrint(buf)
+	require.Equal(t, strings.Join([]string{
+		"+----+--------------+",
+		"| ID |      V       |",
+		"+----+--------------+",
+		"|  1 | 中文字符很长 |",
+		"|  2 | NULL         |",
+		"|  3 | c            |",
+		"+----+--------------+",
+		"",
+	}, "\n"), buf.String())
+
+	buf.Reset()
+	require.NoError(t, rs.PrettyPrintTo(buf, PrettyPrintOptions{MaxColWidth: 3, MaxRows: 2, NullString: "<null>"}))
+	require.Equal(t, strings.Join([]string{
+		"+----+--------+",
+		"| ID |   V    |",
+		"+----+--------+",
+		"|  1 | 中…    |",
+		"|  2 | <null> |",
+		"+----+--------+",
+		"... (1 more rows)",
+		"",
+	}, "\n"), buf.String())
+	require.Equal(t, "中文字符很长", string(rs.data[0][1]))
+
+	buf.Reset()
+	require.NoError(t, rs.PrettyPrintTo(buf, PrettyPrintOptions{MaxRows: 2, RowNumbers: true}))
+	require.Equal(t, strings.Join([]string{
+		"+---+----+--------------+",
+		"| # | ID |      V       |",
+		"+---+----+--------------+",
+		"| 1 |  1 | 中文字符很长 |",
+		"| 2 |  2 | NULL         |",
+		"+---+----+--------------+",
+		"... (1 more rows)",
+		"",
+	}, "\n"), buf.String())
+}
+
+func TestPrettyPrintToBorderStyle(t *testing.T) {
+	rs := ResultSet{
+		cols: []ColumnDef{{Name: "id", Type: "INT"}, {Name: "v", Type: "TEXT"}},
+		data: [][][]byte{
+			{[]byte("1"), []byte("中文")},
+			{[]byte("2"), nil},
+			{[]byte("3"), []byte("a\nbc")},
+		},
+	}
+	rs.markNil(1, 1)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, rs.PrettyPrintTo(buf, PrettyPrintOptions{BorderStyle: "unicode", NullString: "∅"}))
+	require.Equal(t, strings.Join([]string{
+		"┌────┬──────┐",
+		"│ id │ v    │",
+		"├────┼──────┤",
+		"│ 1  │ 中文 │",
+		"│ 2  │ ∅    │",
+		"│ 3  │ a    │",
+		"│    │ bc   │",
+		"└────┴──────┘",
+		"",
+	}, "\n"), buf.String())
+
+	buf.Reset()
+	require.NoError(t, rs.PrettyPrintTo(buf, PrettyPrintOptions{BorderStyle: "markdown", MaxRows: 1}))
+	md := new(bytes.Buffer)
+	require.NoError(t, rs.PrettyPrintMarkdown(md, PrettyPrintOptions{MaxRows: 1}))
+	require.Equal(t, md.String(), buf.String())
+
+	ascii := new(bytes.Buffer)
+	rs.PrettyPrint(ascii)
+	buf.Reset()
+	require.NoError(t, rs.PrettyPrintTo(buf, PrettyPrintOptions{BorderStyle: "ascii"}))
+	require.Equal(t, ascii.String(), buf.String())
+
+	require.EqualError(t, rs.PrettyPrintTo(buf, PrettyPrintOptions{BorderStyle: "fancy"}), `unknown border style: "fancy"`)
+}
+
+func TestPrettyPrintCellOptions(t *testing.T) {
+	rs := ResultSet{
+		cols: []ColumnDef{{Name: "b", Type: "VARBINARY"}, {Name: "v", Type: "TEXT"}},
+		data: [][][]byte{
+			{[]byte{0x1a, 0x2b}, []byte("a\tb\nc\x00")},
+			{bytes.Repeat([]byte{0xff}, 40), []byte{0xfe, 0x01}},
+			{[]byte{}, nil},
+		},
+	}
+	rs.markNil(2, 1)
+	opts := PrettyPrintOptions{NullString: "∅", HexBinary: true, EscapeControl: true}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, rs.PrettyPrintMarkdown(buf, opts))
+	require.Equal(t, strings.Join([]string{
+		"| b | v |",
+		"| --- | --- |",
+		`| 0x1A2B | a\tb\nc\x00 |`,
+		"| 0x" + strings.Repeat("FF", 32) + "... (40 bytes) | 0xFE01 |",
+		"| 0x | ∅ |",
+		"",
+	}, "\n"), buf.String())
+
+	buf.Reset()
+	require.NoError(t, rs.PrettyPrintVertical(buf, opts))
+	require.Contains(t, buf.String(), "b: 0x1A2B\nv: a\\tb\\nc\\x00\n")
+	require.Contains(t, buf.String(), "v: ∅\n")
+
+	// cells are shown as they are by default
+	buf.Reset()
+	require.NoError(t, rs.PrettyPrintVertical(buf))
+	require.Contains(t, buf.String(), "v: a\tb\n   c\x00\n")
+}
+
+func TestPrettyPrintMixedWidth(t *testing.T) {
+	rs := ResultSet{
+		cols: []ColumnDef{{Name: "名称", Type: "VARCHAR"}, {Name: "v", Type: "TEXT"}},
+		data: [][][]byte{
+			{[]byte("abc"), []byte("中文")},
+			{[]byte("e\u0301"), []byte("😀x")},
+			{[]byte("ｶﾀｶﾅ"), []byte("한국어")},
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, rs.PrettyPrintTo(buf, PrettyPrintOptions{BorderStyle: "unicode"}))
+	require.Equal(t, strings.Join([]string{
+		"┌──────┬────────┐",
+		"│ 名称 │ v      │",
+		"├──────┼────────┤",
+		"│ abc  │ 中文   │",
+		"│ é    │ 😀x    │",
+		"│ ｶﾀｶﾅ │ 한국어 │",
+		"└──────┴────────┘",
+		"",
+	}, "\n"), buf.String())
+
+	buf.Reset()
+	rs.PrettyPrint(buf)
+	require.Equal(t, strings.Join([]string{
+		"+------+--------+",
+		"| 名称 |   V    |",
+		"+------+--------+",
+		"| abc  | 中文   |",
+		"| é    | 😀x    |",
+		"| ｶﾀｶﾅ | 한국어 |",
+		"+------+--------+",
+		"",
+	}, "\n"), buf.String())
+
+	buf.Reset()
+	require.NoError(t, rs.PrettyPrintVertical(buf))
+	require.Equal(t, strings.Join([]string{
+		"*************************** 1. row ***************************",
+		"名称: abc",
+		"   v: 中文",
+	}, "\n"), strings.Join(strings.Split(buf.String(), "\n")[:3], "\n"))
+}
+
+func TestPrettyPrintAmbiguousWidth(t *testing.T) {
+	rs := ResultSet{
+		cols: []ColumnDef{{Name: "v", Type: "TEXT"}},
+		data: [][][]byte{{[]byte("α±")}, {[]byte("abc")}},
+	}
+	ascii, unicode := new(bytes.Buffer), new(bytes.Buffer)
+	rs.PrettyPrint(ascii)
+	require.NoError(t, rs.PrettyPrintTo(unicode, PrettyPrintOptions{BorderStyle: "unicode"}))
+	require.Equal(t, strings.Join([]string{
+		"+-----+",
+		"|  V  |",
+		"+-----+",
+		"| α±  |",
+		"| abc |",
+		"+-----+",
+		"",
+	}, "\n"), ascii.String())
+	require.Equal(t, "│ α±  │", strings.Split(unicode.String(), "\n")[3])
+
+	// the locale doesn't matter
+	defer func(eastAsian bool) { runewidth.DefaultCondition.EastAsianWidth = eastAsian }(runewidth.DefaultCondition.EastAsianWidth)
+	runewidth.DefaultCondition.EastAsianWidth = true
+	buf := new(bytes.Buffer)
+	rs.PrettyPrint(buf)
+	require.Equal(t, ascii.String(), buf.String())
+}
+
+func TestTruncateText(t *testing.T) {
+	for _, s := range []string{"中文字符很长", "a中b文c", "ｶﾀｶﾅ", "e\u0301e\u0301e\u0301"} {
+		for width := 1; width <= 8; width++ {
+			out := truncateText(s, width)
+			require.LessOrEqual(t, DisplayWidth(out), width, "%q in %d cells: %q", s, width, out)
+			if DisplayWidth(s) <= width {
+				require.Equal(t, s, out)
+			}
+		}
+	}
+	require.Equal(t, "中文…", truncateText("中文字符很长", 6))
+	require.Equal(t, "中文…", truncateText("中文字符很长", 5))
+	require.Equal(t, "…", truncateText("中文字符很长", 1))
+	require.Equal(t, "e\u0301e\u0301", truncateText("e\u0301e\u0301", 2))
+}
+
+func TestDisplayWidth(t *testing.T) {
+	for s, n := range map[string]int{
+		"":          0,
+		"abc":       3,
+		"中文":        4,
+		"e\u0301":   1,
+		"😀":         2,
+		"ｶﾀｶﾅ":      4,
+		"a\x01b":    2,
+		"ab\u200bc": 3,
+	} {
+		require.Equal(t, n, DisplayWidth(s), s)
+	}
+}
@@ -2,20 +2,15 @@ package resultset
 
 import (
 	"bytes"
-	"compress/gzip"
-	"crypto/sha1"
 	"database/sql"
 	"encoding/binary"
-	"encoding/gob"
-	"encoding/hex"
 	"fmt"
 	"io"
 	"math"
+	"reflect"
 	"sort"
 	"strconv"
 	"unicode"
-
-	"github.com/olekukonko/tablewriter"
 )
 
 type Rows [][]interface{}
@@ -27,10 +22,12 @@ type ColumnDef struct {
 	Precision int64
 	Scale     int64
 	Nullable  bool
+	Unsigned  bool
 
 	HasNullable       bool
 	HasLength         bool
 	HasPrecisionScale bool
+	HasUnsigned       bool
 }
 
 type ExecResult struct {
@@ -46,6 +43,8 @@ type ResultSet struct {
 	data [][][]byte
 	nils []uint64
 	exec ExecResult
+
+	truncated bool
 }
 
 func New(schema []ColumnDef) *ResultSet {
@@ -62,7 +61,37 @@ func NewFromResult(res sql.Result) *ResultSet {
 	return rs
 }
 
+type ReadOptions struct {
+	// MaxBytes stops reading once the stored cell values (see ApproxBytes) would exceed the given size, the row
+	// exceeding the limit is dropped and the result is then marked as truncated. Zero means no limit.
+	MaxBytes int64
+	// MaxRows stops reading once the given number of rows are read and there are more, the result is then marked as
+	// truncated. Zero means no limit.
+	MaxRows int
+}
+
+// unsignedOf tells whether an integer column is unsigned by its scan type. Drivers like go-sql-driver/mysql scan NOT
+// NULL integers into sized integer types but nullable ones into sql.NullInt64 whatever their signedness, so the latter
+// is unknown.
+func unsignedOf(st reflect.Type) (bool, bool) {
+	if st == nil {
+		return false, false
+	}
+	switch st.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return false, true
+	default:
+		return false, false
+	}
+}
+
 func ReadFromRows(rows *sql.Rows) (*ResultSet, error) {
+	return ReadFromRowsWithOptions(rows, ReadOptions{})
+}
+
+func ReadFromRowsWithOptions(rows *sql.Rows, opts ReadOptions) (*ResultSet, error) {
 	types, err := rows.ColumnTypes()
 	if err != nil {
 		return nil, err
@@ -74,26 +103,46 @@ func ReadFromRows(rows *sql.Rows) (*ResultSet, error) {
 		cols[i].Nullable, cols[i].HasNullable = t.Nullable()
 		cols[i].Length, cols[i].HasLength = t.Length()
 		cols[i].Precision, cols[i].Scale, cols[i].HasPrecisionScale = t.DecimalSize()
+		cols[i].Unsigned, cols[i].HasUnsigned = unsignedOf(t.ScanType())
 	}
-	rs, i := New(cols), 0
+	rs, size := New(cols), int64(0)
 	for rows.Next() {
-		row := rs.AllocateRow()
-		if err = rows.Scan(row...); err != nil {
+		if opts.MaxRows > 0 && len(rs.data) >= opts.MaxRows {
+			rs.truncated = true
+			return rs, rows.Close()
+		}
+		row := make([][]byte, len(cols))
+		dest := make([]interface{}, len(cols))
+		for j := range row {
+			dest[j] = &row[j]
+		}
+		if err = rows.Scan(dest...); err != nil {
 			return rs, err
 		}
-		for j, col := range row {
-			if *col.(*[]byte) == nil {
+		n := int64(0)
+		for _, v := range row {
+			n += int64(len(v))
+		}
+		if opts.MaxBytes > 0 && size+n > opts.MaxBytes {
+			rs.truncated = true
+			return rs, rows.Close()
+		}
+		size += n
+		i := len(rs.data)
+		rs.data = append(rs.data, row)
+		for j, v := range row {
+			if v == nil {
 				rs.markNil(i, j)
 			}
 		}
-		i += 1
 	}
 	return rs, rows.Err()
 }
 
 func (rs *ResultSet) String() string {
 	if rs.IsExecResult() {
-		return strconv.FormatInt(rs.ExecResult().RowsAffected, 10) + " rows affected"
+		n, _ := rs.RowsAffected()
+		return strconv.FormatInt(n, 10) + " rows affected"
 	}
 	if rs.NRows() == 0 {
 		return "empty set"
@@ -105,8 +154,40 @@ func (rs *ResultSet) IsExecResult() bool { return len(rs.cols) == 0 }
 
 func (rs *ResultSet) ExecResult() ExecResult { return rs.exec }
 
+// RowsAffected returns the number of rows affected by an exec result, ok is false if the driver doesn't support it or
+// it's not an exec result.
+func (rs *ResultSet) RowsAffected() (n int64, ok bool) {
+	if !rs.IsExecResult() {
+		return 0, false
+	}
+	return rs.exec.RowsAffected, rs.exec.HasRowsAffected
+}
+
+// LastInsertId returns the last insert id of an exec result, ok is false if the driver doesn't support it or it's not
+// an exec result.
+func (rs *ResultSet) LastInsertId() (id int64, ok bool) {
+	if !rs.IsExecResult() {
+		return 0, false
+	}
+	return rs.exec.LastInsertId, rs.exec.HasLastInsertId
+}
+
+func (rs *ResultSet) Truncated() bool { return rs.truncated }
+
 func (rs *ResultSet) NRows() int { return len(rs.data) }
 
+// ApproxBytes returns the total size of cell values, which is what ReadOptions.MaxBytes limits. Memory used by the
+// result set is a bit more than that.
+func (rs *ResultSet) ApproxBytes() int64 {
+	n := int64(0)
+	for _, row := range rs.data {
+		for _, v := range row {
+			n += int64(len(v))
+		}
+	}
+	return n
+}
+
 func (rs *ResultSet) NCols() int { return len(rs.cols) }
 
 func (rs *ResultSet) ColumnDef(i int) ColumnDef {
@@ -119,7 +200,29 @@ func (rs *ResultSet) ColumnDef(i int) ColumnDef {
 	return rs.cols[i]
 }
 
-func (rs *ResultSet) Sort(less func(r1 int, r2 int) bool) { sort.SliceStable(rs.data, less) }
+func (rs *ResultSet) RenameColumn(from string, to string) *ResultSet {
+	return rs.RenameColumns(map[string]string{from: to})
+}
+
+func (rs *ResultSet) RenameColumns(mapping map[string]string) *ResultSet {
+	out := rs.clone()
+	for j, c := range out.cols {
+		if name, ok := mapping[c.Name]; ok {
+			out.cols[j].Name = name
+		}
+	}
+	return out
+}
+
+// Sort sorts rows stably, less is called with the indices of rows as they are before sorting.
+func (rs *ResultSet) Sort(less func(r1 int, r2 int) bool) {
+	perm := make([]int, len(rs.data))
+	for i := range perm {
+		perm[i] = i
+	}
+	sort.SliceStable(perm, func(i int, j int) bool { return less(perm[i], perm[j]) })
+	rs.permute(perm)
+}
 
 func (rs *ResultSet) RawValue(i int, j int) ([]byte, bool) {
 	if i < 0 {
@@ -159,10 +262,26 @@ func (rs *ResultSet) DataDigest(opts DigestOptions) string {
 	if rs.IsExecResult() {
 		return ""
 	}
+	if opts.ShapeOnly {
+		return rs.shapeDigest(opts)
+	}
+	if len(opts.SortKeys) > 0 && !opts.Sort {
+		sorted := rs.clone()
+		if err := sorted.SortBy(opts.SortKeys...); err == nil {
+			opts.SortKeys = nil
+			return sorted.DataDigest(opts)
+		}
+	}
+	if opts.JSONSemantic {
+		opts.Mapper = jsonSemanticMapper(opts.Mapper)
+	}
 	if opts.Sort {
 		return rs.sortedDigest(opts)
 	}
-	h := sha1.New()
+	h := opts.Hash.New()
+	if opts.CompareTypes {
+		rs.encodeTypesTo(h)
+	}
 	for i, row := range rs.data {
 		for j, v := range row {
 			if opts.Filter != nil && !opts.Filter(i, j, v, rs.cols[j]) {
@@ -171,29 +290,61 @@ func (rs *ResultSet) DataDigest(opts DigestOptions) string {
 			_ = rs.encodeCellTo(h, i, j, opts.Mapper)
 		}
 	}
-	return hex.EncodeToString(h.Sum(nil))
+	rs.encodeTruncatedTo(h)
+	return opts.Hash.Format(h.Sum(nil))
 }
 
 func (rs *ResultSet) sortedDigest(opts DigestOptions) string {
 	digests := make([][]byte, rs.NRows())
-	for i, row := range rs.data {
-		h := sha1.New()
-		for j, v := range row {
-			if opts.Filter != nil && !opts.Filter(i, j, v, rs.cols[j]) {
-				continue
-			}
-			_ = rs.encodeCellTo(h, i, j, opts.Mapper)
-		}
-		digests[i] = h.Sum(nil)
+	for i := range rs.data {
+		digests[i] = rs.rowDigest(i, opts)
 	}
 	sort.Slice(digests, func(i, j int) bool {
 		return bytes.Compare(digests[i], digests[j]) < 0
 	})
-	h := sha1.New()
+	h := opts.Hash.New()
+	if opts.CompareTypes {
+		rs.encodeTypesTo(h)
+	}
 	for _, digest := range digests {
 		h.Write(digest)
 	}
-	return hex.EncodeToString(h.Sum(nil))
+	rs.encodeTruncatedTo(h)
+	return opts.Hash.Format(h.Sum(nil))
+}
+
+func (rs *ResultSet) shapeDigest(opts DigestOptions) string {
+	h := opts.Hash.New()
+	if opts.CompareTypes {
+		rs.encodeTypesTo(h)
+	}
+	fmt.Fprintf(h, "%d:%d;", len(rs.data), len(rs.cols))
+	for _, c := range rs.cols {
+		fmt.Fprintf(h, "%d:%s;", len(c.Name), c.Name)
+	}
+	rs.encodeTruncatedTo(h)
+	return opts.Hash.Format(h.Sum(nil))
+}
+
+// truncatedMarker follows digested cells of a truncated result, so that it never digests the same as a complete one.
+// It reads as a NULL cell of 2GiB, which never happens.
+var truncatedMarker = []byte{0xff, 0xff, 0xff, 0xff}
+
+func (rs *ResultSet) encodeTruncatedTo(w io.Writer) {
+	if rs.truncated {
+		w.Write(truncatedMarker)
+	}
+}
+
+func (rs *ResultSet) rowDigest(i int, opts DigestOptions) []byte {
+	h := opts.Hash.New()
+	for j, v := range rs.data[i] {
+		if opts.Filter != nil && !opts.Filter(i, j, v, rs.cols[j]) {
+			continue
+		}
+		_ = rs.encodeCellTo(h, i, j, opts.Mapper)
+	}
+	return h.Sum(nil)
 }
 
 func (rs *ResultSet) AssertData(expect Rows, onErr ...func(act *ResultSet, exp Rows, err error)) (err error) {
@@ -257,82 +408,21 @@ func (rs *ResultSet) AssertData(expect Rows, onErr ...func(act *ResultSet, exp R
 }
 
 func (rs *ResultSet) PrettyPrint(out io.Writer) {
-	table := tablewriter.NewWriter(out)
-	table.SetAutoWrapText(false)
-	if rs.IsExecResult() {
-		table.SetHeader([]string{"RowsAffected", "LastInsertId"})
-		row := []string{"NULL", "NULL"}
-		if rs.exec.HasRowsAffected {
-			row[0] = strconv.FormatInt(rs.exec.RowsAffected, 10)
-		}
-		if rs.exec.HasLastInsertId {
-			row[1] = strconv.FormatInt(rs.exec.LastInsertId, 10)
-		}
-		table.Append(row)
-		table.Render()
-		return
-	}
-	hdr := make([]string, len(rs.cols))
-	for i, c := range rs.cols {
-		hdr[i] = c.Name
-	}
-	table.SetHeader(hdr)
-	for i, r := range rs.data {
-		row := make([]string, len(r))
-		for j, s := range r {
-			if rs.isNil(i, j) {
-				row[j] = "NULL"
-			} else {
-				row[j] = string(s)
-			}
-		}
-		table.Append(row)
-	}
-	table.Render()
-}
-
-func (rs *ResultSet) Encode() ([]byte, error) {
-	buf := new(bytes.Buffer)
-	if err := rs.EncodeTo(buf); err != nil {
-		return nil, err
-	}
-	return buf.Bytes(), nil
-}
-
-func (rs *ResultSet) EncodeTo(w io.Writer) error {
-	zw := gzip.NewWriter(w)
-	defer zw.Close()
-	enc := gob.NewEncoder(zw)
-	tmp := struct {
-		Cols []ColumnDef
-		Data [][][]byte
-		Nils []uint64
-		Exec ExecResult
-	}{rs.cols, rs.data, rs.nils, rs.exec}
-	return enc.Encode(tmp)
-}
-
-func (rs *ResultSet) Decode(raw []byte) error {
-	return rs.DecodeFrom(bytes.NewReader(raw))
+	_ = rs.PrettyPrintTo(out, PrettyPrintOptions{})
 }
 
-func (rs *ResultSet) DecodeFrom(r io.Reader) error {
-	zr, err := gzip.NewReader(r)
-	if err != nil {
-		return err
+func (rs *ResultSet) clone() *ResultSet {
+	out := &ResultSet{exec: rs.exec, truncated: rs.truncated}
+	if rs.cols != nil {
+		out.cols = append([]ColumnDef{}, rs.cols...)
 	}
-	dec := gob.NewDecoder(zr)
-	var tmp struct {
-		Cols []ColumnDef
-		Data [][][]byte
-		Nils []uint64
-		Exec ExecResult
+	if rs.data != nil {
+		out.data = append([][][]byte{}, rs.data...)
 	}
-	if err := dec.Decode(&tmp); err != nil {
-		return err
+	if rs.nils != nil {
+		out.nils = append([]uint64{}, rs.nils...)
 	}
-	rs.cols, rs.data, rs.nils, rs.exec = tmp.Cols, tmp.Data, tmp.Nils, tmp.Exec
-	return nil
+	return out
 }
 
 func (rs *ResultSet) markNil(i int, j int) {
@@ -376,6 +466,32 @@ type DigestOptions struct {
 	Sort   bool
 	Filter func(i int, j int, raw []byte, def ColumnDef) bool
 	Mapper func(i int, j int, raw []byte, def ColumnDef) []byte
+	// SortKeys orders rows by SortBy before digesting, it's ignored if Sort is set. Keys that cannot be resolved
+	// against the columns of a result set leave its rows in the original order, see Validate.
+	SortKeys []SortKey
+	// JSONSemantic canonicalizes values of JSON columns (sorted keys, compact, normalized numbers) before they are
+	// passed to Mapper and digested.
+	JSONSemantic bool
+	// Hash selects the digest algorithm, it defaults to DigestSHA1.
+	Hash DigestAlgorithm
+	// CompareTypes takes declared column types into account: they are digested along with the data, and comparisons
+	// (e.g. CompareResultSets) report type mismatches before data mismatches.
+	CompareTypes bool
+	// ShapeOnly digests the numbers of rows and columns along with column names only, cell values are ignored.
+	ShapeOnly bool
+}
+
+// Validate reports options that cannot be honoured when digesting rs: an unknown Hash or SortKeys that cannot be
+// resolved against its columns.
+func (opts DigestOptions) Validate(rs *ResultSet) error {
+	if !opts.Hash.Valid() {
+		return fmt.Errorf("unknown digest algorithm: %s", opts.Hash)
+	}
+	if opts.Sort || opts.ShapeOnly || rs.IsExecResult() || len(opts.SortKeys) == 0 {
+		return nil
+	}
+	_, err := rs.resolveSortKeys(opts.SortKeys)
+	return err
 }
 
 type Cell interface {
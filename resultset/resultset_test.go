@@ -1,10 +1,15 @@
 package resultset
 
 import (
+	"bytes"
+	"compress/gzip"
 	"database/sql"
 	"encoding/base64"
+	"encoding/gob"
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"strconv"
 	"testing"
 
@@ -32,18 +37,18 @@ func testDB(t *testing.T) *sql.DB {
 }
 
 var rss = []ResultSet{
-	{nil, nil, nil, ExecResult{0, 0, false, false}},
-	{[]ColumnDef{}, nil, nil, ExecResult{1, 0, true, false}},
-	{[]ColumnDef{
+	{exec: ExecResult{0, 0, false, false}},
+	{cols: []ColumnDef{}, exec: ExecResult{1, 0, true, false}},
+	{cols: []ColumnDef{
 		{Name: "foo", Type: "TEXT"},
-	}, nil, nil, ExecResult{0, 1, false, true}},
-	{[]ColumnDef{
+	}, exec: ExecResult{0, 1, false, true}},
+	{cols: []ColumnDef{
 		{Name: "foo", Type: "TEXT"},
-	}, [][][]byte{
+	}, data: [][][]byte{
 		{{0x1}},
 		{nil},
 		{{}},
-	}, []uint64{2}, ExecResult{1, 1, true, true}},
+	}, nils: []uint64{2}, exec: ExecResult{1, 1, true, true}},
 }
 
 func TestAssertDataNil(t *testing.T) {
@@ -173,6 +178,49 @@ func TestEncodeDecodeWithMySQLDataSource(t *testing.T) {
 	}
 }
 
+func TestReadFromRowsUnsignedWithMySQLDataSource(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	rows, err := db.Query("SELECT CAST(1 AS UNSIGNED) AS u, CAST(1 AS SIGNED) AS s, 'x' AS t")
+	require.NoError(t, err)
+	rs, err := ReadFromRows(rows)
+	require.NoError(t, err)
+	cols := rs.Columns()
+	require.True(t, cols[0].HasUnsigned && cols[0].Unsigned)
+	require.True(t, cols[1].HasUnsigned && !cols[1].Unsigned)
+	require.False(t, cols[2].HasUnsigned)
+}
+
+func TestReadFromRowsWithMySQLDataSource(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	read := func(opts ReadOptions) *ResultSet {
+		rows, err := db.Query("SELECT 'aa' AS v UNION ALL SELECT 'bbb' UNION ALL SELECT NULL UNION ALL SELECT 'c'")
+		require.NoError(t, err)
+		rs, err := ReadFromRowsWithOptions(rows, opts)
+		require.NoError(t, err)
+		return rs
+	}
+	for _, tt := range []struct {
+		opts      ReadOptions
+		rows      int
+		truncated bool
+	}{
+		{ReadOptions{}, 4, false},
+		{ReadOptions{MaxBytes: 6}, 4, false},
+		{ReadOptions{MaxBytes: 5}, 3, true},
+		{ReadOptions{MaxBytes: 4}, 1, true},
+		{ReadOptions{MaxBytes: 1}, 0, true},
+		{ReadOptions{MaxBytes: 5, MaxRows: 2}, 2, true},
+		{ReadOptions{MaxRows: 4}, 4, false},
+	} {
+		rs := read(tt.opts)
+		require.Equal(t, tt.rows, rs.NRows(), "%+v", tt.opts)
+		require.Equal(t, tt.truncated, rs.Truncated(), "%+v", tt.opts)
+		require.LessOrEqual(t, rs.ApproxBytes(), int64(6))
+	}
+}
+
 func TestFloatCell(t *testing.T) {
 	type EqTest struct {
 		raw string
@@ -231,3 +279,170 @@ func tEncodeDecodeCheck(rs1 *ResultSet) func(t *testing.T) {
 		}
 	}
 }
+
+func TestRenameColumns(t *testing.T) {
+	rs := &ResultSet{
+		cols: []ColumnDef{{Name: "a", Type: "INT"}, {Name: "b", Type: "INT"}},
+		data: [][][]byte{{[]byte("1"), []byte("2")}},
+	}
+	rs2 := rs.RenameColumn("a", "x")
+	rs3 := rs.RenameColumns(map[string]string{"a": "b", "b": "a"})
+	require.Equal(t, "a", rs.ColumnDef(0).Name)
+	require.Equal(t, "x", rs2.ColumnDef(0).Name)
+	require.Equal(t, "b", rs2.ColumnDef(1).Name)
+	require.Equal(t, []string{"b", "a"}, []string{rs3.ColumnDef(0).Name, rs3.ColumnDef(1).Name})
+	require.Equal(t, rs.DataDigest(DigestOptions{}), rs2.DataDigest(DigestOptions{}))
+
+	rs2.data[0] = nil
+	v, _ := rs.RawValue(0, 0)
+	require.Equal(t, "1", string(v))
+}
+
+func syntheticResultSet(n int) *ResultSet {
+	rs := New([]ColumnDef{{Name: "id", Type: "BIGINT"}, {Name: "v", Type: "VARCHAR"}})
+	rs.data = make([][][]byte, n)
+	for i := range rs.data {
+		rs.data[i] = [][]byte{[]byte(strconv.Itoa(i)), []byte("value-" + strconv.Itoa(i%100))}
+		if i%7 == 0 {
+			rs.data[i][1] = nil
+			rs.markNil(i, 1)
+		}
+	}
+	return rs
+}
+
+func TestApproxBytes(t *testing.T) {
+	rs, err := FromJSON([]byte(`{"columns":[{"name":"a","type":"INT"},{"name":"b","type":"VARCHAR"}],"rows":[[1,"abc"],[22,null]]}`))
+	require.NoError(t, err)
+	require.Equal(t, int64(6), rs.ApproxBytes())
+	require.Zero(t, New(nil).ApproxBytes())
+}
+
+func TestEncodeDecodeChunked(t *testing.T) {
+	for _, n := range []int{0, 1, encodeChunkRows, encodeChunkRows*3 + 5} {
+		t.Run("Rows#"+strconv.Itoa(n), tEncodeDecodeCheck(syntheticResultSet(n)))
+	}
+
+	// payloads encoded as a single value are still readable
+	rs := syntheticResultSet(10)
+	buf := new(bytes.Buffer)
+	zw := gzip.NewWriter(buf)
+	require.NoError(t, gob.NewEncoder(zw).Encode(struct {
+		Cols []ColumnDef
+		Data [][][]byte
+		Nils []uint64
+		Exec ExecResult
+	}{rs.cols, rs.data, rs.nils, rs.exec}))
+	require.NoError(t, zw.Close())
+	var legacy ResultSet
+	require.NoError(t, legacy.Decode(buf.Bytes()))
+	require.Equal(t, rs.DataDigest(DigestOptions{}), legacy.DataDigest(DigestOptions{}))
+	require.True(t, legacy.isNil(7, 1))
+
+	raw, err := syntheticResultSet(encodeChunkRows + 1).Encode()
+	require.NoError(t, err)
+	require.Error(t, legacy.Decode(raw[:len(raw)/2]))
+}
+
+func BenchmarkEncode1M(b *testing.B) {
+	rs := syntheticResultSet(1000000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := rs.Encode(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeTo1M(b *testing.B) {
+	rs := syntheticResultSet(1000000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := rs.EncodeTo(ioutil.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeFrom1M(b *testing.B) {
+	raw, err := syntheticResultSet(1000000).Encode()
+	require.NoError(b, err)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var rs ResultSet
+		if err := rs.DecodeFrom(bytes.NewReader(raw)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeHeader1M(b *testing.B) {
+	raw, err := syntheticResultSet(1000000).Encode()
+	require.NoError(b, err)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, _, err := DecodeHeader(raw); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type sqlResult struct {
+	affected int64
+	id       int64
+}
+
+func (r sqlResult) RowsAffected() (int64, error) {
+	if r.affected < 0 {
+		return 0, errors.New("unsupported")
+	}
+	return r.affected, nil
+}
+
+func (r sqlResult) LastInsertId() (int64, error) {
+	if r.id < 0 {
+		return 0, errors.New("unsupported")
+	}
+	return r.id, nil
+}
+
+func TestExecResultAccessors(t *testing.T) {
+	for _, tt := range []struct {
+		res      sqlResult
+		affected bool
+		id       bool
+	}{
+		{sqlResult{3, 42}, true, true},
+		{sqlResult{3, -1}, true, false},
+		{sqlResult{-1, 42}, false, true},
+	} {
+		rs := NewFromResult(tt.res)
+		raw, err := rs.Encode()
+		require.NoError(t, err)
+		var decoded ResultSet
+		require.NoError(t, decoded.Decode(raw))
+		for _, x := range []*ResultSet{rs, &decoded} {
+			n, ok := x.RowsAffected()
+			require.Equal(t, tt.affected, ok)
+			if ok {
+				require.Equal(t, tt.res.affected, n)
+			}
+			id, ok := x.LastInsertId()
+			require.Equal(t, tt.id, ok)
+			if ok {
+				require.Equal(t, tt.res.id, id)
+			}
+		}
+	}
+	require.Equal(t, "3 rows affected", NewFromResult(sqlResult{3, -1}).String())
+
+	query := syntheticResultSet(1)
+	_, ok := query.RowsAffected()
+	require.False(t, ok)
+	_, ok = query.LastInsertId()
+	require.False(t, ok)
+}
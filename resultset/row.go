@@ -0,0 +1,101 @@
+package resultset
+
+import "errors"
+
+var errStop = errors.New("stop")
+
+// Row is a view of a row in a result set. Values it returns share the underlying storage and must not be modified.
+type Row struct {
+	rs *ResultSet
+	i  int
+}
+
+func (r Row) Len() int { return len(r.rs.data[r.i]) }
+
+// ByIndex returns the raw value of j-th cell like ResultSet.RawValue, a NULL results in a nil slice.
+func (r Row) ByIndex(j int) ([]byte, bool) { return r.rs.RawValue(r.i, j) }
+
+// ByName returns the raw value of the first column named name (case-insensitively).
+func (r Row) ByName(name string) ([]byte, bool) { return r.rs.RawValueByName(r.i, name) }
+
+func (r Row) IsNull(j int) bool {
+	v, ok := r.ByIndex(j)
+	return ok && v == nil
+}
+
+// Values returns a copy of all cells in the row.
+func (r Row) Values() [][]byte {
+	out := make([][]byte, r.Len())
+	for j := range out {
+		if v, _ := r.ByIndex(j); v != nil {
+			out[j] = append([]byte{}, v...)
+		}
+	}
+	return out
+}
+
+// EachRow calls fn for every row in order and stops at the first error, which is then returned.
+func (rs *ResultSet) EachRow(fn func(i int, row Row) error) error {
+	for i := range rs.data {
+		if err := fn(i, Row{rs, i}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Filter returns a new result set with copies of the rows satisfying pred, the columns are kept even if no row
+// matches.
+func (rs *ResultSet) Filter(pred func(row Row) bool) *ResultSet {
+	if rs.IsExecResult() {
+		return rs.clone()
+	}
+	var picked []int
+	rs.EachRow(func(i int, row Row) error {
+		if pred(row) {
+			picked = append(picked, i)
+		}
+		return nil
+	})
+	return rs.copyRows(picked...)
+}
+
+// FilterRows is like Filter but passes pred the original index of a row along with its values as strings, NULL cells
+// are passed as Null. The values slice is reused between calls.
+func (rs *ResultSet) FilterRows(pred func(row int, values []string) bool) *ResultSet {
+	values := make([]string, len(rs.cols))
+	return rs.Filter(func(row Row) bool {
+		for j := range values {
+			if v, _ := row.ByIndex(j); v == nil {
+				values[j] = Null
+			} else {
+				values[j] = string(v)
+			}
+		}
+		return pred(row.i, values)
+	})
+}
+
+// Contains reports whether there is a row whose columns have the given values, Null matches NULL only.
+func (rs *ResultSet) Contains(match map[string]string) bool {
+	idx := make(map[int]string, len(match))
+	for name, v := range match {
+		j, ok := rs.ColumnIndex(name)
+		if !ok {
+			return false
+		}
+		idx[j] = v
+	}
+	found := false
+	rs.EachRow(func(i int, row Row) error {
+		for j, v := range idx {
+			raw, _ := row.ByIndex(j)
+			if (raw == nil) != (v == Null) || (raw != nil && string(raw) != v) {
+				return nil
+			}
+		}
+		found = true
+		return errStop
+	})
+	return found
+}
@@ -0,0 +1,101 @@
+package resultset
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEachRow(t *testing.T) {
+	rs, err := FromJSON([]byte(`{"columns":[{"name":"id","type":"INT"},{"name":"v","type":"VARCHAR"}],
+		"rows":[[1,"a"],[2,null],[3,"c"]]}`))
+	require.NoError(t, err)
+
+	var ids, vs []string
+	require.NoError(t, rs.EachRow(func(i int, row Row) error {
+		require.Equal(t, 2, row.Len())
+		id, ok := row.ByIndex(0)
+		require.True(t, ok)
+		ids = append(ids, string(id))
+		v, ok := row.ByName("V")
+		require.True(t, ok)
+		require.Equal(t, v == nil, row.IsNull(1))
+		vs = append(vs, string(v))
+		return nil
+	}))
+	require.Equal(t, []string{"1", "2", "3"}, ids)
+	require.Equal(t, []string{"a", "", "c"}, vs)
+
+	stop := errors.New("stop")
+	n := 0
+	require.Equal(t, stop, rs.EachRow(func(i int, row Row) error {
+		n++
+		if i == 1 {
+			return stop
+		}
+		return nil
+	}))
+	require.Equal(t, 2, n)
+
+	rs.EachRow(func(i int, row Row) error {
+		vals := row.Values()
+		if i == 1 {
+			require.Nil(t, vals[1])
+		}
+		vals[0][0] = 'x'
+		return nil
+	})
+	v, _ := rs.RawValue(0, 0)
+	require.Equal(t, "1", string(v))
+}
+
+func TestFilterContains(t *testing.T) {
+	rs, err := FromJSON([]byte(`{"columns":[{"name":"id","type":"INT"},{"name":"status","type":"VARCHAR"}],
+		"rows":[[1,"ok"],[2,"failed"],[3,null],[4,"failed"]]}`))
+	require.NoError(t, err)
+
+	failed := rs.Filter(func(row Row) bool {
+		v, _ := row.ByName("status")
+		return string(v) == "failed"
+	})
+	require.Equal(t, 2, failed.NRows())
+	require.Equal(t, rs.Columns(), failed.Columns())
+	v, _ := failed.RawValue(1, 0)
+	require.Equal(t, "4", string(v))
+	failed.data[0][1][0] = 'F'
+	v, _ = rs.RawValue(1, 1)
+	require.Equal(t, "failed", string(v))
+
+	nulls := rs.Filter(func(row Row) bool { return row.IsNull(1) })
+	require.Equal(t, 1, nulls.NRows())
+	require.True(t, nulls.isNil(0, 1))
+	require.False(t, nulls.isNil(0, 0))
+
+	none := rs.Filter(func(row Row) bool { return false })
+	require.Equal(t, 0, none.NRows())
+	require.Equal(t, 2, none.NCols())
+	require.False(t, none.IsExecResult())
+
+	var seen []int
+	even := rs.FilterRows(func(i int, values []string) bool {
+		seen = append(seen, i)
+		return values[1] == Null || i%2 == 1
+	})
+	require.Equal(t, []int{0, 1, 2, 3}, seen)
+	require.Equal(t, rs.Columns(), even.Columns())
+	require.Equal(t, 3, even.NRows())
+	v, _ = even.RawValue(2, 0)
+	require.Equal(t, "4", string(v))
+	require.True(t, even.isNil(1, 1))
+
+	require.True(t, rs.Contains(map[string]string{"status": "failed"}))
+	require.True(t, rs.Contains(map[string]string{"ID": "2", "status": "failed"}))
+	require.False(t, rs.Contains(map[string]string{"id": "1", "status": "failed"}))
+	require.True(t, rs.Contains(map[string]string{"status": Null}))
+	require.True(t, rs.Contains(map[string]string{"id": "3", "status": Null}))
+	require.False(t, rs.Contains(map[string]string{"id": "3", "status": ""}))
+	require.False(t, rs.Contains(map[string]string{"nope": "1"}))
+	require.True(t, rs.Contains(nil))
+	require.False(t, none.Contains(nil))
+}
@@ -0,0 +1,77 @@
+package resultset
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// Sample returns a new result set with n rows chosen at random (by reservoir sampling) in their original order, the
+// same seed always chooses the same rows. All rows are kept if there are no more than n rows.
+func (rs *ResultSet) Sample(n int, seed int64) *ResultSet {
+	if n >= len(rs.data) {
+		return rs.copyRange(0, len(rs.data))
+	}
+	if n < 0 {
+		n = 0
+	}
+	rng := rand.New(rand.NewSource(seed))
+	picked := make([]int, n)
+	for i := range rs.data {
+		if i < n {
+			picked[i] = i
+		} else if k := rng.Intn(i + 1); k < n {
+			picked[k] = i
+		}
+	}
+	sort.Ints(picked)
+	return rs.copyRows(picked...)
+}
+
+// Head returns a new result set with the first n rows.
+func (rs *ResultSet) Head(n int) *ResultSet {
+	return rs.copyRange(0, n)
+}
+
+// Tail returns a new result set with the last n rows.
+func (rs *ResultSet) Tail(n int) *ResultSet {
+	return rs.copyRange(len(rs.data)-n, len(rs.data))
+}
+
+func (rs *ResultSet) copyRange(from int, to int) *ResultSet {
+	if from < 0 {
+		from = 0
+	}
+	if to > len(rs.data) {
+		to = len(rs.data)
+	}
+	if to < from {
+		to = from
+	}
+	idx := make([]int, 0, to-from)
+	for i := from; i < to; i++ {
+		idx = append(idx, i)
+	}
+	return rs.copyRows(idx...)
+}
+
+// copyRows returns a new result set holding copies of the given rows, which shares nothing with rs.
+func (rs *ResultSet) copyRows(rows ...int) *ResultSet {
+	out := &ResultSet{exec: rs.exec, truncated: rs.truncated}
+	if rs.IsExecResult() {
+		return out
+	}
+	out.cols = append([]ColumnDef{}, rs.cols...)
+	out.data = make([][][]byte, len(rows))
+	for k, i := range rows {
+		row := make([][]byte, len(rs.data[i]))
+		for j, v := range rs.data[i] {
+			if rs.isNil(i, j) {
+				out.markNil(k, j)
+			} else {
+				row[j] = append([]byte{}, v...)
+			}
+		}
+		out.data[k] = row
+	}
+	return out
+}
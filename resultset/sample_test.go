@@ -0,0 +1,58 @@
+package resultset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSample(t *testing.T) {
+	rs := syntheticResultSet(100)
+
+	s1, s2 := rs.Sample(10, 42), rs.Sample(10, 42)
+	require.Equal(t, 10, s1.NRows())
+	require.Equal(t, rs.Columns(), s1.Columns())
+	require.Equal(t, s1.DataDigest(DigestOptions{}), s2.DataDigest(DigestOptions{}))
+	require.NotEqual(t, s1.DataDigest(DigestOptions{}), rs.Sample(10, 7).DataDigest(DigestOptions{}))
+	// rows are kept in their original order
+	prev := int64(-1)
+	for i := 0; i < s1.NRows(); i++ {
+		v, _, err := s1.GetInt64(i, 0)
+		require.NoError(t, err)
+		require.Greater(t, v, prev)
+		prev = v
+	}
+
+	all := rs.Sample(1000, 42)
+	require.Equal(t, rs.DataDigest(DigestOptions{}), all.DataDigest(DigestOptions{}))
+	require.True(t, all.isNil(7, 1))
+	require.Equal(t, 0, rs.Sample(0, 42).NRows())
+
+	// samples share nothing with the original
+	all.data[0][0][0] = 'x'
+	require.NotEqual(t, rs.DataDigest(DigestOptions{}), all.DataDigest(DigestOptions{}))
+	raw, err := s1.Encode()
+	require.NoError(t, err)
+	var decoded ResultSet
+	require.NoError(t, decoded.Decode(raw))
+	require.Equal(t, s1.DataDigest(DigestOptions{}), decoded.DataDigest(DigestOptions{}))
+}
+
+func TestHeadTail(t *testing.T) {
+	rs := syntheticResultSet(5)
+	head, tail := rs.Head(2), rs.Tail(2)
+	require.Equal(t, 2, head.NRows())
+	require.Equal(t, 2, tail.NRows())
+	v, _ := head.RawValue(1, 0)
+	require.Equal(t, rs.data[1][0], v)
+	v, _ = tail.RawValue(0, 0)
+	require.Equal(t, rs.data[3][0], v)
+	require.Equal(t, 5, rs.Head(10).NRows())
+	require.Equal(t, 5, rs.Tail(10).NRows())
+	require.Equal(t, 0, rs.Head(-1).NRows())
+	require.Equal(t, 2, rs.Head(0).NCols())
+
+	exec := &ResultSet{exec: ExecResult{RowsAffected: 1, HasRowsAffected: true}}
+	require.True(t, exec.Head(1).IsExecResult())
+	require.Equal(t, exec.String(), exec.Sample(1, 0).String())
+}
@@ -0,0 +1,245 @@
+package resultset
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ScanOptions struct {
+	// Strict reports columns without a matching field, and fields without a matching column, as errors.
+	Strict bool
+}
+
+var (
+	scannerType  = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType     = reflect.TypeOf(time.Time{})
+	nullTimeType = reflect.TypeOf(sql.NullTime{})
+)
+
+type scanField struct {
+	index []int
+	name  string
+	tag   string
+}
+
+// ScanStructs appends every row to dest, which must be a pointer to a slice of structs (or of pointers to structs).
+// Columns are matched with fields by their `db:"name"` tags first, then by field names, both case-insensitively.
+// Fields of embedded structs are matched as if they were declared in the outer struct. NULL can only be stored in a
+// pointer or a sql.Scanner (like sql.NullString) field. A sql.Scanner is given the raw bytes of the cell, except that
+// a sql.NullTime is given the parsed time of a DATE, DATETIME or TIMESTAMP cell, where zero dates are taken as NULL.
+func (rs *ResultSet) ScanStructs(dest interface{}, opts ...ScanOptions) error {
+	var o ScanOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return errors.New("dest must be a pointer to a slice")
+	}
+	slice := v.Elem()
+	elemType, isPtr := slice.Type().Elem(), false
+	if elemType.Kind() == reflect.Ptr {
+		elemType, isPtr = elemType.Elem(), true
+	}
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot scan into a slice of %s", slice.Type().Elem())
+	}
+	fields := structFields(elemType, nil)
+	targets, err := rs.matchFields(fields, o.Strict)
+	if err != nil {
+		return err
+	}
+	return rs.EachRow(func(i int, row Row) error {
+		elem := reflect.New(elemType).Elem()
+		for j, f := range targets {
+			if f == nil {
+				continue
+			}
+			if err := rs.scanField(fieldByIndex(elem, f.index), i, j, f.name); err != nil {
+				return fmt.Errorf("row %d: %v", i, err)
+			}
+		}
+		if isPtr {
+			slice.Set(reflect.Append(slice, elem.Addr()))
+		} else {
+			slice.Set(reflect.Append(slice, elem))
+		}
+		return nil
+	})
+}
+
+func structFields(t reflect.Type, prefix []int) []scanField {
+	var fields []scanField
+	for k := 0; k < t.NumField(); k++ {
+		f := t.Field(k)
+		index := append(append([]int{}, prefix...), k)
+		tag := f.Tag.Get("db")
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && len(tag) == 0 {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				if len(f.PkgPath) > 0 {
+					// like encoding/json, embedded pointers to unexported struct types are ignored
+					continue
+				}
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != timeType {
+				fields = append(fields, structFields(ft, index)...)
+				continue
+			}
+		}
+		if len(f.PkgPath) > 0 {
+			continue
+		}
+		fields = append(fields, scanField{index: index, name: f.Name, tag: tag})
+	}
+	return fields
+}
+
+func (rs *ResultSet) matchFields(fields []scanField, strict bool) ([]*scanField, error) {
+	targets := make([]*scanField, len(rs.cols))
+	used := make([]bool, len(fields))
+	for _, byTag := range []bool{true, false} {
+		for j, c := range rs.cols {
+			if targets[j] != nil {
+				continue
+			}
+			for k := range fields {
+				name := fields[k].name
+				if byTag {
+					name = fields[k].tag
+				} else if len(fields[k].tag) > 0 {
+					continue
+				}
+				if !used[k] && len(name) > 0 && strings.EqualFold(name, c.Name) {
+					targets[j], used[k] = &fields[k], true
+					break
+				}
+			}
+		}
+	}
+	if strict {
+		for j, f := range targets {
+			if f == nil {
+				return nil, fmt.Errorf("column %s has no matching field", rs.cols[j].Name)
+			}
+		}
+		for k, ok := range used {
+			if !ok {
+				return nil, fmt.Errorf("field %s has no matching column", fields[k].name)
+			}
+		}
+	}
+	return targets, nil
+}
+
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for k, x := range index {
+		if k > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func (rs *ResultSet) scanField(fv reflect.Value, i int, j int, name string) error {
+	raw, _ := rs.RawValue(i, j)
+	col := rs.cols[j]
+	if reflect.PtrTo(fv.Type()).Implements(scannerType) {
+		var src interface{}
+		if raw != nil {
+			if fv.Type() == nullTimeType && isTimeType(col.Type) {
+				t, _, err := rs.GetTime(i, j, time.UTC)
+				if err != nil {
+					return err
+				}
+				if !t.IsZero() {
+					src = t
+				}
+			} else {
+				src = append([]byte{}, raw...)
+			}
+		}
+		if err := fv.Addr().Interface().(sql.Scanner).Scan(src); err != nil {
+			return fmt.Errorf("column %s: scan into field %s: %v", col.Name, name, err)
+		}
+		return nil
+	}
+	if fv.Kind() == reflect.Ptr {
+		if raw == nil {
+			fv.Set(reflect.Zero(fv.Type()))
+			return nil
+		}
+		p := reflect.New(fv.Type().Elem())
+		if err := rs.scanField(p.Elem(), i, j, name); err != nil {
+			return err
+		}
+		fv.Set(p)
+		return nil
+	}
+	if raw == nil {
+		return fmt.Errorf("column %s: cannot scan NULL into field %s of type %s", col.Name, name, fv.Type())
+	}
+	if fv.Type() == timeType {
+		t, _, err := rs.GetTime(i, j, time.UTC)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(string(raw))
+	case reflect.Bool:
+		b, _, err := rs.GetBool(i, j)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, _, err := rs.GetInt64(i, j)
+		if err != nil {
+			return err
+		}
+		if fv.OverflowInt(n) {
+			return fmt.Errorf("column %s: %d overflows field %s of type %s", col.Name, n, name, fv.Type())
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(string(raw), 10, 64)
+		if err != nil {
+			return rs.cellError(j, raw, "uint64", err)
+		}
+		if fv.OverflowUint(n) {
+			return fmt.Errorf("column %s: %d overflows field %s of type %s", col.Name, n, name, fv.Type())
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, _, err := rs.GetFloat64(i, j)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("column %s: unsupported field %s of type %s", col.Name, name, fv.Type())
+		}
+		fv.SetBytes(append([]byte{}, raw...))
+	default:
+		return fmt.Errorf("column %s: unsupported field %s of type %s", col.Name, name, fv.Type())
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package resultset
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type scanBase struct {
+	ID      int64 `db:"id"`
+	Created time.Time
+}
+
+type scanExtra struct {
+	Score sql.NullFloat64 `db:"score"`
+}
+
+type scanRecord struct {
+	scanBase
+	scanExtra
+	Name    string
+	Note    *string
+	Active  bool
+	Tag     sql.NullString `db:"tag"`
+	Ignored string         `db:"-"`
+	hidden  string
+}
+
+func TestScanStructs(t *testing.T) {
+	rs, err := FromJSON([]byte(`{
+		"columns": [{"name":"id","type":"BIGINT"},{"name":"created","type":"DATETIME"},{"name":"NAME","type":"VARCHAR"},
+			{"name":"note","type":"TEXT"},{"name":"active","type":"TINYINT"},{"name":"tag","type":"VARCHAR"},
+			{"name":"score","type":"DOUBLE"},{"name":"extra","type":"INT"}],
+		"rows": [
+			[1, "2020-01-02 03:04:05", "a", "hi", 1, "x", 1.5, 7],
+			[2, "2020-01-03", "b", null, 0, null, null, null]
+		]}`))
+	require.NoError(t, err)
+
+	var recs []scanRecord
+	require.NoError(t, rs.ScanStructs(&recs))
+	require.Len(t, recs, 2)
+	require.Equal(t, int64(1), recs[0].ID)
+	require.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), recs[0].Created)
+	require.Equal(t, "a", recs[0].Name)
+	require.Equal(t, "hi", *recs[0].Note)
+	require.True(t, recs[0].Active)
+	require.Equal(t, sql.NullString{String: "x", Valid: true}, recs[0].Tag)
+	require.Equal(t, sql.NullFloat64{Float64: 1.5, Valid: true}, recs[0].Score)
+	require.Nil(t, recs[1].Note)
+	require.False(t, recs[1].Active)
+	require.False(t, recs[1].Tag.Valid)
+	require.False(t, recs[1].Score.Valid)
+
+	var ptrs []*scanRecord
+	require.NoError(t, rs.ScanStructs(&ptrs))
+	require.Equal(t, "b", ptrs[1].Name)
+
+	err = rs.ScanStructs(&recs, ScanOptions{Strict: true})
+	require.EqualError(t, err, "column extra has no matching field")
+
+	var strict []struct {
+		ID    int64
+		Other string
+	}
+	ids, err := FromMaps([]ColumnInfo{{Name: "id", Type: "BIGINT"}}, []map[string]string{{"id": "1"}})
+	require.NoError(t, err)
+	err = ids.ScanStructs(&strict, ScanOptions{Strict: true})
+	require.EqualError(t, err, "field Other has no matching column")
+
+	var notNull []struct {
+		Note string
+	}
+	err = rs.ScanStructs(&notNull)
+	require.EqualError(t, err, "row 1: column note: cannot scan NULL into field Note of type string")
+
+	var small []struct {
+		ID int8 `db:"active"`
+	}
+	require.NoError(t, rs.ScanStructs(&small))
+	require.Equal(t, int8(1), small[0].ID)
+
+	require.Error(t, rs.ScanStructs(recs))
+	require.Error(t, rs.ScanStructs(&[]int{}))
+}
+
+func TestScanStructsTemporalScanners(t *testing.T) {
+	rs, err := FromJSON([]byte(`{
+		"columns": [{"name":"dt","type":"DATETIME"},{"name":"d","type":"DATE"}],
+		"rows": [["2020-01-02 03:04:05", "0000-00-00"], [null, "2020-01-03"]]}`))
+	require.NoError(t, err)
+
+	var texts []struct {
+		DT sql.NullString
+		D  sql.NullString
+	}
+	require.NoError(t, rs.ScanStructs(&texts))
+	require.Equal(t, sql.NullString{String: "2020-01-02 03:04:05", Valid: true}, texts[0].DT)
+	require.Equal(t, sql.NullString{String: "0000-00-00", Valid: true}, texts[0].D)
+	require.False(t, texts[1].DT.Valid)
+
+	var times []struct {
+		DT sql.NullTime
+		D  sql.NullTime
+	}
+	require.NoError(t, rs.ScanStructs(&times))
+	require.Equal(t, sql.NullTime{Time: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Valid: true}, times[0].DT)
+	require.False(t, times[0].D.Valid)
+	require.False(t, times[1].DT.Valid)
+	require.Equal(t, sql.NullTime{Time: time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC), Valid: true}, times[1].D)
+
+	var ptrs []struct {
+		DT *time.Time
+	}
+	require.NoError(t, rs.ScanStructs(&ptrs))
+	require.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), *ptrs[0].DT)
+	require.Nil(t, ptrs[1].DT)
+}
@@ -0,0 +1,155 @@
+package resultset
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type SetDiffOptions struct {
+	// Keys names the columns identifying a row, rows sharing keys but differing in other columns are reported as
+	// changed instead of being reported on both sides.
+	Keys []string
+	// Digest provides the normalizations (Filter, Mapper, JSONSemantic) applied to cells before they are compared,
+	// Sort and SortKeys are ignored.
+	Digest DigestOptions
+}
+
+// RowChange describes a pair of rows with the same keys but different values.
+type RowChange struct {
+	// A and B are row indexes in the compared result sets.
+	A int
+	B int
+	// Columns lists names of the differing columns.
+	Columns []string
+}
+
+type SetDiff struct {
+	OnlyInA *ResultSet
+	OnlyInB *ResultSet
+	Changed []RowChange
+}
+
+func (d *SetDiff) Empty() bool {
+	return d.OnlyInA.NRows() == 0 && d.OnlyInB.NRows() == 0 && len(d.Changed) == 0
+}
+
+func (d *SetDiff) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d only in a, %d only in b, %d changed", d.OnlyInA.NRows(), d.OnlyInB.NRows(), len(d.Changed))
+	for _, c := range d.Changed {
+		fmt.Fprintf(&sb, "\n  a#%d vs b#%d: %s", c.A, c.B, strings.Join(c.Columns, ", "))
+	}
+	return sb.String()
+}
+
+// DiffSet compares rows of a and b as multisets: a row appearing twice in a and once in b is reported once in
+// OnlyInA. Cells are normalized by opts.Digest, so the result agrees with DataDigest using the same options.
+func DiffSet(a *ResultSet, b *ResultSet, opts SetDiffOptions) (*SetDiff, error) {
+	if a.IsExecResult() || b.IsExecResult() {
+		return nil, errors.New("cannot diff exec results")
+	}
+	if a.NCols() != b.NCols() {
+		return nil, fmt.Errorf("column count mismatch: %d vs %d", a.NCols(), b.NCols())
+	}
+	var keys []int
+	for _, name := range opts.Keys {
+		j, ok := a.ColumnIndex(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown key column %s", name)
+		}
+		keys = append(keys, j)
+	}
+	dopts := opts.Digest
+	if dopts.JSONSemantic {
+		dopts.Mapper = jsonSemanticMapper(dopts.Mapper)
+	}
+
+	onlyA, onlyB := make(map[int]bool), make(map[int]bool)
+	pending := make(map[string][]int)
+	for i := range a.data {
+		k := string(a.rowDigest(i, dopts))
+		pending[k] = append(pending[k], i)
+	}
+	var restB []int
+	for i := range b.data {
+		k := string(b.rowDigest(i, dopts))
+		if is := pending[k]; len(is) > 0 {
+			pending[k] = is[1:]
+			continue
+		}
+		restB = append(restB, i)
+	}
+	left := make(map[int]bool)
+	for _, is := range pending {
+		for _, i := range is {
+			left[i] = true
+		}
+	}
+	var restA []int
+	for i := range a.data {
+		if left[i] {
+			restA = append(restA, i)
+		}
+	}
+
+	diff := &SetDiff{}
+	if len(keys) > 0 {
+		byKey := make(map[string][]int)
+		for _, i := range restA {
+			k := string(a.keyDigest(i, keys, dopts))
+			byKey[k] = append(byKey[k], i)
+		}
+		for _, i := range restB {
+			k := string(b.keyDigest(i, keys, dopts))
+			if is := byKey[k]; len(is) > 0 {
+				byKey[k] = is[1:]
+				diff.Changed = append(diff.Changed, RowChange{A: is[0], B: i, Columns: changedColumns(a, is[0], b, i, dopts)})
+				continue
+			}
+			onlyB[i] = true
+		}
+		for _, is := range byKey {
+			for _, i := range is {
+				onlyA[i] = true
+			}
+		}
+	} else {
+		for _, i := range restA {
+			onlyA[i] = true
+		}
+		for _, i := range restB {
+			onlyB[i] = true
+		}
+	}
+	diff.OnlyInA = a.Filter(func(row Row) bool { return onlyA[row.i] })
+	diff.OnlyInB = b.Filter(func(row Row) bool { return onlyB[row.i] })
+	return diff, nil
+}
+
+func (rs *ResultSet) keyDigest(i int, keys []int, opts DigestOptions) []byte {
+	var buf bytes.Buffer
+	for _, j := range keys {
+		_ = rs.encodeCellTo(&buf, i, j, opts.Mapper)
+	}
+	return buf.Bytes()
+}
+
+func changedColumns(a *ResultSet, ia int, b *ResultSet, ib int, opts DigestOptions) []string {
+	var cols []string
+	var x, y bytes.Buffer
+	for j := range a.cols {
+		if opts.Filter != nil && !opts.Filter(ia, j, a.data[ia][j], a.cols[j]) && !opts.Filter(ib, j, b.data[ib][j], b.cols[j]) {
+			continue
+		}
+		x.Reset()
+		y.Reset()
+		_ = a.encodeCellTo(&x, ia, j, opts.Mapper)
+		_ = b.encodeCellTo(&y, ib, j, opts.Mapper)
+		if !bytes.Equal(x.Bytes(), y.Bytes()) {
+			cols = append(cols, a.cols[j].Name)
+		}
+	}
+	return cols
+}
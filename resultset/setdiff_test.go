@@ -0,0 +1,48 @@
+package resultset
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffSet(t *testing.T) {
+	schema := `"columns":[{"name":"id","type":"INT"},{"name":"v","type":"VARCHAR"}]`
+	parse := func(rows string) *ResultSet {
+		rs, err := FromJSON([]byte(`{` + schema + `,"rows":` + rows + `}`))
+		require.NoError(t, err)
+		return rs
+	}
+	a := parse(`[[1,"a"],[1,"a"],[2,null],[3,"c"],[4,"d"]]`)
+	b := parse(`[[3,"c"],[1,"a"],[2,""],[4,"D"],[5,"e"]]`)
+
+	d, err := DiffSet(a, b, SetDiffOptions{})
+	require.NoError(t, err)
+	require.False(t, d.Empty())
+	require.Equal(t, parse(`[[1,"a"],[2,null],[4,"d"]]`).DataDigest(DigestOptions{}), d.OnlyInA.DataDigest(DigestOptions{}))
+	require.Equal(t, parse(`[[2,""],[4,"D"],[5,"e"]]`).DataDigest(DigestOptions{}), d.OnlyInB.DataDigest(DigestOptions{}))
+	require.Empty(t, d.Changed)
+
+	d, err = DiffSet(a, b, SetDiffOptions{Keys: []string{"ID"}})
+	require.NoError(t, err)
+	require.Equal(t, parse(`[[1,"a"]]`).DataDigest(DigestOptions{}), d.OnlyInA.DataDigest(DigestOptions{}))
+	require.Equal(t, parse(`[[5,"e"]]`).DataDigest(DigestOptions{}), d.OnlyInB.DataDigest(DigestOptions{}))
+	require.Equal(t, []RowChange{{A: 2, B: 2, Columns: []string{"v"}}, {A: 4, B: 3, Columns: []string{"v"}}}, d.Changed)
+
+	lower := func(i int, j int, raw []byte, def ColumnDef) []byte { return bytes.ToLower(raw) }
+	d, err = DiffSet(a, b, SetDiffOptions{Keys: []string{"id"}, Digest: DigestOptions{Mapper: lower}})
+	require.NoError(t, err)
+	require.Equal(t, []RowChange{{A: 2, B: 2, Columns: []string{"v"}}}, d.Changed)
+
+	d, err = DiffSet(a, a, SetDiffOptions{})
+	require.NoError(t, err)
+	require.True(t, d.Empty())
+
+	_, err = DiffSet(a, b, SetDiffOptions{Keys: []string{"x"}})
+	require.EqualError(t, err, "unknown key column x")
+	ids, err := a.ProjectIndex(0)
+	require.NoError(t, err)
+	_, err = DiffSet(a, ids, SetDiffOptions{})
+	require.EqualError(t, err, "column count mismatch: 2 vs 1")
+}
@@ -0,0 +1,173 @@
+package resultset
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+type SortMode int
+
+const (
+	// SortAuto compares numerically or chronologically according to the column type, and bytewise otherwise.
+	SortAuto SortMode = iota
+	SortBytes
+	SortNumeric
+	SortTime
+)
+
+// SortKey specifies a column to sort by. The column is looked up by Column (case-insensitively) if it's not empty,
+// otherwise Index is used.
+type SortKey struct {
+	Column string
+	Index  int
+	Desc   bool
+	Mode   SortMode
+}
+
+// SortBy sorts rows stably by the given keys. Like MySQL, NULLs come first in ascending order and last in descending
+// order. Values that cannot be parsed in the numeric or time mode are compared bytewise after the parsable ones.
+func (rs *ResultSet) SortBy(keys ...SortKey) error {
+	if rs.IsExecResult() || len(keys) == 0 {
+		return nil
+	}
+	rks, err := rs.resolveSortKeys(keys)
+	if err != nil {
+		return err
+	}
+	rs.Sort(func(r1 int, r2 int) bool {
+		for _, key := range rks {
+			c := 0
+			n1, n2 := rs.isNil(r1, key.j), rs.isNil(r2, key.j)
+			switch {
+			case n1 && n2:
+			case n1:
+				c = -1
+			case n2:
+				c = 1
+			default:
+				c = key.cmp(rs.data[r1][key.j], rs.data[r2][key.j])
+			}
+			if key.desc {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return nil
+}
+
+type resolvedSortKey struct {
+	j    int
+	desc bool
+	cmp  func(a []byte, b []byte) int
+}
+
+func (rs *ResultSet) resolveSortKeys(keys []SortKey) ([]resolvedSortKey, error) {
+	rks := make([]resolvedSortKey, len(keys))
+	for k, key := range keys {
+		j := key.Index
+		if len(key.Column) > 0 {
+			var ok bool
+			if j, ok = rs.ColumnIndex(key.Column); !ok {
+				return nil, fmt.Errorf("sort by unknown column %s", key.Column)
+			}
+		}
+		if j < 0 || j >= len(rs.cols) {
+			return nil, fmt.Errorf("sort by column #%d: out of range", j)
+		}
+		mode := key.Mode
+		if mode == SortAuto {
+			mode = autoSortMode(rs.cols[j].Type)
+		}
+		rks[k] = resolvedSortKey{j: j, desc: key.Desc, cmp: mode.compareFunc()}
+	}
+	return rks, nil
+}
+
+// permute reorders rows so that the i-th row becomes the perm[i]-th row of the original, NULL marks are moved along.
+func (rs *ResultSet) permute(perm []int) {
+	data := make([][][]byte, len(perm))
+	nils := rs.nils
+	rs.nils = nil
+	old := &ResultSet{cols: rs.cols, nils: nils}
+	for i, k := range perm {
+		data[i] = rs.data[k]
+		for j := range rs.cols {
+			if old.isNil(k, j) {
+				rs.markNil(i, j)
+			}
+		}
+	}
+	rs.data = data
+}
+
+func (m SortMode) compareFunc() func(a []byte, b []byte) int {
+	switch m {
+	case SortNumeric:
+		return compareNumeric
+	case SortTime:
+		return compareTime
+	default:
+		return bytes.Compare
+	}
+}
+
+func autoSortMode(t string) SortMode {
+	switch {
+	case isIntegerType(t), isFloatType(t), isDecimalType(t):
+		return SortNumeric
+	case isTimeType(t):
+		return SortTime
+	default:
+		return SortBytes
+	}
+}
+
+func compareNumeric(a []byte, b []byte) int {
+	x, okx := new(big.Rat).SetString(string(a))
+	y, oky := new(big.Rat).SetString(string(b))
+	switch {
+	case okx && oky:
+		return x.Cmp(y)
+	case okx:
+		return -1
+	case oky:
+		return 1
+	default:
+		return bytes.Compare(a, b)
+	}
+}
+
+func parseSortTime(raw []byte) (time.Time, bool) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, string(raw), time.UTC); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func compareTime(a []byte, b []byte) int {
+	x, okx := parseSortTime(a)
+	y, oky := parseSortTime(b)
+	switch {
+	case okx && oky:
+		if x.Before(y) {
+			return -1
+		} else if x.After(y) {
+			return 1
+		}
+		return 0
+	case okx:
+		return -1
+	case oky:
+		return 1
+	default:
+		return bytes.Compare(a, b)
+	}
+}
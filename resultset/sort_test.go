@@ -0,0 +1,104 @@
+package resultset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func sortFixture(t *testing.T) *ResultSet {
+	rs, err := FromJSON([]byte(`{
+		"columns": [{"name":"n","type":"INT"},{"name":"s","type":"VARCHAR"},{"name":"d","type":"DATETIME"}],
+		"rows": [
+			[10, "b", "2020-01-02"],
+			[null, "a", null],
+			[9, "c", "2020-01-01 12:00:00"],
+			[100, null, "2019-12-31 23:59:59.5"],
+			[9, "a", "2020-01-01"]
+		]}`))
+	require.NoError(t, err)
+	return rs
+}
+
+func column(rs *ResultSet, j int) []interface{} {
+	var out []interface{}
+	for i := 0; i < rs.NRows(); i++ {
+		v, _ := rs.RawValue(i, j)
+		if v == nil {
+			out = append(out, nil)
+		} else {
+			out = append(out, string(v))
+		}
+	}
+	return out
+}
+
+func TestSortBy(t *testing.T) {
+	rs := sortFixture(t)
+	require.NoError(t, rs.SortBy(SortKey{Column: "N"}))
+	require.Equal(t, []interface{}{nil, "9", "9", "10", "100"}, column(rs, 0))
+	require.Equal(t, []interface{}{"a", "c", "a", "b", nil}, column(rs, 1))
+
+	rs = sortFixture(t)
+	require.NoError(t, rs.SortBy(SortKey{Column: "n", Mode: SortBytes}))
+	require.Equal(t, []interface{}{nil, "10", "100", "9", "9"}, column(rs, 0))
+
+	rs = sortFixture(t)
+	require.NoError(t, rs.SortBy(SortKey{Index: 0, Desc: true}, SortKey{Index: 1}))
+	require.Equal(t, []interface{}{"100", "10", "9", "9", nil}, column(rs, 0))
+	require.Equal(t, []interface{}{nil, "b", "a", "c", "a"}, column(rs, 1))
+
+	rs = sortFixture(t)
+	require.NoError(t, rs.SortBy(SortKey{Column: "d"}))
+	require.Equal(t, []interface{}{nil, "2019-12-31 23:59:59.5", "2020-01-01", "2020-01-01 12:00:00", "2020-01-02"}, column(rs, 2))
+	require.Equal(t, []interface{}{nil, "100", "9", "9", "10"}, column(rs, 0))
+
+	require.EqualError(t, rs.SortBy(SortKey{Column: "x"}), "sort by unknown column x")
+	require.Error(t, rs.SortBy(SortKey{Index: 3}))
+
+	raw, err := rs.Encode()
+	require.NoError(t, err)
+	var rs2 ResultSet
+	require.NoError(t, rs2.Decode(raw))
+	require.Equal(t, column(rs, 1), column(&rs2, 1))
+}
+
+func TestSortKeepsNulls(t *testing.T) {
+	rs := sortFixture(t)
+	rs.Sort(func(r1 int, r2 int) bool {
+		v1, _ := rs.RawValue(r1, 1)
+		v2, _ := rs.RawValue(r2, 1)
+		return string(v1) > string(v2)
+	})
+	require.Equal(t, []interface{}{"c", "b", "a", "a", nil}, column(rs, 1))
+	require.Equal(t, []interface{}{"9", "10", nil, "9", "100"}, column(rs, 0))
+}
+
+func TestDigestSortKeys(t *testing.T) {
+	rs1, rs2 := sortFixture(t), sortFixture(t)
+	require.NoError(t, rs2.SortBy(SortKey{Column: "s", Desc: true}))
+	keys := []SortKey{{Column: "n"}, {Column: "s"}}
+	require.NotEqual(t, rs1.DataDigest(DigestOptions{}), rs2.DataDigest(DigestOptions{}))
+	require.Equal(t, rs1.DataDigest(DigestOptions{SortKeys: keys}), rs2.DataDigest(DigestOptions{SortKeys: keys}))
+	require.Equal(t, []interface{}{"10", nil, "9", "100", "9"}, column(rs1, 0))
+
+	sorted := sortFixture(t)
+	require.NoError(t, sorted.SortBy(keys...))
+	require.Equal(t, sorted.DataDigest(DigestOptions{}), rs1.DataDigest(DigestOptions{SortKeys: keys}))
+}
+
+func TestDigestOptionsValidate(t *testing.T) {
+	rs := sortFixture(t)
+	require.NoError(t, DigestOptions{SortKeys: []SortKey{{Column: "N"}, {Index: 1}}}.Validate(rs))
+	require.EqualError(t, DigestOptions{SortKeys: []SortKey{{Column: "x"}}}.Validate(rs), "sort by unknown column x")
+	require.EqualError(t, DigestOptions{SortKeys: []SortKey{{Index: 5}}}.Validate(rs), "sort by column #5: out of range")
+	require.EqualError(t, DigestOptions{Hash: "md5"}.Validate(rs), "unknown digest algorithm: md5")
+	require.NoError(t, DigestOptions{Sort: true, SortKeys: []SortKey{{Column: "x"}}}.Validate(rs))
+	require.NoError(t, DigestOptions{SortKeys: []SortKey{{Column: "x"}}}.Validate(&ResultSet{exec: ExecResult{RowsAffected: 1}}))
+
+	bad := CompareOptions{Digest: DigestOptions{SortKeys: []SortKey{{Column: "x"}}}}
+	ok, diffs := rs.Equal(sortFixture(t), bad)
+	require.False(t, ok)
+	require.Equal(t, []CellDiff{{Kind: InvalidOptions, Row: -1, Expect: "sort by unknown column x", Actual: "sort by unknown column x"}}, diffs)
+	require.Equal(t, "invalid options: sort by unknown column x; sort by unknown column x", diffs[0].String())
+}
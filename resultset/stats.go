@@ -0,0 +1,54 @@
+package resultset
+
+// ColumnStat summarizes values of a column. Min and Max are compared like SortBy does in the SortAuto mode, they are
+// nil if the column has no non-NULL value or is a binary column.
+type ColumnStat struct {
+	Name     string
+	Type     string
+	Nulls    int
+	Distinct int
+	Min      []byte
+	Max      []byte
+}
+
+// ColumnStats computes statistics of every column from the raw cells, values returned are copies.
+func (rs *ResultSet) ColumnStats() []ColumnStat {
+	if rs.IsExecResult() {
+		return nil
+	}
+	stats := make([]ColumnStat, len(rs.cols))
+	for j, c := range rs.cols {
+		st := ColumnStat{Name: c.Name, Type: c.Type}
+		binary := isBinaryType(c.Type)
+		cmp := autoSortMode(c.Type).compareFunc()
+		seen := make(map[string]struct{})
+		var (
+			min, max []byte
+			has      bool
+		)
+		for i := range rs.data {
+			if rs.isNil(i, j) {
+				st.Nulls++
+				continue
+			}
+			v := rs.data[i][j]
+			seen[string(v)] = struct{}{}
+			if binary {
+				continue
+			}
+			if !has || cmp(v, min) < 0 {
+				min = v
+			}
+			if !has || cmp(v, max) > 0 {
+				max = v
+			}
+			has = true
+		}
+		st.Distinct = len(seen)
+		if has {
+			st.Min, st.Max = append([]byte{}, min...), append([]byte{}, max...)
+		}
+		stats[j] = st
+	}
+	return stats
+}
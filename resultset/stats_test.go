@@ -0,0 +1,33 @@
+package resultset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnStats(t *testing.T) {
+	rs := New([]ColumnDef{{Name: "id", Type: "INT"}, {Name: "v", Type: "VARCHAR"}, {Name: "b", Type: "BLOB"}, {Name: "n", Type: "INT"}})
+	for _, row := range [][]string{{"10", "b", "\x01", ""}, {"9", "", "\x02", ""}, {"100", "b", "\x01", ""}} {
+		rs.data = append(rs.data, [][]byte{[]byte(row[0]), []byte(row[1]), []byte(row[2]), nil})
+	}
+	rs.data = append(rs.data, [][]byte{[]byte("-1"), nil, nil, nil})
+	for i := 0; i < 4; i++ {
+		rs.markNil(i, 3)
+	}
+	rs.markNil(3, 1)
+	rs.markNil(3, 2)
+
+	stats := rs.ColumnStats()
+	require.Equal(t, []ColumnStat{
+		{Name: "id", Type: "INT", Distinct: 4, Min: []byte("-1"), Max: []byte("100")},
+		{Name: "v", Type: "VARCHAR", Nulls: 1, Distinct: 2, Min: []byte(""), Max: []byte("b")},
+		{Name: "b", Type: "BLOB", Nulls: 1, Distinct: 2},
+		{Name: "n", Type: "INT", Nulls: 4},
+	}, stats)
+
+	// stats are copies
+	stats[0].Min[0] = '0'
+	require.Equal(t, []byte("-1"), rs.ColumnStats()[0].Min)
+	require.Nil(t, (&ResultSet{exec: ExecResult{RowsAffected: 1}}).ColumnStats())
+}
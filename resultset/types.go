@@ -0,0 +1,124 @@
+package resultset
+
+import "strings"
+
+func isBinaryType(t string) bool {
+	switch strings.ToUpper(t) {
+	case "BINARY", "VARBINARY", "TINYBLOB", "BLOB", "MEDIUMBLOB", "LONGBLOB", "BIT", "GEOMETRY":
+		return true
+	default:
+		return false
+	}
+}
+
+func isIntegerType(t string) bool {
+	switch strings.ToUpper(t) {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "YEAR":
+		return true
+	default:
+		return false
+	}
+}
+
+func isFloatType(t string) bool {
+	switch strings.ToUpper(t) {
+	case "FLOAT", "DOUBLE", "REAL":
+		return true
+	default:
+		return false
+	}
+}
+
+func isDecimalType(t string) bool {
+	switch strings.ToUpper(t) {
+	case "DECIMAL", "NUMERIC":
+		return true
+	default:
+		return false
+	}
+}
+
+func isTimeType(t string) bool {
+	switch strings.ToUpper(t) {
+	case "DATE", "DATETIME", "TIMESTAMP":
+		return true
+	default:
+		return false
+	}
+}
+
+func isJSONType(t string) bool { return strings.EqualFold(t, "JSON") }
+
+// MySQL column type constants as used by the client/server protocol.
+const (
+	TypeDecimal byte = iota
+	TypeTiny
+	TypeShort
+	TypeLong
+	TypeFloat
+	TypeDouble
+	TypeNull
+	TypeTimestamp
+	TypeLongLong
+	TypeInt24
+	TypeDate
+	TypeTime
+	TypeDateTime
+	TypeYear
+	TypeNewDate
+	TypeVarchar
+	TypeBit
+)
+
+const (
+	TypeJSON byte = iota + 0xf5
+	TypeNewDecimal
+	TypeEnum
+	TypeSet
+	TypeTinyBlob
+	TypeMediumBlob
+	TypeLongBlob
+	TypeBlob
+	TypeVarString
+	TypeString
+	TypeGeometry
+)
+
+var typeCodes = map[string]byte{
+	"BIT":        TypeBit,
+	"TEXT":       TypeBlob,
+	"BLOB":       TypeBlob,
+	"DATE":       TypeDate,
+	"DATETIME":   TypeDateTime,
+	"DECIMAL":    TypeNewDecimal,
+	"DOUBLE":     TypeDouble,
+	"ENUM":       TypeEnum,
+	"FLOAT":      TypeFloat,
+	"GEOMETRY":   TypeGeometry,
+	"MEDIUMINT":  TypeInt24,
+	"JSON":       TypeJSON,
+	"INT":        TypeLong,
+	"LONGTEXT":   TypeLongBlob,
+	"LONGBLOB":   TypeLongBlob,
+	"BIGINT":     TypeLongLong,
+	"MEDIUMTEXT": TypeMediumBlob,
+	"MEDIUMBLOB": TypeMediumBlob,
+	"NULL":       TypeNull,
+	"SET":        TypeSet,
+	"SMALLINT":   TypeShort,
+	"BINARY":     TypeString,
+	"CHAR":       TypeString,
+	"TIME":       TypeTime,
+	"TIMESTAMP":  TypeTimestamp,
+	"TINYINT":    TypeTiny,
+	"TINYTEXT":   TypeTinyBlob,
+	"TINYBLOB":   TypeTinyBlob,
+	"VARBINARY":  TypeVarString,
+	"VARCHAR":    TypeVarString,
+	"YEAR":       TypeYear,
+}
+
+func TypeCode(name string) (byte, bool) {
+	code, ok := typeCodes[strings.ToUpper(name)]
+	return code, ok
+}
@@ -0,0 +1,207 @@
+package resultset
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (rs *ResultSet) cell(i int, j int) ([]byte, error) {
+	raw, ok := rs.RawValue(i, j)
+	if !ok {
+		return nil, fmt.Errorf("cell (%d, %d) is out of range", i, j)
+	}
+	return raw, nil
+}
+
+func (rs *ResultSet) cellError(j int, raw []byte, kind string, err error) error {
+	if j < 0 {
+		j += len(rs.cols)
+	}
+	return fmt.Errorf("column %s: cannot parse %q as %s: %v", rs.cols[j].Name, raw, kind, err)
+}
+
+func (rs *ResultSet) columnIndex(name string) (int, error) {
+	j, ok := rs.ColumnIndex(name)
+	if !ok {
+		return -1, fmt.Errorf("column %s does not exist", name)
+	}
+	return j, nil
+}
+
+// GetInt64 parses the cell as a signed integer, the bool result reports whether the cell is NULL.
+func (rs *ResultSet) GetInt64(i int, j int) (int64, bool, error) {
+	raw, err := rs.cell(i, j)
+	if err != nil {
+		return 0, false, err
+	}
+	if raw == nil {
+		return 0, true, nil
+	}
+	v, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return 0, false, rs.cellError(j, raw, "int64", err)
+	}
+	return v, false, nil
+}
+
+// GetFloat64 parses the cell as a floating-point number, the bool result reports whether the cell is NULL.
+func (rs *ResultSet) GetFloat64(i int, j int) (float64, bool, error) {
+	raw, err := rs.cell(i, j)
+	if err != nil {
+		return 0, false, err
+	}
+	if raw == nil {
+		return 0, true, nil
+	}
+	v, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil {
+		return 0, false, rs.cellError(j, raw, "float64", err)
+	}
+	return v, false, nil
+}
+
+// GetString returns the cell as a string, the bool result reports whether the cell is NULL.
+func (rs *ResultSet) GetString(i int, j int) (string, bool, error) {
+	raw, err := rs.cell(i, j)
+	if err != nil {
+		return "", false, err
+	}
+	if raw == nil {
+		return "", true, nil
+	}
+	return string(raw), false, nil
+}
+
+// GetBool parses the cell as a boolean. Integers are true when non-zero (as MySQL does for BOOL columns), otherwise
+// the forms accepted by strconv.ParseBool are recognized. The bool result reports whether the cell is NULL.
+func (rs *ResultSet) GetBool(i int, j int) (bool, bool, error) {
+	raw, err := rs.cell(i, j)
+	if err != nil {
+		return false, false, err
+	}
+	if raw == nil {
+		return false, true, nil
+	}
+	if n, err := strconv.ParseInt(string(raw), 10, 64); err == nil {
+		return n != 0, false, nil
+	}
+	v, err := strconv.ParseBool(string(raw))
+	if err != nil {
+		return false, false, rs.cellError(j, raw, "bool", err)
+	}
+	return v, false, nil
+}
+
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// GetTime parses the cell as a DATE, DATETIME or TIMESTAMP in the given location (UTC if nil). Zero dates like
+// '0000-00-00' result in the zero time. The bool result reports whether the cell is NULL.
+func (rs *ResultSet) GetTime(i int, j int, loc *time.Location) (time.Time, bool, error) {
+	raw, err := rs.cell(i, j)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if raw == nil {
+		return time.Time{}, true, nil
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	s := string(raw)
+	if strings.HasPrefix(s, "0000-00-00") && strings.Trim(s, "0-:. ") == "" {
+		return time.Time{}, false, nil
+	}
+	for _, layout := range timeLayouts {
+		var v time.Time
+		if v, err = time.ParseInLocation(layout, s, loc); err == nil {
+			return v, false, nil
+		}
+	}
+	return time.Time{}, false, rs.cellError(j, raw, "time", err)
+}
+
+func (rs *ResultSet) GetInt64ByName(i int, name string) (int64, bool, error) {
+	j, err := rs.columnIndex(name)
+	if err != nil {
+		return 0, false, err
+	}
+	return rs.GetInt64(i, j)
+}
+
+func (rs *ResultSet) GetFloat64ByName(i int, name string) (float64, bool, error) {
+	j, err := rs.columnIndex(name)
+	if err != nil {
+		return 0, false, err
+	}
+	return rs.GetFloat64(i, j)
+}
+
+func (rs *ResultSet) GetStringByName(i int, name string) (string, bool, error) {
+	j, err := rs.columnIndex(name)
+	if err != nil {
+		return "", false, err
+	}
+	return rs.GetString(i, j)
+}
+
+func (rs *ResultSet) GetBoolByName(i int, name string) (bool, bool, error) {
+	j, err := rs.columnIndex(name)
+	if err != nil {
+		return false, false, err
+	}
+	return rs.GetBool(i, j)
+}
+
+func (rs *ResultSet) GetTimeByName(i int, name string, loc *time.Location) (time.Time, bool, error) {
+	j, err := rs.columnIndex(name)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return rs.GetTime(i, j, loc)
+}
+
+// TypedValueAt returns the cell converted according to its column type: int64 (or uint64 for unsigned values out of
+// the int64 range) for integers, float64 for FLOAT and DOUBLE, time.Time (in UTC) for DATE, DATETIME and TIMESTAMP,
+// nil for NULL and string for everything else, including DECIMAL whose precision a float64 may not hold.
+func (rs *ResultSet) TypedValueAt(i int, name string) (interface{}, error) {
+	j, err := rs.columnIndex(name)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := rs.cell(i, j)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	t := rs.cols[j].Type
+	switch {
+	case isIntegerType(t):
+		v, _, err := rs.GetInt64(i, j)
+		if err != nil {
+			if u, uerr := strconv.ParseUint(string(raw), 10, 64); uerr == nil {
+				return u, nil
+			}
+			return nil, err
+		}
+		return v, nil
+	case isFloatType(t):
+		v, _, err := rs.GetFloat64(i, j)
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+	case isTimeType(t):
+		v, _, err := rs.GetTime(i, j, time.UTC)
+		if err != nil {
+			return nil, err
+		}
+		return v, nil
+	default:
+		return string(raw), nil
+	}
+}
@@ -0,0 +1,103 @@
+package resultset
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypedGetters(t *testing.T) {
+	rs, err := FromJSON([]byte(`{
+		"columns": [{"name":"i","type":"BIGINT"},{"name":"f","type":"DOUBLE"},{"name":"s","type":"VARCHAR"},
+			{"name":"b","type":"TINYINT"},{"name":"d","type":"DATETIME"}],
+		"rows": [
+			[42, 1.5, "x", 1, "2020-01-02 03:04:05.123456"],
+			[null, null, null, null, null],
+			["nan?", "x", "", "yes", "2020-01-02"]
+		]}`))
+	require.NoError(t, err)
+	raw, err := rs.Encode()
+	require.NoError(t, err)
+	require.NoError(t, rs.Decode(raw))
+
+	i, null, err := rs.GetInt64(0, 0)
+	require.NoError(t, err)
+	require.False(t, null)
+	require.Equal(t, int64(42), i)
+	f, _, err := rs.GetFloat64ByName(0, "F")
+	require.NoError(t, err)
+	require.Equal(t, 1.5, f)
+	s, _, err := rs.GetStringByName(0, "s")
+	require.NoError(t, err)
+	require.Equal(t, "x", s)
+	b, _, err := rs.GetBool(0, 3)
+	require.NoError(t, err)
+	require.True(t, b)
+	tm, _, err := rs.GetTime(0, 4, nil)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 123456000, time.UTC), tm)
+
+	for j := 0; j < rs.NCols(); j++ {
+		_, null, err = rs.GetString(1, j)
+		require.NoError(t, err)
+		require.True(t, null)
+	}
+	_, null, err = rs.GetTimeByName(1, "d", time.Local)
+	require.NoError(t, err)
+	require.True(t, null)
+
+	_, _, err = rs.GetInt64(2, 0)
+	require.EqualError(t, err, `column i: cannot parse "nan?" as int64: strconv.ParseInt: parsing "nan?": invalid syntax`)
+	_, _, err = rs.GetFloat64(2, 1)
+	require.Error(t, err)
+	b, _, err = rs.GetBoolByName(2, "b")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `"yes"`)
+	loc := time.FixedZone("X", 3600)
+	tm, _, err = rs.GetTime(2, -1, loc)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2020, 1, 2, 0, 0, 0, 0, loc), tm)
+
+	_, _, err = rs.GetInt64(3, 0)
+	require.Error(t, err)
+	_, _, err = rs.GetInt64ByName(0, "missing")
+	require.EqualError(t, err, "column missing does not exist")
+}
+
+func TestTypedValueAt(t *testing.T) {
+	rs, err := FromJSON([]byte(`{
+		"columns": [{"name":"i","type":"BIGINT"},{"name":"u","type":"BIGINT"},{"name":"f","type":"FLOAT"},
+			{"name":"d","type":"DECIMAL"},{"name":"ts","type":"TIMESTAMP"},{"name":"s","type":"VARCHAR"},{"name":"x","type":""}],
+		"rows": [
+			[-3, "18446744073709551615", 0.25, "12345678901234567.89", "2021-02-03 04:05:06", "str", "raw"],
+			[null, null, null, null, null, null, null],
+			["bad", "bad", "bad", "1", "bad", "", ""]
+		]}`))
+	require.NoError(t, err)
+
+	for name, expect := range map[string]interface{}{
+		"i":  int64(-3),
+		"U":  uint64(18446744073709551615),
+		"f":  0.25,
+		"d":  "12345678901234567.89",
+		"ts": time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC),
+		"s":  "str",
+		"x":  "raw",
+	} {
+		v, err := rs.TypedValueAt(0, name)
+		require.NoError(t, err, name)
+		require.Equal(t, expect, v, name)
+		v, err = rs.TypedValueAt(1, name)
+		require.NoError(t, err, name)
+		require.Nil(t, v, name)
+	}
+	for _, name := range []string{"i", "u", "f", "ts"} {
+		_, err = rs.TypedValueAt(2, name)
+		require.Error(t, err, name)
+	}
+	_, err = rs.TypedValueAt(0, "nope")
+	require.Error(t, err)
+	_, err = rs.TypedValueAt(3, "i")
+	require.Error(t, err)
+}
@@ -0,0 +1,36 @@
+package stmtflow
+
+import (
+	"hash/fnv"
+	"io"
+	"strings"
+)
+
+var sessionColors = []string{
+	"\x1b[31m", "\x1b[32m", "\x1b[33m", "\x1b[34m", "\x1b[35m", "\x1b[36m",
+	"\x1b[91m", "\x1b[92m", "\x1b[93m", "\x1b[94m", "\x1b[95m", "\x1b[96m",
+}
+
+const colorReset = "\x1b[0m"
+
+func sessionColor(s string) string {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return sessionColors[h.Sum32()%uint32(len(sessionColors))]
+}
+
+func writeColored(w io.Writer, color string, text string) {
+	b := new(strings.Builder)
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		b.WriteString(color)
+		b.WriteString(strings.TrimSuffix(line, "\n"))
+		b.WriteString(colorReset)
+		if strings.HasSuffix(line, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	io.WriteString(w, b.String())
+}
@@ -0,0 +1,92 @@
+package stmtflow
+
+import "fmt"
+
+type IssueKind string
+
+const (
+	IssueOrphanInvoke     IssueKind = "OrphanInvoke"
+	IssueOrphanReturn     IssueKind = "OrphanReturn"
+	IssueUnresumedBlock   IssueKind = "UnresumedBlock"
+	IssueUnexpectedBlock  IssueKind = "UnexpectedBlock"
+	IssueUnexpectedResume IssueKind = "UnexpectedResume"
+	IssueSQLMismatch      IssueKind = "SQLMismatch"
+)
+
+// ConsistencyIssue describes a broken invariant of a history, Index points to the offending event.
+type ConsistencyIssue struct {
+	Kind    IssueKind
+	Index   int
+	Session string
+	Message string
+}
+
+func (i ConsistencyIssue) String() string {
+	return fmt.Sprintf("#%d %s: %s: %s", i.Index, i.Session, i.Kind, i.Message)
+}
+
+// CheckConsistency checks invariants across events: in each session an invoke is followed by its return (with the
+// same SQL) before the next invoke, and a block happens during a statement and is eventually resumed.
+func (h History) CheckConsistency() []ConsistencyIssue {
+	type state struct {
+		invoke  int
+		blocked int
+	}
+	var (
+		issues []ConsistencyIssue
+		order  []string
+		states = make(map[string]*state)
+	)
+	report := func(kind IssueKind, i int, format string, args ...interface{}) {
+		issues = append(issues, ConsistencyIssue{kind, i, h[i].Session, fmt.Sprintf(format, args...)})
+	}
+	for i, e := range h {
+		st, ok := states[e.Session]
+		if !ok {
+			st = &state{-1, -1}
+			states[e.Session] = st
+			order = append(order, e.Session)
+		}
+		switch e.Kind {
+		case EventInvoke:
+			if st.invoke >= 0 {
+				report(IssueOrphanInvoke, st.invoke, "%q is not returned before the next invoke", h[st.invoke].sql())
+			}
+			st.invoke, st.blocked = i, -1
+		case EventReturn:
+			if st.invoke < 0 {
+				report(IssueOrphanReturn, i, "%q is returned without an invoke", e.sql())
+				continue
+			}
+			if st.blocked >= 0 {
+				report(IssueUnresumedBlock, st.blocked, "returned without being resumed")
+			}
+			if expect, actual := h[st.invoke].sql(), e.sql(); expect != actual {
+				report(IssueSQLMismatch, i, "invoked %q but returned %q", expect, actual)
+			}
+			st.invoke, st.blocked = -1, -1
+		case EventBlock:
+			if st.invoke < 0 || st.blocked >= 0 {
+				report(IssueUnexpectedBlock, i, "blocked without a running statement")
+				continue
+			}
+			st.blocked = i
+		case EventResume:
+			if st.blocked < 0 {
+				report(IssueUnexpectedResume, i, "resumed without being blocked")
+				continue
+			}
+			st.blocked = -1
+		}
+	}
+	for _, s := range order {
+		st := states[s]
+		if st.blocked >= 0 {
+			report(IssueUnresumedBlock, st.blocked, "never resumed")
+		}
+		if st.invoke >= 0 {
+			report(IssueOrphanInvoke, st.invoke, "%q is never returned", h[st.invoke].sql())
+		}
+	}
+	return issues
+}
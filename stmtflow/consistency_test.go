@@ -0,0 +1,43 @@
+package stmtflow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryCheckConsistency(t *testing.T) {
+	inv1, ret1 := newInvRet("s1", "select 1", nil)
+	inv2, ret2 := newInvRet("s2", "update t set v = 2", nil)
+	require.Empty(t, History{inv1, inv2, NewBlockEvent("s2"), ret1, NewResumeEvent("s2"), ret2}.CheckConsistency())
+	require.Empty(t, History{}.CheckConsistency())
+
+	_, other := newInvRet("s1", "select 2", nil)
+	issues := History{
+		ret2,                 // 0: orphan return
+		inv1,                 // 1: never returned before the next invoke
+		inv1,                 // 2
+		other,                // 3: sql mismatch
+		NewResumeEvent("s1"), // 4: unexpected resume
+		NewBlockEvent("s1"),  // 5: unexpected block
+		inv2,                 // 6: never returned
+		NewBlockEvent("s2"),  // 7: never resumed
+	}.CheckConsistency()
+	kinds := make([]IssueKind, len(issues))
+	for i, issue := range issues {
+		kinds[i] = issue.Kind
+	}
+	require.Equal(t, []IssueKind{
+		IssueOrphanReturn,
+		IssueOrphanInvoke,
+		IssueSQLMismatch,
+		IssueUnexpectedResume,
+		IssueUnexpectedBlock,
+		IssueUnresumedBlock,
+		IssueOrphanInvoke,
+	}, kinds)
+	require.Equal(t, 1, issues[1].Index)
+	require.Equal(t, `#3 s1: SQLMismatch: invoked "select 1" but returned "select 2"`, issues[2].String())
+	require.Equal(t, 7, issues[5].Index)
+	require.Equal(t, "s2", issues[6].Session)
+}
@@ -0,0 +1,197 @@
+package stmtflow
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zyguan/sqlz/internal/diff"
+	"github.com/zyguan/sqlz/resultset"
+)
+
+type DiffKind string
+
+const (
+	DiffMismatch DiffKind = "Mismatch"
+	DiffDelete   DiffKind = "Delete"
+	DiffInsert   DiffKind = "Insert"
+	// DiffColumnCountMismatch is a mismatch of returns whose results have different numbers of columns.
+	DiffColumnCountMismatch DiffKind = "ColumnCountMismatch"
+)
+
+type DiffOptions struct {
+	// Align pairs events by kind, session and SQL (longest common subsequence) instead of by position, so that
+	// statements added to or removed from one side show up as inserts/deletes while aligned pairs are still compared.
+	Align  bool
+	Digest resultset.DigestOptions
+	// Sessions restricts the comparison to events of the listed sessions, events of other sessions are skipped on both
+	// sides. Indexes of differences still refer to positions in the given histories. Empty means all sessions.
+	Sessions []string
+}
+
+type Difference struct {
+	Kind    DiffKind
+	Expect  int
+	Actual  int
+	Message string
+}
+
+func (d Difference) String() string {
+	switch d.Kind {
+	case DiffDelete:
+		return fmt.Sprintf("-[%d] %s", d.Expect, d.Message)
+	case DiffInsert:
+		return fmt.Sprintf("+[%d] %s", d.Actual, d.Message)
+	default:
+		return fmt.Sprintf("![%d:%d] %s", d.Expect, d.Actual, d.Message)
+	}
+}
+
+func Diff(expect History, actual History, opts DiffOptions) []Difference {
+	if len(opts.Sessions) > 0 {
+		return diffSessions(expect, actual, opts)
+	}
+	var diffs []Difference
+	compare := func(i int, j int) {
+		if ok, msg := expect[i].EqualTo(actual[j], opts.Digest); !ok {
+			kind := DiffMismatch
+			if expect[i].columnCountMismatch(&actual[j]) {
+				kind = DiffColumnCountMismatch
+			}
+			diffs = append(diffs, Difference{kind, i, j, msg})
+		}
+	}
+	deleted := func(i int) {
+		diffs = append(diffs, Difference{DiffDelete, i, -1, "missing " + expect[i].tag()})
+	}
+	inserted := func(j int) {
+		diffs = append(diffs, Difference{DiffInsert, -1, j, "unexpected " + actual[j].tag()})
+	}
+
+	if !opts.Align {
+		n := len(expect)
+		if len(actual) < n {
+			n = len(actual)
+		}
+		for i := 0; i < n; i++ {
+			compare(i, i)
+		}
+		for i := n; i < len(expect); i++ {
+			deleted(i)
+		}
+		for j := n; j < len(actual); j++ {
+			inserted(j)
+		}
+		return diffs
+	}
+
+	m, n := len(expect), len(actual)
+	ks1, ks2 := make([]string, m), make([]string, n)
+	for i := range expect {
+		ks1[i] = expect[i].alignKey()
+	}
+	for j := range actual {
+		ks2[j] = actual[j].alignKey()
+	}
+	// lcs[i][j] is the length of the longest common subsequence of ks1[i:] and ks2[j:].
+	lcs := make([][]int, m+1)
+	for i := range lcs {
+		lcs[i] = make([]int, n+1)
+	}
+	for i := m - 1; i >= 0; i-- {
+		for j := n - 1; j >= 0; j-- {
+			if ks1[i] == ks2[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < m && j < n {
+		if ks1[i] == ks2[j] {
+			compare(i, j)
+			i, j = i+1, j+1
+		} else if lcs[i+1][j] >= lcs[i][j+1] {
+			deleted(i)
+			i++
+		} else {
+			inserted(j)
+			j++
+		}
+	}
+	for ; i < m; i++ {
+		deleted(i)
+	}
+	for ; j < n; j++ {
+		inserted(j)
+	}
+	return diffs
+}
+
+func (e *Event) sql() string {
+	switch {
+	case e.inv != nil:
+		return e.inv.SQL
+	case e.ret != nil:
+		return e.ret.SQL
+	default:
+		return ""
+	}
+}
+
+func (e *Event) tag() string {
+	if sql := e.sql(); len(sql) > 0 {
+		return e.EventMeta.String() + "(" + sql + ")"
+	}
+	return e.EventMeta.String()
+}
+
+func (e *Event) alignKey() string { return string(e.Kind) + "\x00" + e.Session + "\x00" + e.sql() }
+
+// DiffText renders both histories as text (with timings stripped) and returns the differences in the unified diff
+// format, or an empty string if they render the same.
+func DiffText(expected History, actual History, opts TextDumpOptions) string {
+	a, b := textLines(expected.StripTimings().Text(opts)), textLines(actual.StripTimings().Text(opts))
+	return diff.Lines(a, b, "expected", "actual", 3)
+}
+
+func textLines(text string) []string {
+	if len(text) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+func diffSessions(expect History, actual History, opts DiffOptions) []Difference {
+	keep := make(map[string]bool, len(opts.Sessions))
+	for _, s := range opts.Sessions {
+		keep[s] = true
+	}
+	filter := func(h History) (History, []int) {
+		var (
+			out History
+			idx []int
+		)
+		for i, e := range h {
+			if keep[e.Session] {
+				out, idx = append(out, e), append(idx, i)
+			}
+		}
+		return out, idx
+	}
+	h1, idx1 := filter(expect)
+	h2, idx2 := filter(actual)
+	opts.Sessions = nil
+	diffs := Diff(h1, h2, opts)
+	for k := range diffs {
+		if diffs[k].Expect >= 0 {
+			diffs[k].Expect = idx1[diffs[k].Expect]
+		}
+		if diffs[k].Actual >= 0 {
+			diffs[k].Actual = idx2[diffs[k].Actual]
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,118 @@
+package stmtflow
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zyguan/sqlz/resultset"
+)
+
+func TestDiff(t *testing.T) {
+	i1, r1 := newInvRet("s1", "begin", &Error{0, "ok"})
+	i2, r2 := newInvRet("s1", "update t set v = v + 1", &Error{0, "ok"})
+	iw, rw := newInvRet("s2", "select * from t", &Error{0, "ok"})
+	i3, r3 := newInvRet("s1", "commit", &Error{0, "ok"})
+	_, r3x := newInvRet("s1", "commit", &Error{1213, "Deadlock found"})
+
+	expect := History{i1, r1, i2, r2, i3, r3}
+	actual := History{i1, r1, iw, rw, i2, r2, i3, r3x}
+
+	require.Empty(t, Diff(expect, expect, DiffOptions{}))
+	require.Empty(t, Diff(expect, expect, DiffOptions{Align: true}))
+
+	diffs := Diff(expect, actual, DiffOptions{})
+	require.Len(t, diffs, 6)
+	require.Equal(t, DiffInsert, diffs[4].Kind)
+	require.Equal(t, DiffInsert, diffs[5].Kind)
+
+	diffs = Diff(expect, actual, DiffOptions{Align: true})
+	require.Len(t, diffs, 3)
+	require.Equal(t, Difference{DiffInsert, -1, 2, "unexpected s2:invoke(select * from t)"}, diffs[0])
+	require.Equal(t, Difference{DiffInsert, -1, 3, "unexpected s2:return(select * from t)"}, diffs[1])
+	require.Equal(t, DiffMismatch, diffs[2].Kind)
+	require.Equal(t, [2]int{5, 7}, [2]int{diffs[2].Expect, diffs[2].Actual})
+
+	diffs = Diff(actual, expect, DiffOptions{Align: true})
+	require.Len(t, diffs, 3)
+	require.Equal(t, DiffDelete, diffs[0].Kind)
+	require.Equal(t, "-[2] missing s2:invoke(select * from t)", diffs[0].String())
+}
+
+func TestDiffSessions(t *testing.T) {
+	i1, r1 := newInvRet("s1", "begin", &Error{0, "ok"})
+	i2, r2 := newInvRet("s2", "select * from t", &Error{0, "ok"})
+	i3, r3 := newInvRet("s3", "commit", &Error{0, "ok"})
+	_, r3x := newInvRet("s3", "commit", &Error{1213, "Deadlock found"})
+	i4, r4 := newInvRet("s1", "commit", &Error{0, "ok"})
+	_, r4x := newInvRet("s1", "commit", &Error{1213, "Deadlock found"})
+
+	expect := History{i1, r1, i2, r2, i3, r3, i4, r4}
+	actual := History{i3, r3x, i1, r1, i4, r4}
+	require.NotEmpty(t, Diff(expect, actual, DiffOptions{}))
+	require.Empty(t, Diff(expect, actual, DiffOptions{Sessions: []string{"s1"}}))
+	require.Empty(t, Diff(expect, actual, DiffOptions{Sessions: []string{"s1"}, Align: true}))
+
+	diffs := Diff(expect, History{i1, r1, i2, r2, i4, r4x}, DiffOptions{Sessions: []string{"s1", "s2"}})
+	require.Len(t, diffs, 1)
+	require.Equal(t, [2]int{7, 5}, [2]int{diffs[0].Expect, diffs[0].Actual})
+
+	diffs = Diff(expect, actual, DiffOptions{Sessions: []string{"s1", "s2"}, Align: true})
+	require.Len(t, diffs, 2)
+	require.Equal(t, Difference{DiffDelete, 2, -1, "missing s2:invoke(select * from t)"}, diffs[0])
+	require.Equal(t, Difference{DiffDelete, 3, -1, "missing s2:return(select * from t)"}, diffs[1])
+}
+
+func TestDiffColumnCountMismatch(t *testing.T) {
+	e1 := newQueryRetEvent(t, "t", `{"columns":[{"name":"a","type":"INT"}],"rows":[[1]]}`)
+	e2 := newQueryRetEvent(t, "t", `{"columns":[{"name":"a","type":"INT"},{"name":"b","type":"INT"}],"rows":[[1,2]]}`)
+	e3 := newQueryRetEvent(t, "t", `{"columns":[{"name":"a","type":"INT"}],"rows":[[2]]}`)
+
+	ok, msg := e1.EqualTo(e2)
+	require.False(t, ok)
+	require.Equal(t, "t:return(select * from t): column count mismatch: expect 1 [a], got 2 [a b]", msg)
+
+	diffs := Diff(History{e1, e1}, History{e2, e3}, DiffOptions{})
+	require.Len(t, diffs, 2)
+	require.Equal(t, DiffColumnCountMismatch, diffs[0].Kind)
+	require.Equal(t, DiffMismatch, diffs[1].Kind)
+	require.Contains(t, diffs[1].Message, "digest")
+}
+
+func TestDiffText(t *testing.T) {
+	i1, r1 := newInvRet("s1", "begin", &Error{0, "ok"})
+	iw, rw := newInvRet("s2", "select * from t", &Error{0, "ok"})
+	i3, r3 := newInvRet("s1", "commit", &Error{0, "ok"})
+	_, r3x := newInvRet("s1", "commit", &Error{1213, "Deadlock found"})
+	i2 := NewInvokeEvent("s1", Invoke{Stmt{Sess: "s1", SQL: "update t set v = 1"}})
+	ret := func(t0 time.Time) Event {
+		return NewReturnEvent("s1", Return{
+			Stmt: Stmt{Sess: "s1", SQL: "update t set v = 1"},
+			Res:  resultset.NewFromResult(driver.RowsAffected(1)),
+			T:    [2]time.Time{t0, t0.Add(time.Second)},
+		})
+	}
+
+	expect := History{i1, r1, i2, ret(time.Unix(100, 0)), i3, r3}
+	actual := History{i1, r1, iw, rw, i2, ret(time.Unix(200, 0)), i3, r3x}
+	opts := TextDumpOptions{WithLat: true}
+	require.Empty(t, DiffText(expect, expect, opts))
+	require.Empty(t, DiffText(expect, History{i1, r1, i2, ret(time.Unix(300, 0)), i3, r3}, opts))
+	require.Equal(t, `--- expected
++++ actual
+@@ -1,7 +1,9 @@
+ /* s1 */ begin
+ -- s1 >> ok
++/* s2 */ select * from t
++-- s2 >> ok
+ /* s1 */ update t set v = 1
+ -- s1 >> 1 rows affected
+ -- s1    00:00:00.000 ~ 00:00:00.000 (cost 0s)
+ /* s1 */ commit
+--- s1 >> ok
++-- s1 >> E1213: Deadlock found
+`, DiffText(expect, actual, opts))
+	require.Equal(t, time.Unix(100, 0), expect[3].Return().T[0])
+	require.Contains(t, DiffText(nil, expect, opts), "@@ -0,0 +1,7 @@\n")
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"io"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/zyguan/sqlz/resultset"
@@ -16,6 +19,8 @@ var (
 	ErrConnNotExist = errors.New("connection not exist")
 	ErrConnBorrowed = errors.New("connection borrowed")
 	ErrPollTimeout  = errors.New("poll timeout")
+	// ErrMaxDuration is returned by Eval when the flow is aborted for exceeding EvalOptions.MaxDuration.
+	ErrMaxDuration = errors.New("flow exceeds max duration")
 )
 
 const (
@@ -28,6 +33,24 @@ type Pool struct {
 	wg    sync.WaitGroup
 	conns map[string]*sql.Conn
 	flags map[string]byte
+
+	readOpts resultset.ReadOptions
+	cancel   context.CancelFunc
+	classify func(error) error
+
+	captureRowStats bool
+}
+
+func (p *Pool) classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if p.classify != nil {
+		if classified := p.classify(err); classified != nil {
+			return classified
+		}
+	}
+	return WrapError(err)
 }
 
 type BorrowedConn struct {
@@ -76,6 +99,9 @@ func (p *Pool) Borrow(s string) (*BorrowedConn, error) {
 func (p *Pool) Wait() { p.wg.Wait() }
 
 func (p *Pool) Close() error {
+	if p.cancel != nil {
+		p.cancel()
+	}
 	var fstErr error
 	for _, c := range p.conns {
 		if err := c.Close(); fstErr == nil && err != nil {
@@ -105,12 +131,17 @@ const (
 	S_QUERY uint = 1 << iota
 	S_WAIT
 	S_UNORDERED
+	// S_IGNORE_ERROR marks a statement whose error is tolerated: it's still recorded, but it doesn't stop the flow
+	// (see EvalOptions.StopOnError) and it's expected by EqualTo.
+	S_IGNORE_ERROR
 )
 
 type Stmt struct {
 	Sess  string `json:"s"`
 	SQL   string `json:"q"`
 	Flags uint   `json:"flags,omitempty"`
+	// Capture names the variable the statement's return is captured into, see EvalOptions.Vars.
+	Capture string `json:"capture,omitempty"`
 }
 
 func (s Stmt) Session() string { return s.Sess }
@@ -128,30 +159,67 @@ func (s Stmt) Poll(ctx context.Context, c *BorrowedConn, w time.Duration) (Sessi
 			c.Return()
 			close(f)
 		}()
-		if s.Flags&S_QUERY > 0 {
-			t0 := time.Now()
-			rows, err := c.QueryContext(ctx, s.SQL)
-			if err != nil {
-				f <- Return{s, nil, WrapError(err), [2]time.Time{t0, time.Now()}}
-				return
-			}
-			defer rows.Close()
-			res, err := resultset.ReadFromRows(rows)
-			f <- Return{s, res, WrapError(err), [2]time.Time{t0, time.Now()}}
-		} else {
-			t0 := time.Now()
-			res, err := c.ExecContext(ctx, s.SQL)
-			if err != nil {
-				f <- Return{s, nil, WrapError(err), [2]time.Time{t0, time.Now()}}
-				return
+		if !c.pool.captureRowStats {
+			f <- s.exec(ctx, c)
+			return
+		}
+		before, err := c.handlerReads(ctx)
+		ret := s.exec(ctx, c)
+		if err == nil {
+			if after, err := c.handlerReads(ctx); err == nil {
+				ret.RowStats = &RowStats{Examined: after - before}
+				if ret.Res != nil && !ret.Res.IsExecResult() {
+					ret.RowStats.Returned = int64(ret.Res.NRows())
+				}
 			}
-			f <- Return{s, resultset.NewFromResult(res), nil, [2]time.Time{t0, time.Now()}}
 		}
+		f <- ret
 	}()
 	r := RunningStmt{s, f}
 	return r.Poll(ctx, c, w)
 }
 
+func (s Stmt) exec(ctx context.Context, c *BorrowedConn) Return {
+	t0 := time.Now()
+	if s.Flags&S_QUERY > 0 {
+		rows, err := c.QueryContext(ctx, s.SQL)
+		if err != nil {
+			return Return{Stmt: s, Err: c.pool.classifyError(err), T: [2]time.Time{t0, time.Now()}}
+		}
+		defer rows.Close()
+		res, err := resultset.ReadFromRowsWithOptions(rows, c.pool.readOpts)
+		return Return{Stmt: s, Res: res, Err: c.pool.classifyError(err), T: [2]time.Time{t0, time.Now()}}
+	}
+	res, err := c.ExecContext(ctx, s.SQL)
+	if err != nil {
+		return Return{Stmt: s, Err: c.pool.classifyError(err), T: [2]time.Time{t0, time.Now()}}
+	}
+	return Return{Stmt: s, Res: resultset.NewFromResult(res), T: [2]time.Time{t0, time.Now()}}
+}
+
+// handlerReads sums up the Handler_read_* status variables of the session, which approximates the number of rows the
+// session has examined so far. Note that the status query itself may count a few reads.
+func (c *BorrowedConn) handlerReads(ctx context.Context) (int64, error) {
+	rows, err := c.QueryContext(ctx, `SHOW SESSION STATUS LIKE 'Handler\_read\_%'`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	var total int64
+	for rows.Next() {
+		var name, value string
+		if err = rows.Scan(&name, &value); err != nil {
+			return 0, err
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("parse %s: %w", name, err)
+		}
+		total += n
+	}
+	return total, rows.Err()
+}
+
 type RunningStmt struct {
 	Stmt
 	future <-chan Return
@@ -204,6 +272,37 @@ type Return struct {
 	Res *resultset.ResultSet
 	Err error
 	T   [2]time.Time
+
+	// Digest is the data digest of a result loaded from a digest-only dump, in which case Res is nil.
+	Digest string
+	// RowStats is captured only if EvalOptions.CaptureRowStats is set.
+	RowStats *RowStats
+
+	lazy *lazyResult
+}
+
+// RowStats compares the number of rows a statement examined with the number of rows it returned, a large gap usually
+// indicates a full scan.
+type RowStats struct {
+	// Examined is the delta of Handler_read_* status variables around the statement.
+	Examined int64 `json:"examined"`
+	// Returned is the number of rows in the result, it's zero for non-query statements.
+	Returned int64 `json:"returned"`
+}
+
+func (s RowStats) String() string {
+	return fmt.Sprintf("examined=%d returned=%d", s.Examined, s.Returned)
+}
+
+// Truncated reports whether the result was truncated when it was read (see EvalOptions.MaxResultRows). The truncation
+// of a digest-only result is not known, it's part of the digest though.
+func (r Return) Truncated() bool { return r.Res != nil && r.Res.Truncated() }
+
+func (r Return) digest(opts resultset.DigestOptions) string {
+	if r.Res == nil {
+		return r.Digest
+	}
+	return r.Res.DataDigest(opts)
 }
 
 type Waitable interface{ Wait() }
@@ -217,8 +316,54 @@ type EvalOptions struct {
 	PingTime  time.Duration
 	BlockTime time.Duration
 	Callback  func(e Event)
+	// MaxResultBytes stops reading rows of a query once its result would exceed the given size, the return is then
+	// marked as truncated. Zero means no limit.
+	MaxResultBytes int
+	// MaxResultRows stops reading rows of a query once it has read the given number of rows and there are more, the
+	// return is then marked as truncated. Zero means no limit.
+	MaxResultRows int
+	// SessionInit maps a session to statements executed right after its connection is established. They are
+	// reported to the callback as ordinary invoke/return events before the flow starts.
+	SessionInit map[string][]string
+	// Sessions configure connections of sessions, their statements (see SessionConfig.Stmts) are executed like
+	// SessionInit, before the ones of SessionInit. Sessions not in the flow are ignored.
+	Sessions []SessionConfig
+	// SessionMap resolves the database a session connects to. It's called once per session, in the order sessions
+	// first appear, when the flow is set up. Sessions mapped to nil use the database passed to Eval.
+	SessionMap func(session string) (*sql.DB, error)
+	// StopOnError stops the flow once a statement returns an unexpected error. Running statements are canceled and
+	// their returns reported, the pending ones are reported as skip events. Eval returns no error in this case.
+	StopOnError bool
+	// ErrorClassifier converts errors returned by the driver into the errors recorded in returns, so that drivers
+	// other than MySQL can still report structured error codes (typically by returning an *Error). It defaults to
+	// WrapError, which is also applied when the classifier returns nil.
+	ErrorClassifier func(err error) error
+	// CaptureRowStats records rows examined and returned by each statement in Return.RowStats. Rows examined are
+	// derived from MySQL's Handler_read_* session status, which costs two extra queries per statement.
+	CaptureRowStats bool
+	// StepController, if set, is consulted before invoking each statement of the flow, it may pause the flow until the
+	// statement is allowed to run. Statements of SessionInit are not paused.
+	StepController *StepController
+	// MaxDuration aborts the flow once it has run (since connections are established) for the given duration, zero
+	// means no limit. The flow is stopped like StopOnError does: running statements are canceled and their returns
+	// reported, the pending ones are reported as skip events, and then an abort event is reported. Eval returns an
+	// error wrapping ErrMaxDuration in this case. Canceling a statement blocked by a lock closes the connection of the
+	// session (the MySQL driver does so), however the server may not notice it until the lock wait ends, so locks
+	// held by the session can outlive the flow for a while.
+	MaxDuration time.Duration
+	// Vars, if set, stores values captured by statements (see Stmt.Capture) and interpolates references to them into
+	// the SQL of statements right before they are invoked, invoke and return events record the interpolated SQL. A
+	// statement referencing an undefined variable returns an error without being executed. Nil disables both captures
+	// and interpolation.
+	Vars *Vars
+	// WithSeq assigns each event a sequence number (see EventMeta.Seq) drawn from a counter shared by all flows of the
+	// process, so that events of flows evaluated concurrently can be merged in order (see Merge).
+	WithSeq bool
 }
 
+// eventSeq is the last sequence number assigned to events.
+var eventSeq int64
+
 func Run(ctx context.Context, db *sql.DB, stmts []Stmt, opts EvalOptions) error {
 	w, err := Eval(ctx, db, stmts, opts)
 	if w != nil {
@@ -229,14 +374,56 @@ func Run(ctx context.Context, db *sql.DB, stmts []Stmt, opts EvalOptions) error
 }
 
 func Eval(ctx context.Context, db *sql.DB, stmts []Stmt, opts EvalOptions) (WaitableCloser, error) {
-	pool, head, err := initForEval(ctx, db, stmts)
+	pool, head, err := initForEval(ctx, db, stmts, opts.SessionMap)
 	if err != nil {
 		return nil, err
 	}
+	pool.readOpts.MaxBytes = int64(opts.MaxResultBytes)
+	pool.readOpts.MaxRows = opts.MaxResultRows
+	pool.classify = opts.ErrorClassifier
+	pool.captureRowStats = opts.CaptureRowStats
+	parent, cancelTimeout := ctx, context.CancelFunc(func() {})
+	if opts.MaxDuration > 0 {
+		ctx, cancelTimeout = context.WithTimeout(ctx, opts.MaxDuration)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	pool.cancel = func() {
+		cancel()
+		cancelTimeout()
+	}
 	callback := opts.Callback
 	if callback == nil {
 		callback = func(_ Event) {}
 	}
+	if traceID, spanID := TraceFromContext(ctx); len(traceID) > 0 || len(spanID) > 0 {
+		cb := callback
+		callback = func(e Event) {
+			e.TraceID, e.SpanID = traceID, spanID
+			cb(e)
+		}
+	}
+	if opts.WithSeq {
+		cb := callback
+		callback = func(e Event) {
+			e.Seq = atomic.AddInt64(&eventSeq, 1)
+			cb(e)
+		}
+	}
+	err = evalFlow(ctx, pool, head, stmts, opts, callback)
+	if err != nil && opts.MaxDuration > 0 && errors.Is(err, context.DeadlineExceeded) && parent.Err() == nil {
+		if serr := stopFlow(pool, head, callback); serr != nil {
+			return pool, serr
+		}
+		err = fmt.Errorf("%w: %s", ErrMaxDuration, opts.MaxDuration)
+		callback(NewAbortEvent(err))
+	}
+	return pool, err
+}
+
+func evalFlow(ctx context.Context, pool *Pool, head *stmtNode, stmts []Stmt, opts EvalOptions, callback func(Event)) error {
+	if err := initSessions(ctx, pool, stmts, sessionInitStmts(opts), callback); err != nil {
+		return err
+	}
 	for head.next != nil {
 		for p := head; p.next != nil; p = p.next {
 			stmt := p.next.stmt
@@ -253,7 +440,7 @@ func Eval(ctx context.Context, db *sql.DB, stmts []Stmt, opts EvalOptions) (Wait
 					case <-done:
 						p.waited = true
 					case <-ctx.Done():
-						return pool, ctx.Err()
+						return ctx.Err()
 					}
 					break
 				}
@@ -262,21 +449,51 @@ func Eval(ctx context.Context, db *sql.DB, stmts []Stmt, opts EvalOptions) (Wait
 					if err == ErrConnBorrowed {
 						continue
 					}
-					return pool, err
+					return err
+				}
+				x, ierr := stmt.Statement(), error(nil)
+				if opts.Vars != nil {
+					x.SQL, ierr = opts.Vars.Interpolate(x.SQL)
 				}
-				callback(NewInvokeEvent(stmt.Session(), Invoke{stmt.Statement()}))
+				// the controller sees what's going to be invoked, that is, the interpolated statement
+				if err = opts.StepController.wait(ctx, x); err != nil {
+					c.Return()
+					return err
+				}
+				if ierr != nil {
+					c.Return()
+					ret := Return{Stmt: x, Err: ierr, T: [2]time.Time{time.Now(), time.Now()}}
+					callback(NewInvokeEventWithContext(ctx, stmt.Session(), Invoke{x}))
+					callback(NewReturnEvent(stmt.Session(), ret))
+					p.next = p.next.next
+					if opts.StopOnError && isUnexpected(ret) {
+						return stopFlow(pool, head, callback)
+					}
+					break
+				}
+				if opts.Vars != nil {
+					stmt = x
+				}
+				callback(NewInvokeEventWithContext(ctx, stmt.Session(), Invoke{stmt.Statement()}))
 				s, err := stmt.Poll(ctx, c, opts.BlockTime)
 				if err != nil {
 					if err == ErrPollTimeout {
 						callback(NewBlockEvent(stmt.Session()))
-						p.next.stmt = s
+						p.next.stmt, p.next.blocked = s, true
 						continue
 					}
-					return pool, err
+					// keep the running statement, so that it can still be resolved by stopFlow
+					p.next.stmt = s
+					return err
 				}
 				// Assert typeof(s) == CompletedStmt
-				callback(NewReturnEvent(stmt.Session(), s.Result()))
+				ret := s.Result()
+				callback(NewReturnEvent(stmt.Session(), ret))
 				p.next = p.next.next
+				opts.Vars.capture(ret)
+				if opts.StopOnError && isUnexpected(ret) {
+					return stopFlow(pool, head, callback)
+				}
 				break
 			} else if status == Running {
 				s, err := stmt.Poll(ctx, nil, opts.PingTime)
@@ -285,49 +502,149 @@ func Eval(ctx context.Context, db *sql.DB, stmts []Stmt, opts EvalOptions) (Wait
 						p.next.stmt = s
 						continue
 					}
-					return pool, err
+					return err
 				}
 				// Assert typeof(s) == CompletedStmt
+				ret := s.Result()
 				callback(NewResumeEvent(stmt.Session()))
-				callback(NewReturnEvent(stmt.Session(), s.Result()))
+				callback(NewReturnEvent(stmt.Session(), ret))
 				p.next = p.next.next
+				opts.Vars.capture(ret)
+				if opts.StopOnError && isUnexpected(ret) {
+					return stopFlow(pool, head, callback)
+				}
 				break
 			} else {
-				return pool, errors.New("invalid statement status: " + string(stmt.Status()))
+				return errors.New("invalid statement status: " + string(stmt.Status()))
 			}
 		}
 	}
-	return pool, nil
+	return nil
+}
+
+func isUnexpected(ret Return) bool { return ret.Err != nil && ret.Stmt.Flags&S_IGNORE_ERROR == 0 }
+
+func stopFlow(pool *Pool, head *stmtNode, callback func(Event)) error {
+	pool.cancel()
+	for p := head.next; p != nil; p = p.next {
+		if p.stmt.Status() != Running {
+			continue
+		}
+		// the statement context has been canceled, so just wait for the future to be resolved
+		s, err := p.stmt.Poll(context.Background(), nil, 0)
+		if err != nil {
+			return err
+		}
+		if p.blocked {
+			callback(NewResumeEvent(s.Session()))
+		}
+		callback(NewReturnEvent(s.Session(), s.Result()))
+	}
+	for p := head.next; p != nil; p = p.next {
+		if p.stmt.Status() == Pending {
+			callback(NewSkipEvent(p.stmt.Session(), Invoke{p.stmt.Statement()}))
+		}
+	}
+	return nil
+}
+
+// sessionInitStmts collects statements of opts.Sessions and opts.SessionInit by sessions.
+func sessionInitStmts(opts EvalOptions) map[string][]Stmt {
+	init := make(map[string][]Stmt)
+	for _, c := range opts.Sessions {
+		init[c.Name] = append(init[c.Name], c.Stmts()...)
+	}
+	for s, qs := range opts.SessionInit {
+		for _, q := range qs {
+			init[s] = append(init[s], Stmt{Sess: s, SQL: q})
+		}
+	}
+	return init
+}
+
+func initSessions(ctx context.Context, pool *Pool, stmts []Stmt, init map[string][]Stmt, callback func(Event)) error {
+	if len(init) == 0 {
+		return nil
+	}
+	done := make(map[string]bool, len(init))
+	for _, stmt := range stmts {
+		s := stmt.Session()
+		if done[s] {
+			continue
+		}
+		done[s] = true
+		for _, stmt := range init[s] {
+			c, err := pool.Borrow(s)
+			if err != nil {
+				return err
+			}
+			callback(NewInvokeEvent(s, Invoke{stmt}))
+			res, err := stmt.Poll(ctx, c, 0)
+			if err != nil {
+				return err
+			}
+			ret := res.Result()
+			callback(NewReturnEvent(s, ret))
+			if ret.Err != nil {
+				return fmt.Errorf("init session %s: %w", s, ret.Err)
+			}
+		}
+	}
+	return nil
 }
 
 type stmtNode struct {
 	stmt SessionStmt
 	next *stmtNode
 
-	waited bool
+	waited  bool
+	blocked bool
 }
 
-func initForEval(ctx context.Context, db *sql.DB, stmts []Stmt) (*Pool, *stmtNode, error) {
+func initForEval(ctx context.Context, db *sql.DB, stmts []Stmt, sessionMap func(string) (*sql.DB, error)) (*Pool, *stmtNode, error) {
 	p := &Pool{
 		conns: map[string]*sql.Conn{},
 		flags: map[string]byte{},
 	}
-	h := &stmtNode{}
-	m := make(map[string]bool, 2)
-	for i := len(stmts) - 1; i >= 0; i-- {
-		stmt := stmts[i]
+	for _, stmt := range stmts {
 		s := stmt.Session()
-		h.next = &stmtNode{stmt, h.next, false}
-		if !m[s] {
-			c, err := db.Conn(ctx)
-			if err != nil {
-				return nil, nil, err
-			}
-			if err = p.Put(s, c); err != nil {
-				return nil, nil, err
-			}
-			m[s] = true
+		if p.flags[s]&flagExist > 0 {
+			continue
 		}
+		sdb, err := sessionDB(db, sessionMap, s)
+		if err != nil {
+			p.Close()
+			return nil, nil, err
+		}
+		c, err := sdb.Conn(ctx)
+		if err != nil {
+			p.Close()
+			return nil, nil, err
+		}
+		if err = p.Put(s, c); err != nil {
+			p.Close()
+			return nil, nil, err
+		}
+	}
+	h := &stmtNode{}
+	for i := len(stmts) - 1; i >= 0; i-- {
+		h.next = &stmtNode{stmt: stmts[i], next: h.next}
 	}
 	return p, h, nil
 }
+
+func sessionDB(db *sql.DB, sessionMap func(string) (*sql.DB, error), s string) (*sql.DB, error) {
+	if sessionMap != nil {
+		mapped, err := sessionMap(s)
+		if err != nil {
+			return nil, fmt.Errorf("map session %s: %w", s, err)
+		}
+		if mapped != nil {
+			db = mapped
+		}
+	}
+	if db == nil {
+		return nil, fmt.Errorf("map session %s: no database", s)
+	}
+	return db, nil
+}
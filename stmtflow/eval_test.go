@@ -0,0 +1,411 @@
+package stmtflow
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+	"github.com/zyguan/sqlz/resultset"
+)
+
+var opts struct {
+	mysqlDSN string
+}
+
+func init() {
+	flag.StringVar(&opts.mysqlDSN, "mysql-dsn", "root:@tcp(127.0.0.1:4000)/test", "mysql dsn")
+}
+
+func testDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("mysql", opts.mysqlDSN)
+	require.NoError(t, err)
+	if err := db.Ping(); err != nil {
+		t.Skipf("failed to ping target mysql: dsn=%s, err=%v", opts.mysqlDSN, err)
+	}
+	return db
+}
+
+func TestEvalSessionMap(t *testing.T) {
+	stmts := []Stmt{{Sess: "s1", SQL: "select 1", Flags: S_QUERY}, {Sess: "s2", SQL: "select 2", Flags: S_QUERY}}
+	oops := errors.New("oops")
+	err := Run(context.Background(), nil, stmts, EvalOptions{SessionMap: func(s string) (*sql.DB, error) {
+		return nil, oops
+	}})
+	require.True(t, errors.Is(err, oops))
+	require.EqualError(t, Run(context.Background(), nil, stmts, EvalOptions{}), "map session s1: no database")
+
+	db := testDB(t)
+	defer db.Close()
+	var mapped []string
+	var h History
+	err = Run(context.Background(), nil, stmts, EvalOptions{
+		Callback: h.Collect,
+		SessionMap: func(s string) (*sql.DB, error) {
+			mapped = append(mapped, s)
+			return db, nil
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"s1", "s2"}, mapped)
+	require.Len(t, h, 4)
+}
+
+func TestEvalStopOnError(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	stmts := []Stmt{
+		{Sess: "s1", SQL: "select 1", Flags: S_QUERY},
+		{Sess: "s2", SQL: "select * from no_such_table", Flags: S_QUERY},
+		{Sess: "s1", SQL: "select 2", Flags: S_QUERY},
+		{Sess: "s2", SQL: "select 3", Flags: S_QUERY},
+	}
+	var h History
+	require.NoError(t, Run(context.Background(), db, stmts, EvalOptions{Callback: h.Collect, StopOnError: true}))
+	require.Len(t, h, 6)
+	require.Error(t, h[3].Return().Err)
+	require.True(t, h[4].IsKind(EventSkip))
+	require.Equal(t, "select 2", h[4].Invoke().SQL)
+	require.True(t, h[5].IsKind(EventSkip))
+	require.Equal(t, "select 3", h[5].Invoke().SQL)
+}
+
+func TestEvalStopOnErrorBlocked(t *testing.T) {
+	db, err := sql.Open("stmtflow-fake", "")
+	require.NoError(t, err)
+	defer db.Close()
+	stmts := []Stmt{
+		{Sess: "s1", SQL: "sleep 200ms"},
+		{Sess: "s2", SQL: "fail"},
+		{Sess: "s1", SQL: "select 1", Flags: S_QUERY},
+	}
+	var h History
+	require.NoError(t, Run(context.Background(), db, stmts, EvalOptions{Callback: h.Collect, BlockTime: 50 * time.Millisecond, StopOnError: true}))
+	var events []string
+	for _, e := range h {
+		events = append(events, e.Session+" "+string(e.Kind))
+	}
+	require.Equal(t, []string{"s1 Invoke", "s1 Block", "s2 Invoke", "s2 Return", "s1 Resume", "s1 Return", "s1 Skip"}, events)
+	require.Empty(t, h.CheckConsistency())
+}
+
+func TestEvalSessionInit(t *testing.T) {
+	db, err := sql.Open("stmtflow-fake", "")
+	require.NoError(t, err)
+	defer db.Close()
+	stmts := []Stmt{
+		{Sess: "s1", SQL: "select 1", Flags: S_QUERY},
+		{Sess: "s2", SQL: "select 2", Flags: S_QUERY},
+	}
+	events := func(h History) []string {
+		var xs []string
+		for _, e := range h {
+			switch e.Kind {
+			case EventInvoke:
+				xs = append(xs, e.Session+" invoke: "+e.Invoke().SQL)
+			case EventReturn:
+				xs = append(xs, e.Session+" return: "+e.Return().SQL)
+			}
+		}
+		return xs
+	}
+
+	var h History
+	require.NoError(t, Run(context.Background(), db, stmts, EvalOptions{
+		Callback:    h.Collect,
+		SessionInit: map[string][]string{"s2": {"set @a = 1", "set @b = 2"}, "s3": {"set @c = 3"}},
+	}))
+	require.Equal(t, []string{
+		"s2 invoke: set @a = 1", "s2 return: set @a = 1",
+		"s2 invoke: set @b = 2", "s2 return: set @b = 2",
+		"s1 invoke: select 1", "s1 return: select 1",
+		"s2 invoke: select 2", "s2 return: select 2",
+	}, events(h))
+	require.True(t, h[1].Return().Res.IsExecResult())
+	require.Empty(t, h.CheckConsistency())
+
+	// a failed init statement stops the flow before it starts
+	h = nil
+	err = Run(context.Background(), db, stmts, EvalOptions{
+		Callback:    h.Collect,
+		SessionInit: map[string][]string{"s1": {"fail"}, "s2": {"set @a = 1"}},
+	})
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "init session s1: "), err.Error())
+	require.Equal(t, []string{"s1 invoke: fail", "s1 return: fail"}, events(h))
+	require.Error(t, h[1].Return().Err)
+}
+
+func TestDumpTextWithExplainPlan(t *testing.T) {
+	stmt := Stmt{Sess: "s1", SQL: "select 1", Flags: S_QUERY}
+	buf := new(bytes.Buffer)
+	ev := NewInvokeEvent("s1", Invoke{stmt})
+	ev.DumpText(buf, TextDumpOptions{WithExplainPlan: true})
+	require.Equal(t, "/* s1 */ select 1\n", buf.String())
+
+	fake, err := sql.Open("stmtflow-fake", "")
+	require.NoError(t, err)
+	defer fake.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	buf.Reset()
+	ev.DumpText(buf, TextDumpOptions{WithExplainPlan: true, DB: fake, Context: ctx})
+	require.True(t, strings.HasPrefix(buf.String(), "/* s1 */ select 1\n-- s1 >> explain: "), buf.String())
+	require.Contains(t, buf.String(), context.Canceled.Error())
+
+	db := testDB(t)
+	defer db.Close()
+	buf.Reset()
+	ev.DumpText(buf, TextDumpOptions{WithExplainPlan: true, DB: db})
+	require.True(t, strings.HasPrefix(buf.String(), "/* s1 */ select 1\n-- s1 >> explain"))
+
+	buf.Reset()
+	ev = NewInvokeEvent("s1", Invoke{Stmt{Sess: "s1", SQL: "begin"}})
+	ev.DumpText(buf, TextDumpOptions{WithExplainPlan: true, DB: db})
+	require.Equal(t, "/* s1 */ begin\n", buf.String())
+}
+
+func TestEvalErrorClassifier(t *testing.T) {
+	db, err := sql.Open("stmtflow-fake", "")
+	require.NoError(t, err)
+	defer db.Close()
+	stmts := []Stmt{
+		{Sess: "s1", SQL: "select 1", Flags: S_QUERY},
+		{Sess: "s2", SQL: "fail to update"},
+		{Sess: "s1", SQL: "fail to select", Flags: S_QUERY},
+		{Sess: "s2", SQL: "update t set v = 1"},
+	}
+	var h History
+	require.NoError(t, Run(context.Background(), db, stmts, EvalOptions{Callback: h.Collect}))
+	require.Len(t, h, 8)
+	require.Equal(t, &Error{-1, "fake error"}, h[3].Return().Err)
+
+	h = nil
+	require.NoError(t, Run(context.Background(), db, stmts, EvalOptions{
+		Callback: h.Collect,
+		ErrorClassifier: func(err error) error {
+			var fe fakeError
+			if errors.As(err, &fe) {
+				return &Error{Code: fe.code, Message: fe.Error()}
+			}
+			return nil
+		},
+	}))
+	require.Len(t, h, 8)
+	require.Equal(t, "select 1", h[1].Return().Res.ToMaps()[0]["sql"])
+	require.Equal(t, &Error{42, "fake error"}, h[3].Return().Err)
+	require.Equal(t, &Error{42, "fake error"}, h[5].Return().Err)
+	require.Equal(t, int64(1), h[7].Return().Res.ExecResult().RowsAffected)
+}
+
+func TestEvalIgnoreError(t *testing.T) {
+	db, err := sql.Open("stmtflow-fake", "")
+	require.NoError(t, err)
+	defer db.Close()
+	stmts := []Stmt{
+		{Sess: "s1", SQL: "fail to drop table", Flags: S_IGNORE_ERROR},
+		{Sess: "s1", SQL: "create table t (id int)"},
+		{Sess: "s2", SQL: "fail to insert"},
+		{Sess: "s1", SQL: "select 1", Flags: S_QUERY},
+	}
+	var h History
+	require.NoError(t, Run(context.Background(), db, stmts, EvalOptions{Callback: h.Collect, StopOnError: true}))
+	require.Len(t, h, 7)
+	require.Error(t, h[1].Return().Err)
+	require.NoError(t, h[3].Return().Err)
+	require.Error(t, h[5].Return().Err)
+	require.True(t, h[6].IsKind(EventSkip))
+
+	// the ignored error is expected no matter what the statement returns
+	ok, msg := h[1].EqualTo(NewReturnEvent("s1", Return{Stmt: stmts[0]}))
+	require.True(t, ok, msg)
+	_, ret := newInvRet("s2", "fail to insert", nil)
+	ok, _ = h[5].EqualTo(ret)
+	require.False(t, ok)
+}
+
+func TestEvalCaptureRowStats(t *testing.T) {
+	db, err := sql.Open("stmtflow-fake", "")
+	require.NoError(t, err)
+	defer db.Close()
+	stmts := []Stmt{
+		{Sess: "s1", SQL: "select 1", Flags: S_QUERY},
+		{Sess: "s1", SQL: "update t set v = 1"},
+	}
+	var h History
+	require.NoError(t, Run(context.Background(), db, stmts, EvalOptions{Callback: h.Collect}))
+	require.Nil(t, h[1].Return().RowStats)
+
+	h = nil
+	require.NoError(t, Run(context.Background(), db, stmts, EvalOptions{Callback: h.Collect, CaptureRowStats: true}))
+	require.Len(t, h, 4)
+	require.Equal(t, &RowStats{Examined: fakeReadsPerStmt, Returned: 1}, h[1].Return().RowStats)
+	require.Equal(t, &RowStats{Examined: fakeReadsPerStmt}, h[3].Return().RowStats)
+	require.Contains(t, h[1].Text(TextDumpOptions{Verbose: true}), "-- s1    examined=10 returned=1\n")
+	require.NotContains(t, h[1].Text(TextDumpOptions{}), "examined=")
+
+	js, err := json.Marshal(h[1])
+	require.NoError(t, err)
+	var ev Event
+	require.NoError(t, json.Unmarshal(js, &ev))
+	require.Equal(t, h[1].Return().RowStats, ev.Return().RowStats)
+}
+
+func TestEvalMaxResultRows(t *testing.T) {
+	db, err := sql.Open("stmtflow-fake", "")
+	require.NoError(t, err)
+	defer db.Close()
+	stmts := []Stmt{
+		{Sess: "s1", SQL: "select * from seq_10", Flags: S_QUERY},
+		{Sess: "s1", SQL: "select * from seq_3", Flags: S_QUERY},
+	}
+	var h History
+	require.NoError(t, Run(context.Background(), db, stmts, EvalOptions{Callback: h.Collect, MaxResultRows: 3}))
+	require.Len(t, h, 4)
+	ret := h[1].Return()
+	require.True(t, ret.Truncated())
+	require.Equal(t, 3, ret.Res.NRows())
+	require.Contains(t, h[1].Text(TextDumpOptions{}), "-- s1 >> 3 rows in set (truncated at 3 rows)\n")
+	require.Contains(t, h[1].Text(TextDumpOptions{Verbose: true}), "-- s1    (truncated at 3 rows)\n")
+	require.False(t, h[3].Return().Truncated())
+	// a truncated result never digests the same as a complete one
+	require.NotEqual(t, ret.Res.DataDigest(resultset.DigestOptions{}), h[3].Return().Res.DataDigest(resultset.DigestOptions{}))
+
+	js, err := json.Marshal(h[1])
+	require.NoError(t, err)
+	var ev Event
+	require.NoError(t, json.Unmarshal(js, &ev))
+	require.True(t, ev.Return().Res.Truncated())
+	ok, msg := ev.EqualTo(h[1])
+	require.True(t, ok, msg)
+}
+
+func TestEvalMaxResultBytes(t *testing.T) {
+	db, err := sql.Open("stmtflow-fake", "")
+	require.NoError(t, err)
+	defer db.Close()
+	stmts := []Stmt{{Sess: "s1", SQL: "select * from seq_20", Flags: S_QUERY}}
+	var h History
+	// rows 1 to 9 take 9 bytes, row 10 takes 2 bytes
+	require.NoError(t, Run(context.Background(), db, stmts, EvalOptions{Callback: h.Collect, MaxResultBytes: 10}))
+	res := h[1].Return().Res
+	require.True(t, h[1].Return().Truncated())
+	require.Equal(t, 9, res.NRows())
+	require.Equal(t, int64(9), res.ApproxBytes())
+	require.Contains(t, h[1].Text(TextDumpOptions{}), "(truncated at 9 rows)")
+
+	h = nil
+	require.NoError(t, Run(context.Background(), db, stmts, EvalOptions{Callback: h.Collect, MaxResultBytes: 11, MaxResultRows: 5}))
+	require.Equal(t, 5, h[1].Return().Res.NRows())
+	require.True(t, h[1].Return().Res.Truncated())
+}
+
+func TestEvalMaxDuration(t *testing.T) {
+	db, err := sql.Open("stmtflow-fake", "")
+	require.NoError(t, err)
+	defer db.Close()
+	stmts := []Stmt{
+		{Sess: "s1", SQL: "sleep 300ms"},
+		{Sess: "s1", SQL: "select 1", Flags: S_QUERY},
+	}
+	kinds := func(h History) []EventKind {
+		ks := make([]EventKind, len(h))
+		for i, e := range h {
+			ks[i] = e.Kind
+		}
+		return ks
+	}
+
+	var h History
+	err = Run(context.Background(), db, stmts, EvalOptions{Callback: h.Collect, BlockTime: 50 * time.Millisecond, MaxDuration: 100 * time.Millisecond})
+	require.True(t, errors.Is(err, ErrMaxDuration))
+	require.Equal(t, []EventKind{EventInvoke, EventBlock, EventResume, EventReturn, EventSkip, EventAbort}, kinds(h))
+	require.Empty(t, h.CheckConsistency())
+	require.Equal(t, "-- aborted: "+err.Error()+"\n", h[5].Text(TextDumpOptions{}))
+	require.Equal(t, 1, h.ComputeMetrics().Sessions)
+
+	// the deadline is reached before the statement is reported as blocked
+	h = nil
+	err = Run(context.Background(), db, stmts, EvalOptions{Callback: h.Collect, BlockTime: time.Second, MaxDuration: 100 * time.Millisecond})
+	require.True(t, errors.Is(err, ErrMaxDuration))
+	require.Equal(t, []EventKind{EventInvoke, EventReturn, EventSkip, EventAbort}, kinds(h))
+	require.Empty(t, h.CheckConsistency())
+
+	js, err := json.Marshal(h[3])
+	require.NoError(t, err)
+	var ev Event
+	require.NoError(t, json.Unmarshal(js, &ev))
+	require.Equal(t, EventAbort, ev.Kind)
+	require.Equal(t, WrapError(h[3].ret.Err), ev.ret.Err)
+
+	// a flow finished in time is not affected
+	h = nil
+	require.NoError(t, Run(context.Background(), db, stmts[1:], EvalOptions{Callback: h.Collect, MaxDuration: time.Second}))
+	require.Len(t, h, 2)
+}
+
+func TestEvalVars(t *testing.T) {
+	db, err := sql.Open("stmtflow-fake", "")
+	require.NoError(t, err)
+	defer db.Close()
+	stmts := []Stmt{
+		{Sess: "s1", SQL: "select * from seq_3", Flags: S_QUERY, Capture: "n"},
+		{Sess: "s2", SQL: "update t set v = ${n} where id = ${n}"},
+		{Sess: "s2", SQL: "update t set v = ${m}"},
+	}
+	vars := NewVars()
+	var h History
+	require.NoError(t, Run(context.Background(), db, stmts, EvalOptions{Callback: h.Collect, Vars: vars}))
+	require.Len(t, h, 6)
+	n, ok := vars.Get("n")
+	require.True(t, ok)
+	require.Equal(t, "1", n)
+	require.Equal(t, "update t set v = 1 where id = 1", h[2].Invoke().SQL)
+	require.Equal(t, "update t set v = 1 where id = 1", h[3].Return().Stmt.SQL)
+	require.EqualError(t, h[5].Return().Err, "undefined variable: m")
+	require.Equal(t, "update t set v = ${m}", h[4].Invoke().SQL)
+	require.Empty(t, h.CheckConsistency())
+
+	// references are kept as they are without vars
+	h = nil
+	require.NoError(t, Run(context.Background(), db, stmts, EvalOptions{Callback: h.Collect}))
+	require.Equal(t, stmts[1].SQL, h[2].Invoke().SQL)
+	require.NoError(t, h[5].Return().Err)
+}
+
+func TestEvalWithSeq(t *testing.T) {
+	db, err := sql.Open("stmtflow-fake", "")
+	require.NoError(t, err)
+	defer db.Close()
+	stmts := []Stmt{{Sess: "s1", SQL: "select 1", Flags: S_QUERY}, {Sess: "s2", SQL: "select 2", Flags: S_QUERY}}
+	var h1, h2 History
+	require.NoError(t, Run(context.Background(), db, stmts, EvalOptions{Callback: h1.Collect, WithSeq: true}))
+	require.NoError(t, Run(context.Background(), db, stmts, EvalOptions{Callback: h2.Collect, WithSeq: true}))
+	for i := 1; i < len(h1); i++ {
+		require.Greater(t, h1[i].Seq, h1[i-1].Seq)
+	}
+	require.Greater(t, h2[0].Seq, h1[len(h1)-1].Seq)
+	require.Equal(t, append(append(History{}, h1...), h2...), Merge(h2, h1))
+
+	js, err := json.Marshal(h1[1])
+	require.NoError(t, err)
+	require.Contains(t, string(js), fmt.Sprintf(`"seq":%d`, h1[1].Seq))
+	var ev Event
+	require.NoError(t, json.Unmarshal(js, &ev))
+	require.Equal(t, h1[1].Seq, ev.Seq)
+
+	var h History
+	require.NoError(t, Run(context.Background(), db, stmts, EvalOptions{Callback: h.Collect}))
+	require.Zero(t, h[0].Seq)
+	js, err = json.Marshal(h[0])
+	require.NoError(t, err)
+	require.NotContains(t, string(js), "seq")
+}
@@ -2,50 +2,101 @@ package stmtflow
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/zyguan/sqlz/resultset"
 )
 
+type EventKind string
+
 const (
-	EventBlock  = "Block"
-	EventResume = "Resume"
-	EventInvoke = "Invoke"
-	EventReturn = "Return"
+	EventBlock  EventKind = "Block"
+	EventResume EventKind = "Resume"
+	EventInvoke EventKind = "Invoke"
+	EventReturn EventKind = "Return"
+	EventSkip   EventKind = "Skip"
+	EventAbort  EventKind = "Abort"
 )
 
+var EventKinds = []EventKind{EventBlock, EventResume, EventInvoke, EventReturn, EventSkip, EventAbort}
+
+func (k EventKind) Valid() bool {
+	for _, x := range EventKinds {
+		if k == x {
+			return true
+		}
+	}
+	return false
+}
+
 func NewBlockEvent(s string) Event {
-	return Event{EventMeta: EventMeta{EventBlock, s}}
+	return Event{EventMeta: EventMeta{Kind: EventBlock, Session: s}}
 }
 
 func NewResumeEvent(s string) Event {
-	return Event{EventMeta: EventMeta{EventResume, s}}
+	return Event{EventMeta: EventMeta{Kind: EventResume, Session: s}}
 }
 
 func NewInvokeEvent(s string, inv Invoke) Event {
-	return Event{EventMeta: EventMeta{EventInvoke, s}, inv: &inv}
+	return NewInvokeEventWithContext(context.Background(), s, inv)
+}
+
+// NewInvokeEventWithContext creates an invoke event carrying the trace metadata of ctx (see WithTrace).
+func NewInvokeEventWithContext(ctx context.Context, s string, inv Invoke) Event {
+	return Event{EventMeta: newEventMeta(ctx, EventInvoke, s), inv: &inv}
+}
+
+// NewSkipEvent creates an event for a statement that is never invoked because the flow has been stopped.
+func NewSkipEvent(s string, inv Invoke) Event {
+	return Event{EventMeta: EventMeta{Kind: EventSkip, Session: s}, inv: &inv}
+}
+
+// NewAbortEvent creates the terminal event of a flow aborted by err, it belongs to no session.
+func NewAbortEvent(err error) Event {
+	return Event{EventMeta: EventMeta{Kind: EventAbort}, ret: &Return{Err: err}}
 }
 
 func NewReturnEvent(s string, ret Return) Event {
-	return Event{EventMeta: EventMeta{EventReturn, s}, ret: &ret}
+	return Event{EventMeta: EventMeta{Kind: EventReturn, Session: s}, ret: &ret}
 }
 
 type EventMeta struct {
-	Kind    string `json:"kind"`
-	Session string `json:"session"`
+	Kind    EventKind `json:"kind"`
+	Session string    `json:"session"`
+	// TraceID and SpanID associate the event with a trace, they are not compared by EqualTo.
+	TraceID string `json:"trace_id,omitempty"`
+	SpanID  string `json:"span_id,omitempty"`
+	// Note is a free-form annotation of the event (see History.AnnotateWith), it's not compared by EqualTo either.
+	Note string `json:"note,omitempty"`
+	// Seq orders events of all flows evaluated with EvalOptions.WithSeq, zero means unknown. It's not compared by
+	// EqualTo.
+	Seq int64 `json:"seq,omitempty"`
+}
+
+func newEventMeta(ctx context.Context, k EventKind, s string) EventMeta {
+	meta := EventMeta{Kind: k, Session: s}
+	meta.TraceID, meta.SpanID = TraceFromContext(ctx)
+	return meta
 }
 
 func (e EventMeta) String() string {
-	return e.Session + ":" + strings.ToLower(e.Kind)
+	return e.Session + ":" + strings.ToLower(string(e.Kind))
 }
 
+func (e EventMeta) IsKind(k EventKind) bool { return e.Kind == k }
+
 type Event struct {
 	EventMeta
 	inv *Invoke
@@ -59,61 +110,98 @@ type eventInvoke struct {
 
 type eventReturn struct {
 	EventMeta
-	Stmt   Stmt            `json:"stmt"`
-	T      []int64         `json:"t"`
-	Data   [][]interface{} `json:"data,omitempty"`
-	Result *string         `json:"result,omitempty"`
-	Error  *Error          `json:"error,omitempty"`
+	Stmt      Stmt            `json:"stmt"`
+	T         []int64         `json:"t"`
+	Data      [][]interface{} `json:"data,omitempty"`
+	Result    *string         `json:"result,omitempty"`
+	Error     *Error          `json:"error,omitempty"`
+	Truncated bool            `json:"truncated,omitempty"`
+	Digest    string          `json:"digest,omitempty"`
+	RowStats  *RowStats       `json:"row_stats,omitempty"`
 }
 
-func (e Event) MarshalJSON() ([]byte, error) {
+func (e Event) MarshalJSON() ([]byte, error) { return e.marshalJSON(JsonDumpOptions{}) }
+
+func (e Event) marshalJSON(opts JsonDumpOptions) ([]byte, error) {
 	switch e.Kind {
 	case EventBlock, EventResume:
 		return json.Marshal(e.EventMeta)
-	case EventInvoke:
+	case EventInvoke, EventSkip:
 		inv := eventReturn{EventMeta: e.EventMeta}
 		if e.inv == nil {
 			return nil, errors.New("invoke data is missing")
 		}
 		inv.Stmt = e.inv.Stmt
 		return json.Marshal(inv)
+	case EventAbort:
+		if e.ret == nil || e.ret.Err == nil {
+			return nil, errors.New("abort error is missing")
+		}
+		return json.Marshal(struct {
+			EventMeta
+			Error *Error `json:"error"`
+		}{e.EventMeta, WrapError(e.ret.Err).(*Error)})
 	case EventReturn:
 		ret := eventReturn{EventMeta: e.EventMeta}
-		if e.ret == nil {
+		data := e.loadResult()
+		if data == nil {
 			return nil, errors.New("return data is missing")
 		}
-		ret.Stmt = e.ret.Stmt
-		ret.T = []int64{e.ret.T[0].UnixNano(), e.ret.T[1].UnixNano()}
-		if err := e.ret.Err; err != nil {
+		if err := e.ResultError(); err != nil {
+			return nil, err
+		}
+		ret.Stmt = data.Stmt
+		ret.T = []int64{data.T[0].UnixNano(), data.T[1].UnixNano()}
+		// `truncated` is for human readers only, the truncation is recorded by `result` (or `digest`)
+		ret.Truncated = data.Truncated()
+		ret.RowStats = data.RowStats
+		if err := data.Err; err != nil {
 			ret.Error = WrapError(err).(*Error)
 			return json.Marshal(ret)
 		}
-		rs := e.ret.Res
-		raw, err := rs.Encode()
+		rs := data.Res
+		if rs == nil {
+			ret.Digest = data.Digest
+			return json.Marshal(ret)
+		}
+		if opts.DigestOnly && !rs.IsExecResult() {
+			ret.Digest = rs.DataDigest(resultset.DigestOptions{Sort: data.Stmt.Flags&S_UNORDERED > 0, Hash: opts.DigestAlgorithm})
+			return json.Marshal(ret)
+		}
+		var raw []byte
+		var err error
+		if opts.Encoding != nil {
+			raw, err = rs.EncodeWith(*opts.Encoding)
+		} else {
+			raw, err = rs.Encode()
+		}
 		if err != nil {
 			return nil, err
 		}
 		s := base64.StdEncoding.EncodeToString(raw)
 		ret.Result = &s
-		if !e.ret.Res.IsExecResult() {
+		if !rs.IsExecResult() {
 			rows, cols := rs.NRows(), rs.NCols()
 			mem := make([]interface{}, rows*cols)
-			for i := 0; i < rows; i++ {
+			rs.EachRow(func(i int, row resultset.Row) error {
 				for j := 0; j < cols; j++ {
-					if x, ok := rs.RawValue(i, j); ok && x != nil {
+					if x, ok := row.ByIndex(j); ok && x != nil {
 						mem[i*cols+j] = string(x)
 					}
 				}
 				ret.Data = append(ret.Data, mem[i*cols:(i+1)*cols])
-			}
+				return nil
+			})
 		}
 		return json.Marshal(ret)
 	default:
-		return nil, errors.New("unknown event: " + e.Kind)
+		return nil, errors.New("unknown event: " + string(e.Kind))
 	}
 }
 
-func (e *Event) UnmarshalJSON(data []byte) error {
+func (e *Event) UnmarshalJSON(data []byte) error { return e.unmarshalJSON(data, LoadOptions{}) }
+
+func (e *Event) unmarshalJSON(data []byte, opts LoadOptions) error {
 	var meta EventMeta
 	err := json.Unmarshal(data, &meta)
 	if err != nil {
@@ -123,20 +211,37 @@ func (e *Event) UnmarshalJSON(data []byte) error {
 	switch e.Kind {
 	case EventBlock, EventResume:
 		return nil
-	case EventInvoke:
+	case EventInvoke, EventSkip:
 		var inv eventInvoke
 		if err = json.Unmarshal(data, &inv); err != nil {
 			return err
 		}
 		e.inv = &Invoke{Stmt: inv.Stmt}
 		return nil
+	case EventAbort:
+		var abort struct {
+			Error *Error `json:"error"`
+		}
+		if err = json.Unmarshal(data, &abort); err != nil {
+			return err
+		}
+		if abort.Error == nil {
+			return errors.New("invalid abort event: `error` is missing")
+		}
+		e.ret = &Return{Err: abort.Error}
+		return nil
 	case EventReturn:
-		var ret eventReturn
+		var ret struct {
+			eventReturn
+			// rows in `data` are for human readers only, results are always decoded from `result`
+			Data skipJSON `json:"data,omitempty"`
+		}
 		if err = json.Unmarshal(data, &ret); err != nil {
 			return err
 		}
 		e.ret = &Return{}
 		e.ret.Stmt = ret.Stmt
+		e.ret.RowStats = ret.RowStats
 		if len(ret.T) > 0 {
 			e.ret.T[0] = time.Unix(0, ret.T[0])
 		}
@@ -147,26 +252,130 @@ func (e *Event) UnmarshalJSON(data []byte) error {
 			e.ret.Err = ret.Error
 			return nil
 		}
+		if ret.Result == nil && len(ret.Digest) > 0 {
+			if a := resultset.DigestAlgorithmOf(ret.Digest); !a.Valid() {
+				return fmt.Errorf("invalid return event: unknown digest algorithm: %s", a)
+			}
+			e.ret.Digest = ret.Digest
+			return nil
+		}
 		if ret.Result == nil {
 			return errors.New("invalid return event: `error` or `result` is missing")
 		}
-		raw, err := base64.StdEncoding.DecodeString(*ret.Result)
-		if err != nil {
-			return err
+		l := &lazyResult{encoded: *ret.Result, session: e.Session, sql: ret.Stmt.SQL}
+		if opts.LazyResults {
+			e.ret.lazy = l
+			return nil
 		}
-		e.ret.Res = new(resultset.ResultSet)
-		return e.ret.Res.Decode(raw)
+		e.ret.Res, err = l.decode()
+		return err
 	default:
-		return errors.New("unknown event: " + e.Kind)
+		return errors.New("unknown event: " + string(e.Kind))
 	}
 }
 
+// skipJSON discards a JSON value without allocating anything for it.
+type skipJSON struct{}
+
+func (skipJSON) UnmarshalJSON([]byte) error { return nil }
+
+// LoadOptions controls how LoadJson decodes a dumped history.
+type LoadOptions struct {
+	// LazyResults keeps results of return events encoded until they are needed (by Return, EqualTo, DumpText, etc.),
+	// which speeds up loading large dumps of which only a few results are inspected, see also Event.ResultHeader. An
+	// error of the deferred decoding is reported by Event.ResultError.
+	LazyResults bool
+}
+
+// LoadJson reads a history written by DumpJson.
+func LoadJson(r io.Reader, opts LoadOptions) (History, error) {
+	var raws []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raws); err != nil {
+		return nil, err
+	}
+	h := make(History, len(raws))
+	for i, raw := range raws {
+		if err := h[i].unmarshalJSON(raw, opts); err != nil {
+			return nil, fmt.Errorf("event #%d: %w", i, err)
+		}
+	}
+	return h, nil
+}
+
+// lazyResult is a base64 encoded result whose decoding is deferred.
+type lazyResult struct {
+	once    sync.Once
+	encoded string
+	session string
+	sql     string
+
+	res *resultset.ResultSet
+	err error
+}
+
+func (l *lazyResult) decode() (*resultset.ResultSet, error) {
+	l.once.Do(func() {
+		raw, err := base64.StdEncoding.DecodeString(l.encoded)
+		if err == nil {
+			l.res = new(resultset.ResultSet)
+			err = l.res.Decode(raw)
+		}
+		if err != nil {
+			l.res, l.err = nil, fmt.Errorf("decode result of %s (%s): %w", l.session, l.sql, err)
+		}
+	})
+	return l.res, l.err
+}
+
+// loadResult returns the (possibly nil) return data. The result of a lazily loaded return is decoded into a copy of
+// the data, so that events sharing it can be loaded concurrently. A result failing to decode is left nil, see
+// ResultError.
+func (e *Event) loadResult() *Return {
+	if e.ret == nil || e.ret.lazy == nil {
+		return e.ret
+	}
+	ret := *e.ret
+	ret.Res, _ = e.ret.lazy.decode()
+	return &ret
+}
+
+// ResultError reports the failure of decoding the result of a return event loaded with LoadOptions.LazyResults. Such
+// a failure is not an error of the statement, so it's not reported by Return().Err.
+func (e *Event) ResultError() error {
+	if e.ret == nil || e.ret.lazy == nil {
+		return nil
+	}
+	_, err := e.ret.lazy.decode()
+	return err
+}
+
+// ResultHeader returns columns and the number of rows of the result of a return event, along with whether it's an
+// exec result. Rows of a lazily loaded result (see LoadOptions) are not decoded.
+func (e *Event) ResultHeader() (resultset.ColumnDefs, int, bool, error) {
+	if e.ret != nil && e.ret.lazy != nil {
+		raw, err := base64.StdEncoding.DecodeString(e.ret.lazy.encoded)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		return resultset.DecodeHeader(raw)
+	}
+	if e.ret == nil || e.ret.Res == nil {
+		return nil, 0, false, errors.New("no result")
+	}
+	rs := e.ret.Res
+	cols := make(resultset.ColumnDefs, rs.NCols())
+	for j := range cols {
+		cols[j] = rs.ColumnDef(j)
+	}
+	return cols, rs.NRows(), rs.IsExecResult(), nil
+}
+
 func (e *Event) EqualTo(other Event, opts ...resultset.DigestOptions) (bool, string) {
-	if e.EventMeta != other.EventMeta {
+	if e.Kind != other.Kind || e.Session != other.Session {
 		return false, fmt.Sprintf("expect %+v, got %+v", e.EventMeta, other.EventMeta)
 	}
 	tag := e.EventMeta.String()
-	if e.Kind == EventInvoke {
+	if e.Kind == EventInvoke || e.Kind == EventSkip {
 		thisInv, thatInv := e.Invoke(), other.Invoke()
 		tag += "(" + thisInv.Stmt.SQL + ")"
 		if thisInv.Stmt != thatInv.Stmt {
@@ -178,6 +387,16 @@ func (e *Event) EqualTo(other Event, opts ...resultset.DigestOptions) (bool, str
 		if thisRet.Stmt != thatRet.Stmt {
 			return false, fmt.Sprintf(tag+": expect %+v, got %+v", thisRet.Stmt, thatRet.Stmt)
 		}
+		// undecodable results are never equal, even to each other
+		if err := e.ResultError(); err != nil {
+			return false, tag + ": expect " + err.Error()
+		}
+		if err := other.ResultError(); err != nil {
+			return false, tag + ": got " + err.Error()
+		}
+		if thisRet.Stmt.Flags&S_IGNORE_ERROR > 0 && (thisRet.Err != nil || thatRet.Err != nil) {
+			return true, ""
+		}
 		if thisRet.Err != nil {
 			if thatRet.Err == nil {
 				return false, fmt.Sprintf(tag+": expect (%s), got ok", thisRet.Err.Error())
@@ -187,24 +406,69 @@ func (e *Event) EqualTo(other Event, opts ...resultset.DigestOptions) (bool, str
 				return false, fmt.Sprintf(tag+": expect (%s), got (%s)", e1.Error(), e2.Error())
 			}
 		} else {
-			if thatRet.Res == nil {
+			if thatRet.Err != nil {
 				return false, fmt.Sprintf(tag+": expect a result, got (%s)", thatRet.Err.Error())
 			}
+			if thisRet.Res == nil || thatRet.Res == nil {
+				// a digest-only result is comparable by the digest recorded at dump time only, which covers truncation
+				if len(opts) > 0 && !digestOnlyComparable(opts[0], thisRet.Stmt.Flags&S_UNORDERED > 0) {
+					return false, tag + ": digest options cannot be applied to a digest-only result"
+				}
+				o := resultset.DigestOptions{Sort: thisRet.Stmt.Flags&S_UNORDERED > 0}
+				// the side with data is digested by the algorithm of the recorded digest
+				if thisRet.Res == nil {
+					o.Hash = resultset.DigestAlgorithmOf(thisRet.Digest)
+				} else {
+					o.Hash = resultset.DigestAlgorithmOf(thatRet.Digest)
+				}
+				for _, r := range []Return{thisRet, thatRet} {
+					if a := resultset.DigestAlgorithmOf(r.Digest); r.Res == nil && !a.Valid() {
+						return false, fmt.Sprintf(tag+": unknown digest algorithm %s", a)
+					}
+				}
+				if thisRet.Res == nil && thatRet.Res == nil {
+					if a1, a2 := resultset.DigestAlgorithmOf(thisRet.Digest), resultset.DigestAlgorithmOf(thatRet.Digest); a1 != a2 {
+						return false, fmt.Sprintf(tag+": expect digest algorithm %s, got %s", a1, a2)
+					}
+				}
+				if h1, h2 := thisRet.digest(o), thatRet.digest(o); h1 != h2 {
+					return false, fmt.Sprintf(tag+": expect digest %s, got %s", h1, h2)
+				}
+				return true, ""
+			}
 			r1, r2 := thisRet.Res, thatRet.Res
 			if r1.IsExecResult() != r2.IsExecResult() {
 				return false, fmt.Sprintf(tag+": expect [%s], got [%s]", r1, r2)
 			}
+			if r1.NCols() != r2.NCols() {
+				return false, fmt.Sprintf(tag+": column count mismatch: expect %d %v, got %d %v",
+					r1.NCols(), columnNames(r1), r2.NCols(), columnNames(r2))
+			}
 			if !r1.IsExecResult() {
-				var o resultset.DigestOptions
+				var o resultset.CompareOptions
 				if len(opts) > 0 {
-					o = opts[0]
+					o.Digest = opts[0]
 				}
-				h1, h2 := "", ""
-				o.Sort = o.Sort || thisRet.Stmt.Flags&S_UNORDERED > 0
-				h1 = r1.DataDigest(o)
-				h2 = r2.DataDigest(o)
-				if h1 != h2 {
-					return false, fmt.Sprintf(tag+": expect digest %s, got %s", h1, h2)
+				o.Digest.Sort = o.Digest.Sort || thisRet.Stmt.Flags&S_UNORDERED > 0
+				if r1.Truncated() || r2.Truncated() {
+					if r1.Truncated() != r2.Truncated() {
+						return false, fmt.Sprintf(tag+": expect truncated=%v, got truncated=%v", r1.Truncated(), r2.Truncated())
+					}
+					// only the rows both sides have read are comparable
+					n := r1.NRows()
+					if r2.NRows() < n {
+						n = r2.NRows()
+					}
+					read := func(i int, _ []string) bool { return i < n }
+					r1, r2 = r1.FilterRows(read), r2.FilterRows(read)
+				}
+				if ok, diffs := r1.Equal(r2, o); !ok {
+					switch d := diffs[0]; d.Kind {
+					case resultset.CellValueMismatch, resultset.RowCountMismatch:
+						return false, fmt.Sprintf(tag+": expect digest %s, got %s (%s)", r1.DataDigest(o.Digest), r2.DataDigest(o.Digest), d)
+					default:
+						return false, tag + ": " + d.String()
+					}
 				}
 			}
 		}
@@ -212,24 +476,97 @@ func (e *Event) EqualTo(other Event, opts ...resultset.DigestOptions) (bool, str
 	return true, ""
 }
 
+// digestOnlyComparable reports whether comparing by digests recorded at dump time honours opts. They are computed with
+// rows sorted for unordered statements only and without any normalization, opts.Hash doesn't matter since the side
+// with data is digested by the recorded algorithm.
+func digestOnlyComparable(opts resultset.DigestOptions, unordered bool) bool {
+	return (!opts.Sort || unordered) && len(opts.SortKeys) == 0 && opts.Filter == nil && opts.Mapper == nil &&
+		!opts.JSONSemantic && !opts.CompareTypes && !opts.ShapeOnly
+}
+
+// columnCountMismatch reports whether both events return query results with different numbers of columns.
+func (e *Event) columnCountMismatch(other *Event) bool {
+	ret1, ret2 := e.loadResult(), other.loadResult()
+	if ret1 == nil || ret2 == nil || ret1.Res == nil || ret2.Res == nil {
+		return false
+	}
+	r1, r2 := ret1.Res, ret2.Res
+	return !r1.IsExecResult() && !r2.IsExecResult() && r1.NCols() != r2.NCols()
+}
+
+// LatencyWithin compares the latency of e (the expected one) with other's, it returns the delta (positive if other is
+// slower) and whether its absolute value is within tolerance. Unlike EqualTo, it's about timing only, and both events
+// must be return events, otherwise it reports false with a zero delta.
+func (e *Event) LatencyWithin(other Event, tolerance time.Duration) (bool, time.Duration) {
+	if e.Kind != EventReturn || other.Kind != EventReturn || e.ret == nil || other.ret == nil {
+		return false, 0
+	}
+	delta := other.ret.T[1].Sub(other.ret.T[0]) - e.ret.T[1].Sub(e.ret.T[0])
+	return delta <= tolerance && -delta <= tolerance, delta
+}
+
+func columnNames(rs *resultset.ResultSet) []string {
+	names := make([]string, rs.NCols())
+	for j := range names {
+		names[j] = rs.ColumnDef(j).Name
+	}
+	return names
+}
+
 func (e *Event) Invoke() Invoke { return *e.inv }
 
-func (e *Event) Return() Return { return *e.ret }
+func (e *Event) Return() Return { return *e.loadResult() }
+
+// Text renders the event like DumpText does.
+func (e Event) Text(opts TextDumpOptions) string {
+	b := new(strings.Builder)
+	e.DumpText(b, opts)
+	return b.String()
+}
 
 func (e *Event) DumpText(w io.Writer, opts TextDumpOptions) {
+	if opts.Color && opts.WithSessionColors {
+		buf := new(bytes.Buffer)
+		opts.WithSessionColors = false
+		e.DumpText(buf, opts)
+		writeColored(w, sessionColor(e.Session), buf.String())
+		return
+	}
+	if len(e.Note) > 0 {
+		for _, line := range strings.Split(e.Note, "\n") {
+			fmt.Fprintf(w, "-- note: %s\n", line)
+		}
+	}
 	switch e.Kind {
 	case EventInvoke:
 		sql := e.Invoke().SQL
 		if !strings.HasPrefix(sql, "/*") {
-			sql = fmt.Sprintf("/* %s */ %s", e.Invoke().Sess, sql)
+			sql = fmt.Sprintf("/* %s */ %s", e.Invoke().Sess, opts.sqlText(sql))
+		} else {
+			sql = opts.sqlText(sql)
+		}
+		fmt.Fprintln(w, wrapSQL(sql, opts.SQLLineWidth))
+		if opts.WithExplainPlan && opts.DB != nil {
+			opts.dumpExplain(w, e.Session, e.Invoke().SQL)
 		}
-		fmt.Fprintln(w, sql)
 	case EventReturn:
 		ret := e.Return()
 		if ret.Err == nil {
-			if opts.Verbose && !ret.Res.IsExecResult() {
+			if opts.Verbose && ret.Res != nil && !ret.Res.IsExecResult() {
 				buf, fst := new(bytes.Buffer), true
-				ret.Res.PrettyPrint(buf)
+				pp := opts.PrettyPrint
+				pp.RowNumbers = pp.RowNumbers || opts.WithRowNumbers
+				if opts.MaxResults > 0 {
+					pp.MaxRows = opts.MaxResults
+				}
+				if opts.Vertical {
+					ret.Res.PrettyPrintVertical(buf, pp)
+				} else {
+					ret.Res.PrettyPrintTo(buf, pp)
+				}
+				if buf.Len() == 0 {
+					fmt.Fprintf(buf, "%s\n", ret.Res.String())
+				}
 				for {
 					line, err := buf.ReadString('\n')
 					if err != nil {
@@ -242,12 +579,20 @@ func (e *Event) DumpText(w io.Writer, opts TextDumpOptions) {
 						fmt.Fprint(w, "-- ", e.Session, "    ", line)
 					}
 				}
+				if ret.Truncated() {
+					fmt.Fprintf(w, "-- %s    %s\n", e.Session, truncatedText(ret))
+				}
+			} else if ret.Truncated() {
+				fmt.Fprintf(w, "-- %s >> %s %s\n", e.Session, opts.summary(ret), truncatedText(ret))
 			} else {
-				fmt.Fprintf(w, "-- %s >> %s\n", e.Session, ret.Res.String())
+				fmt.Fprintf(w, "-- %s >> %s\n", e.Session, opts.summary(ret))
 			}
 			if opts.WithLat {
 				fmt.Fprintf(w, "-- %s    %s ~ %s (cost %s)\n", e.Session,
-					ret.T[0].Format("15:04:05.000"), ret.T[1].Format("15:04:05.000"), ret.T[1].Sub(ret.T[0]))
+					opts.timeText(ret.T[0]), opts.timeText(ret.T[1]), ret.T[1].Sub(ret.T[0]))
+			}
+			if opts.Verbose && ret.RowStats != nil {
+				fmt.Fprintf(w, "-- %s    %s\n", e.Session, ret.RowStats)
 			}
 		} else {
 			fmt.Fprintf(w, "-- %s >> %s\n", e.Session, ret.Err.Error())
@@ -256,9 +601,17 @@ func (e *Event) DumpText(w io.Writer, opts TextDumpOptions) {
 		fmt.Fprintf(w, "-- %s >> blocked\n", e.Session)
 	case EventResume:
 		fmt.Fprintf(w, "-- %s >> resumed\n", e.Session)
+	case EventSkip:
+		fmt.Fprintf(w, "-- %s >> skipped: %s\n", e.Session, opts.sqlText(e.Invoke().SQL))
+	case EventAbort:
+		fmt.Fprintf(w, "-- aborted: %s\n", e.ret.Err.Error())
 	}
 }
 
+func truncatedText(ret Return) string {
+	return fmt.Sprintf("(truncated at %d rows)", ret.Res.NRows())
+}
+
 type Error struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -290,30 +643,273 @@ type History []Event
 type JsonDumpOptions struct {
 	Prefix string
 	Indent string
+	// DigestOnly records query results by their data digests instead of their data. Such dumps cannot reproduce the
+	// rows, but are still good for EqualTo.
+	DigestOnly bool
+	// DigestAlgorithm is the algorithm of digests recorded by DigestOnly dumps, it defaults to resultset.DigestSHA1.
+	DigestAlgorithm resultset.DigestAlgorithm
+	// Encoding encodes results by ResultSet.EncodeWith (e.g. to choose the compression) instead of ResultSet.Encode.
+	Encoding *resultset.EncodeOptions
+	// OneEventPerLine writes brackets of the outer array on their own lines and each event as a compact JSON object on
+	// a line, which diffs well. Prefix and Indent are ignored then.
+	OneEventPerLine bool
 }
 
 func (h History) DumpJson(w io.Writer, opts JsonDumpOptions) error {
+	out := make([]json.RawMessage, len(h))
+	for i, e := range h {
+		raw, err := e.marshalJSON(opts)
+		if err != nil {
+			return err
+		}
+		out[i] = raw
+	}
+	if opts.OneEventPerLine {
+		buf := new(bytes.Buffer)
+		buf.WriteString("[\n")
+		for i, raw := range out {
+			buf.Write(raw)
+			if i < len(out)-1 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString("]\n")
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
 	enc := json.NewEncoder(w)
 	enc.SetIndent(opts.Prefix, opts.Indent)
-	return enc.Encode(h)
+	return enc.Encode(out)
 }
 
 type TextDumpOptions struct {
-	Verbose bool
-	WithLat bool
+	Verbose  bool
+	WithLat  bool
+	Grid     bool
+	Vertical bool
+	// WithRowNumbers prefixes verbose result tables with a 1-based row index column.
+	WithRowNumbers bool
+	// ResultFormatter renders the one-line summary of a result, an empty output falls back to Res.String().
+	ResultFormatter func(rs *resultset.ResultSet) string
+	// WithExplainPlan follows each invoked statement with its `EXPLAIN FORMAT=JSON` output queried from DB at dump
+	// time.
+	WithExplainPlan bool
+	DB              *sql.DB
+	// Context bounds queries of WithExplainPlan, nil means context.Background().
+	Context context.Context
+	// Color enables ANSI colors in the output.
+	Color bool
+	// WithSessionColors prints lines of each session in a color derived from its name, it requires Color.
+	WithSessionColors bool
+	// MaxSQLLen truncates the printed SQL to the given number of characters with an ellipsis, zero means no limit.
+	MaxSQLLen int
+	// SQLLineWidth hard-wraps invoked SQL at spaces to lines of about the given number of characters, continuation
+	// lines are indented by four spaces. Zero means no wrapping.
+	SQLLineWidth int
+	// Separator is printed on a line of its own whenever the session changes between consecutive events, the grid
+	// layout ignores it. Empty means no separator.
+	Separator string
+	// WithTimezone converts timestamps to the given location before printing them, nil prints them as they are.
+	WithTimezone *time.Location
+	// PrettyPrint renders result tables in verbose mode, e.g. how NULL and binary cells are shown. Its RowNumbers is
+	// set by WithRowNumbers.
+	PrettyPrint resultset.PrettyPrintOptions
+	// WithSQLNormalized collapses whitespace and uppercases common keywords of the printed SQL, quoted strings and
+	// identifiers are left as they are. It is a lightweight canonicalization for diffing dumps, not a formatter.
+	WithSQLNormalized bool
+	// MaxResults prints at most the given number of rows of each result table in verbose mode, followed by a line
+	// noting how many rows are left. It overrides PrettyPrint.MaxRows, zero means no limit.
+	MaxResults int
+}
+
+func (opts TextDumpOptions) timeText(t time.Time) string {
+	if opts.WithTimezone != nil {
+		t = t.In(opts.WithTimezone)
+	}
+	return t.Format("15:04:05.000")
+}
+
+func (opts TextDumpOptions) sqlText(sql string) string {
+	if opts.WithSQLNormalized {
+		sql = normalizeSQL(sql)
+	}
+	if opts.MaxSQLLen <= 0 || utf8.RuneCountInString(sql) <= opts.MaxSQLLen {
+		return sql
+	}
+	rs := []rune(sql)
+	return string(rs[:opts.MaxSQLLen]) + "…"
+}
+
+var (
+	sqlTokenPattern = regexp.MustCompile(`'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"|` + "`[^`]*`" + `|\s+|\w+`)
+	sqlKeywords     = map[string]struct{}{}
+)
+
+func init() {
+	for _, kw := range strings.Fields(`
+		SELECT FROM WHERE AND OR NOT NULL IS IN AS ON JOIN LEFT RIGHT INNER OUTER CROSS USING GROUP BY ORDER HAVING
+		LIMIT OFFSET ASC DESC DISTINCT UNION ALL EXISTS BETWEEN LIKE CASE WHEN THEN ELSE END FOR SHARE LOCK MODE
+		INSERT INTO VALUES REPLACE UPDATE SET DELETE IGNORE DUPLICATE KEY BEGIN START TRANSACTION COMMIT ROLLBACK
+		SAVEPOINT CREATE ALTER DROP TABLE INDEX VIEW IF PRIMARY UNIQUE DEFAULT SHOW EXPLAIN ANALYZE TRUNCATE`) {
+		sqlKeywords[kw] = struct{}{}
+	}
+}
+
+func isKeyword(s string) bool {
+	_, ok := sqlKeywords[s]
+	return ok
+}
+
+// normalizeSQL collapses runs of whitespace outside quotes into single spaces and uppercases known keywords.
+func normalizeSQL(sql string) string {
+	return strings.TrimSpace(sqlTokenPattern.ReplaceAllStringFunc(sql, func(tok string) string {
+		switch tok[0] {
+		case '\'', '"', '`':
+			return tok
+		case ' ', '\t', '\n', '\r', '\f', '\v':
+			return " "
+		}
+		if up := strings.ToUpper(tok); isKeyword(up) {
+			return up
+		}
+		return tok
+	}))
+}
+
+// wrapSQL breaks lines of sql at the last space fitting in width, a word longer than width is left unbroken. Spaces in
+// quoted strings and identifiers are never broken at.
+func wrapSQL(sql string, width int) string {
+	if width <= 0 {
+		return sql
+	}
+	const indent = "    "
+	rs, quoted := []rune(sql), sqlQuoted(sql)
+	breakable := func(i int) bool { return rs[i] == ' ' && !quoted[i] }
+	b := new(strings.Builder)
+	for start := 0; start <= len(rs); start++ {
+		end := start
+		for end < len(rs) && rs[end] != '\n' {
+			end++
+		}
+		if start > 0 {
+			b.WriteByte('\n')
+		}
+		prefix := ""
+		for {
+			limit := width - len(prefix)
+			if limit < 1 {
+				limit = 1
+			}
+			if end-start <= limit {
+				b.WriteString(prefix + string(rs[start:end]))
+				break
+			}
+			cut := -1
+			for i := start + limit; i > start; i-- {
+				if breakable(i) {
+					cut = i
+					break
+				}
+			}
+			if cut < 0 {
+				for i := start + limit + 1; i < end; i++ {
+					if breakable(i) {
+						cut = i
+						break
+					}
+				}
+			}
+			next := cut + 1
+			for cut >= 0 && next < end && rs[next] == ' ' {
+				next++
+			}
+			if cut < 0 || next == end {
+				b.WriteString(prefix + string(rs[start:end]))
+				break
+			}
+			b.WriteString(prefix + strings.TrimRight(string(rs[start:cut]), " ") + "\n")
+			start, prefix = next, indent
+		}
+		start = end
+	}
+	return b.String()
+}
+
+// sqlQuoted marks runes of sql which belong to quoted strings or identifiers, quotes included.
+func sqlQuoted(sql string) []bool {
+	rs := []rune(sql)
+	quoted := make([]bool, len(rs))
+	var quote rune
+	for i := 0; i < len(rs); i++ {
+		switch {
+		case quote == 0:
+			if rs[i] == '\'' || rs[i] == '"' || rs[i] == '`' {
+				quote, quoted[i] = rs[i], true
+			}
+		case rs[i] == '\\' && quote != '`' && i+1 < len(rs):
+			quoted[i], quoted[i+1] = true, true
+			i++
+		default:
+			quoted[i] = true
+			if rs[i] == quote {
+				quote = 0
+			}
+		}
+	}
+	return quoted
+}
+
+func (opts TextDumpOptions) summary(ret Return) string {
+	rs := ret.Res
+	if rs == nil && ret.lazy != nil {
+		if _, err := ret.lazy.decode(); err != nil {
+			return "<undecodable> " + err.Error()
+		}
+	}
+	if rs == nil {
+		return "<digest> " + ret.Digest
+	}
+	if opts.ResultFormatter != nil {
+		if s := opts.ResultFormatter(rs); len(s) > 0 {
+			return s
+		}
+	}
+	return rs.String()
 }
 
 func (h History) DumpText(w io.Writer, opts TextDumpOptions) error {
-	for _, e := range h {
+	if opts.Grid {
+		return h.dumpGrid(w, opts)
+	}
+	for i, e := range h {
+		if len(opts.Separator) > 0 && i > 0 && h[i-1].Session != e.Session {
+			fmt.Fprintln(w, opts.Separator)
+		}
 		e.DumpText(w, opts)
 	}
 	return nil
 }
 
+// Text renders the history like DumpText does. A dump error, which should never happen for an in-memory writer, is
+// appended as a trailing comment rather than dropped.
+func (h History) Text(opts TextDumpOptions) string {
+	b := new(strings.Builder)
+	if err := h.DumpText(b, opts); err != nil {
+		fmt.Fprintf(b, "-- dump error: %v\n", err)
+	}
+	return b.String()
+}
+
 func (h *History) Collect(e Event) { *h = append(*h, e) }
 
 func TextDumper(w io.Writer, opts TextDumpOptions) func(Event) {
+	last := ""
 	return func(e Event) {
+		if len(opts.Separator) > 0 && len(last) > 0 && last != e.Session {
+			fmt.Fprintln(w, opts.Separator)
+		}
+		last = e.Session
 		e.DumpText(w, opts)
 	}
 }
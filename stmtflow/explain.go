@@ -0,0 +1,53 @@
+package stmtflow
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/zyguan/sqlz/resultset"
+)
+
+var explainable = map[string]bool{
+	"select":  true,
+	"insert":  true,
+	"update":  true,
+	"delete":  true,
+	"replace": true,
+	"with":    true,
+	"table":   true,
+}
+
+func (opts TextDumpOptions) dumpExplain(w io.Writer, s string, sql string) {
+	if !explainable[leadingKeyword(sql)] {
+		return
+	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	rows, err := opts.DB.QueryContext(ctx, "EXPLAIN FORMAT=JSON "+sql)
+	if err != nil {
+		fmt.Fprintf(w, "-- %s >> explain: %s\n", s, WrapError(err).Error())
+		return
+	}
+	defer rows.Close()
+	rs, err := resultset.ReadFromRows(rows)
+	if err != nil {
+		fmt.Fprintf(w, "-- %s >> explain: %s\n", s, WrapError(err).Error())
+		return
+	}
+	fmt.Fprintf(w, "-- %s >> explain:\n", s)
+	rs.EachRow(func(i int, row resultset.Row) error {
+		cells := make([]string, row.Len())
+		for j := range cells {
+			v, _ := row.ByIndex(j)
+			cells[j] = string(v)
+		}
+		for _, line := range strings.Split(strings.Join(cells, "\t"), "\n") {
+			fmt.Fprintf(w, "-- %s    %s\n", s, line)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,93 @@
+package stmtflow
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fakeDriver is a minimal database/sql driver: statements starting with "fail" return fakeError, queries return a
+// single row holding the SQL text (or rows 1 to n for `select * from seq_n`) and everything else affects one row.
+// Statements like `sleep 100ms` take the given duration. Every statement reads fakeReadsPerStmt rows, which are reported
+// by `SHOW SESSION STATUS`.
+type fakeDriver struct{}
+
+const fakeReadsPerStmt = 10
+
+type fakeError struct{ code int }
+
+func (e fakeError) Error() string { return "fake error" }
+
+type fakeConn struct{ reads int }
+
+type fakeStmt struct {
+	sql  string
+	conn *fakeConn
+}
+
+type fakeRows struct {
+	cols []string
+	vals [][]string
+}
+
+func init() { sql.Register("stmtflow-fake", fakeDriver{}) }
+
+func (fakeDriver) Open(name string) (driver.Conn, error) { return &fakeConn{}, nil }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{query, c}, nil }
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (s fakeStmt) Close() error { return nil }
+
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.conn.reads += fakeReadsPerStmt
+	if strings.HasPrefix(s.sql, "fail") {
+		return nil, fakeError{42}
+	}
+	if d, err := time.ParseDuration(strings.TrimPrefix(s.sql, "sleep ")); err == nil {
+		time.Sleep(d)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if strings.HasPrefix(s.sql, "SHOW SESSION STATUS") {
+		return &fakeRows{[]string{"Variable_name", "Value"}, [][]string{{"Handler_read_rnd_next", strconv.Itoa(s.conn.reads)}}}, nil
+	}
+	s.conn.reads += fakeReadsPerStmt
+	if strings.HasPrefix(s.sql, "fail") {
+		return nil, fakeError{42}
+	}
+	if n, err := strconv.Atoi(strings.TrimPrefix(s.sql, "select * from seq_")); err == nil {
+		rows := &fakeRows{cols: []string{"n"}}
+		for i := 1; i <= n; i++ {
+			rows.vals = append(rows.vals, []string{strconv.Itoa(i)})
+		}
+		return rows, nil
+	}
+	return &fakeRows{[]string{"sql"}, [][]string{{s.sql}}}, nil
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.vals) == 0 {
+		return io.EOF
+	}
+	for i, v := range r.vals[0] {
+		dest[i] = []byte(v)
+	}
+	r.vals = r.vals[1:]
+	return nil
+}
@@ -0,0 +1,220 @@
+package stmtflow
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/zyguan/sqlz/resultset"
+)
+
+// HistoryBuilder constructs histories event by event, typically as expectations in tests. See History.GenerateGoTest
+// for generating builder code from a recorded history.
+type HistoryBuilder struct {
+	h   History
+	err error
+}
+
+func NewHistoryBuilder() *HistoryBuilder { return &HistoryBuilder{} }
+
+func (b *HistoryBuilder) Invoke(stmt Stmt) *HistoryBuilder {
+	return b.add(NewInvokeEvent(stmt.Sess, Invoke{stmt}))
+}
+
+func (b *HistoryBuilder) Skip(stmt Stmt) *HistoryBuilder {
+	return b.add(NewSkipEvent(stmt.Sess, Invoke{stmt}))
+}
+
+func (b *HistoryBuilder) Block(s string) *HistoryBuilder { return b.add(NewBlockEvent(s)) }
+
+func (b *HistoryBuilder) Resume(s string) *HistoryBuilder { return b.add(NewResumeEvent(s)) }
+
+// Rows adds the return of a query with the given columns and rows, cells equal to resultset.Null are NULL.
+func (b *HistoryBuilder) Rows(stmt Stmt, cols []resultset.ColumnDef, rows [][]string) *HistoryBuilder {
+	rb := resultset.NewBuilder(cols...)
+	for _, row := range rows {
+		values := make([]interface{}, len(row))
+		for j, v := range row {
+			if v != resultset.Null {
+				values[j] = v
+			}
+		}
+		rb.AddRow(values...)
+	}
+	rs, err := rb.Build()
+	if err != nil {
+		if b.err == nil {
+			b.err = fmt.Errorf("event #%d: %v", len(b.h), err)
+		}
+		return b
+	}
+	return b.add(NewReturnEvent(stmt.Sess, Return{Stmt: stmt, Res: rs}))
+}
+
+// Exec adds the return of a statement without rows, a negative rowsAffected or lastInsertId means it's unknown.
+func (b *HistoryBuilder) Exec(stmt Stmt, rowsAffected int64, lastInsertId int64) *HistoryBuilder {
+	rs := resultset.NewFromResult(builtResult{rowsAffected, lastInsertId})
+	return b.add(NewReturnEvent(stmt.Sess, Return{Stmt: stmt, Res: rs}))
+}
+
+// Digest adds the return of a statement whose result is known by its data digest only.
+func (b *HistoryBuilder) Digest(stmt Stmt, digest string) *HistoryBuilder {
+	return b.add(NewReturnEvent(stmt.Sess, Return{Stmt: stmt, Digest: digest}))
+}
+
+// Error adds the return of a failed statement.
+func (b *HistoryBuilder) Error(stmt Stmt, code int, message string) *HistoryBuilder {
+	return b.add(NewReturnEvent(stmt.Sess, Return{Stmt: stmt, Err: &Error{code, message}}))
+}
+
+func (b *HistoryBuilder) Abort(code int, message string) *HistoryBuilder {
+	return b.add(NewAbortEvent(&Error{code, message}))
+}
+
+// Build returns the history built so far, or the first error of adding events.
+func (b *HistoryBuilder) Build() (History, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return append(History{}, b.h...), nil
+}
+
+func (b *HistoryBuilder) add(e Event) *HistoryBuilder {
+	b.h = append(b.h, e)
+	return b
+}
+
+type builtResult [2]int64
+
+func (r builtResult) LastInsertId() (int64, error) {
+	if r[1] < 0 {
+		return 0, errors.New("unknown last insert id")
+	}
+	return r[1], nil
+}
+
+func (r builtResult) RowsAffected() (int64, error) {
+	if r[0] < 0 {
+		return 0, errors.New("unknown rows affected")
+	}
+	return r[0], nil
+}
+
+// GenerateGoTest writes a Go function named funcName which reconstructs h by a HistoryBuilder, it's meant for
+// bootstrapping golden tests from a known-good run. The function returns (stmtflow.History, error) and refers to the
+// stmtflow and resultset packages by those names. Timestamps, truncation, notes and trace metadata of events are not
+// kept.
+func (h History) GenerateGoTest(w io.Writer, funcName string) error {
+	b := new(strings.Builder)
+	fmt.Fprintf(b, "func %s() (stmtflow.History, error) {\n", funcName)
+	b.WriteString("\treturn stmtflow.NewHistoryBuilder().\n")
+	for i := range h {
+		line, err := h[i].goBuilderCall()
+		if err != nil {
+			return fmt.Errorf("event #%d: %v", i, err)
+		}
+		b.WriteString("\t\t" + line + ".\n")
+	}
+	b.WriteString("\t\tBuild()\n}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (e *Event) goBuilderCall() (string, error) {
+	switch e.Kind {
+	case EventBlock:
+		return "Block(" + strconv.Quote(e.Session) + ")", nil
+	case EventResume:
+		return "Resume(" + strconv.Quote(e.Session) + ")", nil
+	case EventInvoke:
+		return "Invoke(" + goStmt(e.inv.Stmt) + ")", nil
+	case EventSkip:
+		return "Skip(" + goStmt(e.inv.Stmt) + ")", nil
+	case EventAbort:
+		err := WrapError(e.ret.Err).(*Error)
+		return fmt.Sprintf("Abort(%d, %s)", err.Code, strconv.Quote(err.Message)), nil
+	case EventReturn:
+		if err := e.ResultError(); err != nil {
+			return "", err
+		}
+		ret := e.loadResult()
+		stmt := goStmt(ret.Stmt)
+		switch {
+		case ret.Err != nil:
+			err := WrapError(ret.Err).(*Error)
+			return fmt.Sprintf("Error(%s, %d, %s)", stmt, err.Code, strconv.Quote(err.Message)), nil
+		case ret.Res == nil && len(ret.Digest) > 0:
+			return fmt.Sprintf("Digest(%s, %s)", stmt, strconv.Quote(ret.Digest)), nil
+		case ret.Res == nil:
+			return "", errors.New("return without result")
+		case ret.Res.IsExecResult():
+			n, ok := ret.Res.RowsAffected()
+			if !ok {
+				n = -1
+			}
+			id, ok := ret.Res.LastInsertId()
+			if !ok {
+				id = -1
+			}
+			return fmt.Sprintf("Exec(%s, %d, %d)", stmt, n, id), nil
+		default:
+			return fmt.Sprintf("Rows(%s, %s, %s)", stmt, goColumns(ret.Res), goRows(ret.Res)), nil
+		}
+	default:
+		return "", fmt.Errorf("unknown event kind: %s", e.Kind)
+	}
+}
+
+var goStmtFlags = []struct {
+	flag uint
+	name string
+}{{S_QUERY, "S_QUERY"}, {S_WAIT, "S_WAIT"}, {S_UNORDERED, "S_UNORDERED"}, {S_IGNORE_ERROR, "S_IGNORE_ERROR"}}
+
+func goStmt(s Stmt) string {
+	fields := []string{"Sess: " + strconv.Quote(s.Sess), "SQL: " + strconv.Quote(s.SQL)}
+	if s.Flags != 0 {
+		var flags []string
+		rest := s.Flags
+		for _, f := range goStmtFlags {
+			if rest&f.flag > 0 {
+				flags = append(flags, "stmtflow."+f.name)
+				rest &^= f.flag
+			}
+		}
+		if rest != 0 {
+			flags = append(flags, strconv.FormatUint(uint64(rest), 10))
+		}
+		fields = append(fields, "Flags: "+strings.Join(flags, " | "))
+	}
+	if len(s.Capture) > 0 {
+		fields = append(fields, "Capture: "+strconv.Quote(s.Capture))
+	}
+	return "stmtflow.Stmt{" + strings.Join(fields, ", ") + "}"
+}
+
+func goColumns(rs *resultset.ResultSet) string {
+	cols := make([]string, rs.NCols())
+	for j := range cols {
+		def := rs.ColumnDef(j)
+		cols[j] = "{Name: " + strconv.Quote(def.Name) + ", Type: " + strconv.Quote(def.Type) + "}"
+	}
+	return "[]resultset.ColumnDef{" + strings.Join(cols, ", ") + "}"
+}
+
+func goRows(rs *resultset.ResultSet) string {
+	rows := make([]string, rs.NRows())
+	for i := range rows {
+		cells := make([]string, rs.NCols())
+		for j := range cells {
+			if v, _ := rs.RawValue(i, j); v != nil {
+				cells[j] = strconv.Quote(string(v))
+			} else {
+				cells[j] = "resultset.Null"
+			}
+		}
+		rows[i] = "{" + strings.Join(cells, ", ") + "}"
+	}
+	return "[][]string{" + strings.Join(rows, ", ") + "}"
+}
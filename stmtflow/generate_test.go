@@ -0,0 +1,70 @@
+package stmtflow
+
+import (
+	"bytes"
+	"errors"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zyguan/sqlz/resultset"
+)
+
+func TestHistoryGenerateGoTest(t *testing.T) {
+	q := Stmt{Sess: "s1", SQL: "select a, b from t where s = \"x\"", Flags: S_QUERY}
+	u := Stmt{Sess: "s2", SQL: "update t set a = 1", Flags: S_WAIT | S_IGNORE_ERROR}
+	e := Stmt{Sess: "s1", SQL: "insert into t values (1)"}
+	rs, err := resultset.FromJSON([]byte(`{"columns":[{"name":"a","type":"INT"},{"name":"b","type":"VARCHAR"}],"rows":[[1,null],[2,"x\ny"],[3,""]]}`))
+	require.NoError(t, err)
+	h := History{
+		NewInvokeEvent("s1", Invoke{q}), NewReturnEvent("s1", Return{Stmt: q, Res: rs}),
+		NewInvokeEvent("s2", Invoke{u}), NewBlockEvent("s2"),
+		NewInvokeEvent("s1", Invoke{e}), NewReturnEvent("s1", Return{Stmt: e, Res: resultset.NewFromResult(driverResult(1))}),
+		NewResumeEvent("s2"), NewReturnEvent("s2", Return{Stmt: u, Err: &Error{1213, "Deadlock found"}}),
+		NewAbortEvent(WrapError(errors.New("timeout"))),
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, h.GenerateGoTest(buf, "expectHistory"))
+	require.Equal(t, `func expectHistory() (stmtflow.History, error) {
+	return stmtflow.NewHistoryBuilder().
+		Invoke(stmtflow.Stmt{Sess: "s1", SQL: "select a, b from t where s = \"x\"", Flags: stmtflow.S_QUERY}).
+		Rows(stmtflow.Stmt{Sess: "s1", SQL: "select a, b from t where s = \"x\"", Flags: stmtflow.S_QUERY}, []resultset.ColumnDef{{Name: "a", Type: "INT"}, {Name: "b", Type: "VARCHAR"}}, [][]string{{"1", resultset.Null}, {"2", "x\ny"}, {"3", ""}}).
+		Invoke(stmtflow.Stmt{Sess: "s2", SQL: "update t set a = 1", Flags: stmtflow.S_WAIT | stmtflow.S_IGNORE_ERROR}).
+		Block("s2").
+		Invoke(stmtflow.Stmt{Sess: "s1", SQL: "insert into t values (1)"}).
+		Exec(stmtflow.Stmt{Sess: "s1", SQL: "insert into t values (1)"}, 1, -1).
+		Resume("s2").
+		Error(stmtflow.Stmt{Sess: "s2", SQL: "update t set a = 1", Flags: stmtflow.S_WAIT | stmtflow.S_IGNORE_ERROR}, 1213, "Deadlock found").
+		Abort(-1, "timeout").
+		Build()
+}
+`, buf.String())
+	_, err = parser.ParseFile(token.NewFileSet(), "", "package p\n"+buf.String(), 0)
+	require.NoError(t, err)
+
+	// the same calls as the generated code
+	built, err := NewHistoryBuilder().
+		Invoke(q).
+		Rows(q, []resultset.ColumnDef{{Name: "a", Type: "INT"}, {Name: "b", Type: "VARCHAR"}}, [][]string{{"1", resultset.Null}, {"2", "x\ny"}, {"3", ""}}).
+		Invoke(u).
+		Block("s2").
+		Invoke(e).
+		Exec(e, 1, -1).
+		Resume("s2").
+		Error(u, 1213, "Deadlock found").
+		Abort(-1, "timeout").
+		Build()
+	require.NoError(t, err)
+	require.Len(t, built, len(h))
+	for i := range h {
+		ok, msg := h[i].EqualTo(built[i])
+		require.True(t, ok, "#%d: %s", i, msg)
+	}
+	require.Equal(t, h.Text(TextDumpOptions{Verbose: true}), built.Text(TextDumpOptions{Verbose: true}))
+
+	_, err = NewHistoryBuilder().Rows(q, []resultset.ColumnDef{{Name: "a", Type: "INT"}}, [][]string{{"1", "2"}}).Build()
+	require.Error(t, err)
+	require.Error(t, History{NewReturnEvent("s1", Return{Stmt: q})}.GenerateGoTest(buf, "f"))
+}
@@ -0,0 +1,84 @@
+package stmtflow
+
+import (
+	"io"
+	"strings"
+
+	"github.com/zyguan/sqlz/resultset"
+)
+
+func (h History) dumpGrid(w io.Writer, opts TextDumpOptions) error {
+	var (
+		sessions []string
+		index    = map[string]int{}
+	)
+	for _, e := range h {
+		if _, ok := index[e.Session]; !ok {
+			index[e.Session] = len(sessions)
+			sessions = append(sessions, e.Session)
+		}
+	}
+	widths := make([]int, len(sessions))
+	for j, s := range sessions {
+		widths[j] = resultset.DisplayWidth(s)
+	}
+	cells := make([]string, len(h))
+	for i, e := range h {
+		cells[i] = e.gridCell(opts)
+		if n := resultset.DisplayWidth(cells[i]); n > widths[index[e.Session]] {
+			widths[index[e.Session]] = n
+		}
+	}
+
+	line := make([]string, len(sessions))
+	writeLine := func(sep string) error {
+		for j := range line {
+			line[j] += strings.Repeat(" ", widths[j]-resultset.DisplayWidth(line[j]))
+		}
+		_, err := io.WriteString(w, strings.TrimRight(strings.Join(line, sep), " ")+"\n")
+		return err
+	}
+	copy(line, sessions)
+	if err := writeLine(" | "); err != nil {
+		return err
+	}
+	for j := range line {
+		line[j] = strings.Repeat("-", widths[j])
+	}
+	if err := writeLine("-+-"); err != nil {
+		return err
+	}
+	for i, e := range h {
+		for j := range line {
+			line[j] = ""
+		}
+		line[index[e.Session]] = cells[i]
+		if err := writeLine(" | "); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Event) gridCell(opts TextDumpOptions) string {
+	switch e.Kind {
+	case EventInvoke:
+		return opts.sqlText(strings.Join(strings.Fields(e.Invoke().SQL), " "))
+	case EventReturn:
+		ret := e.Return()
+		if ret.Err != nil {
+			return ">> " + ret.Err.Error()
+		}
+		return ">> " + opts.summary(ret)
+	case EventBlock:
+		return ">> blocked"
+	case EventResume:
+		return ">> resumed"
+	case EventSkip:
+		return ">> skipped"
+	case EventAbort:
+		return ">> aborted: " + e.ret.Err.Error()
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,554 @@
+package stmtflow
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/zyguan/sqlz/resultset"
+)
+
+func (h History) Len() int { return len(h) }
+
+func (h History) IsEmpty() bool { return len(h) == 0 }
+
+func (h History) Append(events ...Event) History {
+	out := make(History, 0, len(h)+len(events))
+	out = append(out, h...)
+	return append(out, events...)
+}
+
+// Head returns the first n events (or all of them if there are fewer), sharing the backing array with h.
+func (h History) Head(n int) History {
+	return h[:clampLen(n, len(h))]
+}
+
+// Tail returns the last n events (or all of them if there are fewer), sharing the backing array with h.
+func (h History) Tail(n int) History {
+	return h[len(h)-clampLen(n, len(h)):]
+}
+
+func clampLen(n int, max int) int {
+	if n < 0 {
+		return 0
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
+
+// ReplaceSession returns a copy of the history with the session renamed, statements recorded in the events follow
+// the new name as well. The original history is left untouched.
+func (h History) ReplaceSession(oldName string, newName string) History {
+	out := make(History, len(h))
+	for i, e := range h {
+		if e.Session == oldName {
+			e.Session = newName
+		}
+		if e.inv != nil && e.inv.Sess == oldName {
+			inv := *e.inv
+			inv.Sess = newName
+			e.inv = &inv
+		}
+		if e.ret != nil && e.ret.Sess == oldName {
+			ret := *e.ret
+			ret.Sess = newName
+			e.ret = &ret
+		}
+		out[i] = e
+	}
+	return out
+}
+
+// StripTimings returns a copy of the history with timestamps of returns zeroed, so that histories of different runs
+// render and serialize identically. The original history is left untouched.
+func (h History) StripTimings() History {
+	out := make(History, len(h))
+	for i, e := range h {
+		if e.ret != nil {
+			ret := *e.ret
+			ret.T = [2]time.Time{}
+			e.ret = &ret
+		}
+		out[i] = e
+	}
+	return out
+}
+
+// AnnotateWith returns a copy of the history with notes attached to events by their indexes (see EventMeta.Note), an
+// empty note removes the existing one and indexes out of range are ignored. The original history is left untouched.
+func (h History) AnnotateWith(annotations map[int]string) History {
+	out := append(History{}, h...)
+	for i, note := range annotations {
+		if i >= 0 && i < len(out) {
+			out[i].Note = note
+		}
+	}
+	return out
+}
+
+// Annotations returns notes attached to events by their indexes.
+func (h History) Annotations() map[int]string {
+	notes := make(map[int]string)
+	for i, e := range h {
+		if len(e.Note) > 0 {
+			notes[i] = e.Note
+		}
+	}
+	return notes
+}
+
+// Merge merges histories into one, events of each history keep their order. Events are ordered by sequence numbers
+// (see EventMeta.Seq) when both have, otherwise by timestamps, where an event without a timestamp takes the finish
+// time of the next return in its history. Ties are broken by the order of histories.
+func Merge(histories ...History) History {
+	var out History
+	pos := make([]int, len(histories))
+	times := make([][]time.Time, len(histories))
+	for k, h := range histories {
+		times[k] = h.eventTimes()
+	}
+	before := func(a int, b int) bool {
+		x, y := histories[a][pos[a]], histories[b][pos[b]]
+		if x.Seq > 0 && y.Seq > 0 {
+			return x.Seq < y.Seq
+		}
+		tx, ty := times[a][pos[a]], times[b][pos[b]]
+		return !tx.IsZero() && (ty.IsZero() || tx.Before(ty))
+	}
+	for {
+		next := -1
+		for k, h := range histories {
+			if pos[k] < len(h) && (next < 0 || before(k, next)) {
+				next = k
+			}
+		}
+		if next < 0 {
+			return out
+		}
+		out = append(out, histories[next][pos[next]])
+		pos[next]++
+	}
+}
+
+// eventTimes returns the finish time of the next return (at or after each event), zero if there is none.
+func (h History) eventTimes() []time.Time {
+	times := make([]time.Time, len(h))
+	var t time.Time
+	for i := len(h) - 1; i >= 0; i-- {
+		if e := h[i]; e.Kind == EventReturn && e.ret != nil && !e.ret.T[1].IsZero() {
+			t = e.ret.T[1]
+		}
+		times[i] = t
+	}
+	return times
+}
+
+// BlockedBy reports whether the session has been blocked (and resumed) and, if so, which session unblocked it. The
+// blocker is the session of the commit (or rollback) returned right before the resume, it's empty when there is no
+// such statement, i.e. the blocker is unknown.
+func (h History) BlockedBy(session string) (blocker string, ok bool) {
+	blocked := false
+	for i, e := range h {
+		if e.Session != session {
+			continue
+		}
+		if e.Kind == EventBlock {
+			blocked = true
+		} else if e.Kind == EventResume && blocked {
+			if j := h.unblocker(i); j >= 0 {
+				return h[j].Session, true
+			}
+			return "", true
+		}
+	}
+	return "", false
+}
+
+// unblocker returns the index of the commit (or rollback) of another session returned right before the resume event
+// h[i], -1 if there is none.
+func (h History) unblocker(i int) int {
+	for j := i - 1; j >= 0; j-- {
+		e := h[j]
+		if e.Session == h[i].Session {
+			break
+		}
+		if e.Kind != EventReturn || e.ret == nil {
+			continue
+		}
+		if kw := leadingKeyword(e.ret.SQL); kw == "commit" || kw == "rollback" {
+			return j
+		}
+	}
+	return -1
+}
+
+// LongestChain returns the longest chain of causally dependent events, which is the critical path of a concurrent
+// history. An event depends on the previous event of the same session, and a resume event also depends on the event
+// unblocking it if it's known (see BlockedBy). Ties are broken by the chain ending first.
+func (h History) LongestChain() []Event {
+	if len(h) == 0 {
+		return nil
+	}
+	length, prev := make([]int, len(h)), make([]int, len(h))
+	last := make(map[string]int)
+	end := 0
+	for i, e := range h {
+		length[i], prev[i] = 1, -1
+		if j, ok := last[e.Session]; ok {
+			length[i], prev[i] = length[j]+1, j
+		}
+		if e.Kind == EventResume {
+			if j := h.unblocker(i); j >= 0 && length[j]+1 > length[i] {
+				length[i], prev[i] = length[j]+1, j
+			}
+		}
+		last[e.Session] = i
+		if length[i] > length[end] {
+			end = i
+		}
+	}
+	chain := make([]Event, length[end])
+	for i, k := end, len(chain)-1; i >= 0; i, k = prev[i], k-1 {
+		chain[k] = h[i]
+	}
+	return chain
+}
+
+func leadingKeyword(sql string) string {
+	for {
+		sql = strings.TrimSpace(sql)
+		if strings.HasPrefix(sql, "/*") {
+			end := strings.Index(sql, "*/")
+			if end < 0 {
+				return ""
+			}
+			sql = sql[end+2:]
+		} else if strings.HasPrefix(sql, "--") || strings.HasPrefix(sql, "#") {
+			end := strings.IndexByte(sql, '\n')
+			if end < 0 {
+				return ""
+			}
+			sql = sql[end+1:]
+		} else {
+			break
+		}
+	}
+	end := strings.IndexFunc(sql, func(r rune) bool {
+		return !(r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r))
+	})
+	if end >= 0 {
+		sql = sql[:end]
+	}
+	return strings.ToLower(sql)
+}
+
+// SplitAtBarrier splits h into phases separated by block/resume pairs of session. Every phase but the last ends with
+// the resume event of a pair, so the pair and events happening while session is blocked belong to the phase it
+// concludes. A trailing empty phase is omitted, and a block that is never resumed is not a barrier. The phases share
+// the backing array with h.
+func (h History) SplitAtBarrier(session string) []History {
+	var (
+		phases  []History
+		start   int
+		blocked bool
+	)
+	for i, e := range h {
+		if e.Session != session {
+			continue
+		}
+		switch e.Kind {
+		case EventBlock:
+			blocked = true
+		case EventResume:
+			if blocked {
+				phases = append(phases, h[start:i+1])
+				start, blocked = i+1, false
+			}
+		}
+	}
+	if start < len(h) || len(phases) == 0 {
+		phases = append(phases, h[start:])
+	}
+	return phases
+}
+
+func (h History) EventsBetween(after Event, before Event) History {
+	start := h.indexOf(after, 0)
+	if start < 0 {
+		return History{}
+	}
+	end := h.indexOf(before, start+1)
+	if end < 0 {
+		return History{}
+	}
+	return h[start+1 : end]
+}
+
+func (h History) indexOf(e Event, from int) int {
+	for i := from; i < len(h); i++ {
+		if ok, _ := h[i].EqualTo(e); ok {
+			return i
+		}
+	}
+	return -1
+}
+
+// InvokeReturnLatencies returns latencies of all returns in order, returns with an error contribute -1.
+func (h History) InvokeReturnLatencies() []time.Duration {
+	var lats []time.Duration
+	for _, e := range h {
+		if e.Kind != EventReturn || e.ret == nil {
+			continue
+		}
+		if e.ret.Err != nil {
+			lats = append(lats, -1)
+		} else {
+			lats = append(lats, e.ret.T[1].Sub(e.ret.T[0]))
+		}
+	}
+	return lats
+}
+
+// MedianLatency returns the median latency of successful statements, or zero if there is none.
+func (h History) MedianLatency() time.Duration {
+	var lats []time.Duration
+	for _, lat := range h.InvokeReturnLatencies() {
+		if lat >= 0 {
+			lats = append(lats, lat)
+		}
+	}
+	if len(lats) == 0 {
+		return 0
+	}
+	sort.Slice(lats, func(i, j int) bool { return lats[i] < lats[j] })
+	n := len(lats)
+	if n%2 == 1 {
+		return lats[n/2]
+	}
+	return (lats[n/2-1] + lats[n/2]) / 2
+}
+
+// EventMetrics aggregates a history. Latencies are of successful statements, they are all zero if there is none.
+type EventMetrics struct {
+	TotalEvents  int
+	TotalInvokes int
+	TotalReturns int
+	TotalErrors  int
+	TotalBlocks  int
+	TotalResumes int
+	Sessions     int
+	TotalLatency time.Duration
+	MaxLatency   time.Duration
+	MinLatency   time.Duration
+}
+
+// ComputeMetrics computes EventMetrics of the history in a single pass.
+func (h History) ComputeMetrics() EventMetrics {
+	m := EventMetrics{TotalEvents: len(h)}
+	sessions := make(map[string]struct{})
+	succeeded := 0
+	for _, e := range h {
+		if e.Kind != EventAbort {
+			sessions[e.Session] = struct{}{}
+		}
+		switch e.Kind {
+		case EventInvoke:
+			m.TotalInvokes++
+		case EventBlock:
+			m.TotalBlocks++
+		case EventResume:
+			m.TotalResumes++
+		case EventReturn:
+			m.TotalReturns++
+			if e.ret == nil {
+				continue
+			}
+			if e.ret.Err != nil {
+				m.TotalErrors++
+				continue
+			}
+			lat := e.ret.T[1].Sub(e.ret.T[0])
+			m.TotalLatency += lat
+			if succeeded == 0 || lat > m.MaxLatency {
+				m.MaxLatency = lat
+			}
+			if succeeded == 0 || lat < m.MinLatency {
+				m.MinLatency = lat
+			}
+			succeeded++
+		}
+	}
+	m.Sessions = len(sessions)
+	return m
+}
+
+// ErrorCodes counts errors returned in the history by their codes (see WrapError), errors without a code are counted
+// as -1.
+func (h History) ErrorCodes() map[int]int {
+	codes := make(map[int]int)
+	for _, e := range h {
+		if e.Kind == EventReturn && e.ret != nil && e.ret.Err != nil {
+			codes[WrapError(e.ret.Err).(*Error).Code]++
+		}
+	}
+	return codes
+}
+
+type SessionContention struct {
+	Session string
+	Blocks  int
+}
+
+// ContentionRank counts block events of sessions, the ones blocked more often come first and ties are in the order
+// sessions are first blocked. Sessions never blocked are left out.
+func (h History) ContentionRank() []SessionContention {
+	var rank []SessionContention
+	index := make(map[string]int)
+	for _, e := range h {
+		if e.Kind != EventBlock {
+			continue
+		}
+		i, ok := index[e.Session]
+		if !ok {
+			i = len(rank)
+			index[e.Session] = i
+			rank = append(rank, SessionContention{Session: e.Session})
+		}
+		rank[i].Blocks++
+	}
+	sort.SliceStable(rank, func(i, j int) bool { return rank[i].Blocks > rank[j].Blocks })
+	return rank
+}
+
+// MostContended returns the session blocked most often along with its number of blocks, see ContentionRank. It
+// returns an empty session if no session is ever blocked.
+func (h History) MostContended() (session string, blockCount int) {
+	if rank := h.ContentionRank(); len(rank) > 0 {
+		return rank[0].Session, rank[0].Blocks
+	}
+	return "", 0
+}
+
+type ThroughputPoint struct {
+	Start time.Time
+	Count int
+}
+
+// maxThroughputPoints bounds the number of buckets Throughput returns.
+const maxThroughputPoints = 1 << 16
+
+// Throughput counts completed statements per bucket. Buckets start at the earliest invocation time recorded by the
+// returns (or the completion time of a return without one) and cover the last completion, including buckets without
+// any completion. It returns nil if there is no completion, and an error if bucket isn't positive or the history spans
+// more than 65536 buckets.
+func (h History) Throughput(bucket time.Duration) ([]ThroughputPoint, error) {
+	if bucket <= 0 {
+		return nil, fmt.Errorf("invalid bucket: %s", bucket)
+	}
+	var start, end time.Time
+	for _, e := range h {
+		if e.Kind != EventReturn || e.ret == nil || e.ret.T[1].IsZero() {
+			continue
+		}
+		t := e.ret.T[0]
+		if t.IsZero() || t.After(e.ret.T[1]) {
+			t = e.ret.T[1]
+		}
+		if start.IsZero() || t.Before(start) {
+			start = t
+		}
+		if e.ret.T[1].After(end) {
+			end = e.ret.T[1]
+		}
+	}
+	if end.IsZero() {
+		return nil, nil
+	}
+	n := end.Sub(start) / bucket
+	if n < 0 || n >= maxThroughputPoints {
+		return nil, fmt.Errorf("%s spans more than %d buckets of %s", end.Sub(start), maxThroughputPoints, bucket)
+	}
+	points := make([]ThroughputPoint, int(n)+1)
+	for i := range points {
+		points[i].Start = start.Add(time.Duration(i) * bucket)
+	}
+	for _, e := range h {
+		if e.Kind != EventReturn || e.ret == nil || e.ret.T[1].IsZero() {
+			continue
+		}
+		points[int(e.ret.T[1].Sub(start)/bucket)].Count++
+	}
+	return points, nil
+}
+
+// Digest folds every event (kind, session, statement, error, rows affected and data digest, timestamps excluded) into a
+// single digest, so that histories with equal digests are expected to be equal to each other event by event. The order
+// of events matters. Data digests are computed by opts, like what EqualTo does. A digest-only result can be folded only
+// if it's recorded by opts.Hash and opts don't normalize data, otherwise an error is returned, so is a lazily loaded
+// result which fails to decode (see Event.ResultError).
+func (h History) Digest(opts resultset.DigestOptions) (string, error) {
+	if !opts.Hash.Valid() {
+		return "", fmt.Errorf("unknown digest algorithm: %s", opts.Hash)
+	}
+	if len(opts.Hash) == 0 {
+		opts.Hash = resultset.DigestSHA1
+	}
+	d := opts.Hash.New()
+	write := func(s string) { fmt.Fprintf(d, "%d:%s;", len(s), s) }
+	for i, e := range h {
+		write(string(e.Kind))
+		write(e.Session)
+		switch {
+		case e.inv != nil:
+			write(e.inv.SQL)
+			write(strconv.FormatUint(uint64(e.inv.Flags), 10))
+		case e.ret != nil:
+			if err := e.ResultError(); err != nil {
+				return "", fmt.Errorf("event #%d: %w", i, err)
+			}
+			ret := e.loadResult()
+			write(ret.Stmt.SQL)
+			write(strconv.FormatUint(uint64(ret.Stmt.Flags), 10))
+			if ret.Err != nil && ret.Stmt.Flags&S_IGNORE_ERROR > 0 {
+				write("ignored error")
+				continue
+			}
+			if ret.Err != nil {
+				err := WrapError(ret.Err).(*Error)
+				write("error")
+				write(strconv.Itoa(err.Code))
+				if err.Code < 0 {
+					write(err.Message)
+				}
+				continue
+			}
+			if ret.Res != nil && ret.Res.IsExecResult() {
+				write("exec")
+				if n, ok := ret.Res.RowsAffected(); ok {
+					write(strconv.FormatInt(n, 10))
+				} else {
+					write("?")
+				}
+				continue
+			}
+			unordered := ret.Stmt.Flags&S_UNORDERED > 0
+			if ret.Res == nil && len(ret.Digest) > 0 {
+				if a := resultset.DigestAlgorithmOf(ret.Digest); a != opts.Hash {
+					return "", fmt.Errorf("event #%d: expect digest algorithm %s, got %s", i, opts.Hash, a)
+				}
+				if !digestOnlyComparable(opts, unordered) {
+					return "", fmt.Errorf("event #%d: digest options cannot be applied to a digest-only result", i)
+				}
+			}
+			o := opts
+			o.Sort = o.Sort || unordered
+			write(ret.digest(o))
+		}
+	}
+	return opts.Hash.Format(d.Sum(nil)), nil
+}
@@ -0,0 +1,430 @@
+package stmtflow
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zyguan/sqlz/resultset"
+)
+
+func TestHistoryLen(t *testing.T) {
+	var h History
+	require.Equal(t, 0, h.Len())
+	require.True(t, h.IsEmpty())
+	h = History{NewBlockEvent("s1"), NewResumeEvent("s1")}
+	require.Equal(t, 2, h.Len())
+	require.False(t, h.IsEmpty())
+}
+
+func TestHistoryAppend(t *testing.T) {
+	h1 := make(History, 1, 4)
+	h1[0] = NewBlockEvent("s1")
+	h2 := h1.Append(NewResumeEvent("s1"))
+	h3 := h1.Append(NewBlockEvent("s2"), NewResumeEvent("s2"))
+	require.Len(t, h1, 1)
+	require.Equal(t, History{NewBlockEvent("s1"), NewResumeEvent("s1")}, h2)
+	require.Equal(t, History{NewBlockEvent("s1"), NewBlockEvent("s2"), NewResumeEvent("s2")}, h3)
+}
+
+func TestHistoryHeadTail(t *testing.T) {
+	h := History{NewBlockEvent("s1"), NewResumeEvent("s1"), NewBlockEvent("s2")}
+	require.Equal(t, h[:2], h.Head(2))
+	require.Equal(t, h[1:], h.Tail(2))
+	require.Equal(t, h, h.Head(5))
+	require.Equal(t, h, h.Tail(5))
+	require.Empty(t, h.Head(-1))
+	require.Empty(t, h.Tail(0))
+	require.Empty(t, History(nil).Tail(3))
+
+	h.Head(1)[0] = NewResumeEvent("s3")
+	require.Equal(t, NewResumeEvent("s3"), h[0])
+	h.Tail(1)[0] = NewResumeEvent("s3")
+	require.Equal(t, NewResumeEvent("s3"), h[2])
+}
+
+func TestHistoryReplaceSession(t *testing.T) {
+	inv, ret := newInvRet("s1", "select 1", nil)
+	h := History{inv, NewBlockEvent("s1"), NewResumeEvent("s1"), ret, NewBlockEvent("s2")}
+	h2 := h.ReplaceSession("s1", "s3")
+	require.Equal(t, "s1", h[0].Session)
+	require.Equal(t, "s1", h[0].Invoke().Sess)
+	require.Equal(t, "s1", h[3].Return().Sess)
+	for _, e := range h2[:4] {
+		require.Equal(t, "s3", e.Session)
+	}
+	require.Equal(t, "s3", h2[0].Invoke().Sess)
+	require.Equal(t, "select 1", h2[0].Invoke().SQL)
+	require.Equal(t, "s3", h2[3].Return().Sess)
+	require.Equal(t, NewBlockEvent("s2"), h2[4])
+	require.Equal(t, "/* s3 */ select 1\n", h2[0].Text(TextDumpOptions{}))
+}
+
+func TestHistoryLongestChain(t *testing.T) {
+	require.Nil(t, History{}.LongestChain())
+
+	i1, r1 := newInvRet("s1", "update t set v = 1 where id = 1", nil)
+	i2, r2 := newInvRet("s2", "update t set v = 2 where id = 1", nil)
+	i3, r3 := newInvRet("s3", "select 1", nil)
+	ic, rc := newInvRet("s1", "commit", nil)
+	h := History{i1, r1, i2, NewBlockEvent("s2"), i3, r3, ic, rc, NewResumeEvent("s2"), r2}
+	require.Equal(t, []Event{i1, r1, ic, rc, NewResumeEvent("s2"), r2}, h.LongestChain())
+
+	// without the block, sessions are independent and the first longest one wins
+	h = History{i1, r1, i2, i3, r3, ic, rc, r2}
+	require.Equal(t, []Event{i1, r1, ic, rc}, h.LongestChain())
+
+	// an unknown unblocker adds no dependency
+	h = History{i1, r1, i2, NewBlockEvent("s2"), i3, r3, NewResumeEvent("s2"), r2}
+	require.Equal(t, []Event{i2, NewBlockEvent("s2"), NewResumeEvent("s2"), r2}, h.LongestChain())
+}
+
+func newInvRet(s string, sql string, err error) (Event, Event) {
+	stmt := Stmt{Sess: s, SQL: sql}
+	return NewInvokeEvent(s, Invoke{stmt}), NewReturnEvent(s, Return{Stmt: stmt, Err: err})
+}
+
+func TestHistoryBlockedBy(t *testing.T) {
+	i1, r1 := newInvRet("s1", "update t set v = 1 where id = 1", nil)
+	i2, r2 := newInvRet("s2", "update t set v = 2 where id = 1", nil)
+	i3, r3 := newInvRet("s3", "select 1", nil)
+	ic, rc := newInvRet("s1", "/* s1 */ COMMIT", nil)
+	h := History{i1, r1, i2, NewBlockEvent("s2"), i3, r3, ic, rc, NewResumeEvent("s2"), r2}
+
+	blocker, ok := h.BlockedBy("s2")
+	require.True(t, ok)
+	require.Equal(t, "s1", blocker)
+	_, ok = h.BlockedBy("s1")
+	require.False(t, ok)
+	_, ok = h.BlockedBy("s3")
+	require.False(t, ok)
+
+	// a resume without a preceding commit or rollback is not attributed to anyone
+	h = History{i1, r1, i2, NewBlockEvent("s2"), i3, r3, NewResumeEvent("s2"), r2}
+	blocker, ok = h.BlockedBy("s2")
+	require.True(t, ok)
+	require.Empty(t, blocker)
+	_, ok = h[:4].BlockedBy("s2")
+	require.False(t, ok)
+}
+
+func TestHistoryDumpGrid(t *testing.T) {
+	i1, r1 := newInvRet("s1", "update t set v = 1 where id = 1", nil)
+	i2, r2 := newInvRet("s2", "update t\n  set v = 2 where id = 1", &Error{1213, "Deadlock found"})
+	h := History{i1, r1, i2, NewBlockEvent("s2"), NewResumeEvent("s2"), r2}
+	h[1].ret.Res = resultset.NewFromResult(driverResult(1))
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, h.DumpText(buf, TextDumpOptions{Grid: true}))
+	require.Equal(t, strings.Join([]string{
+		"s1                              | s2",
+		"--------------------------------+--------------------------------",
+		"update t set v = 1 where id = 1 |",
+		">> 1 rows affected              |",
+		"                                | update t set v = 2 where id = 1",
+		"                                | >> blocked",
+		"                                | >> resumed",
+		"                                | >> E1213: Deadlock found",
+		"",
+	}, "\n"), buf.String())
+}
+
+func TestHistoryAnnotateWith(t *testing.T) {
+	i1, r1 := newInvRet("s1", "update t set v = 1 where id = 1", nil)
+	i2, r2 := newInvRet("s2", "update t set v = 2 where id = 1", &Error{1213, "Deadlock found"})
+	h := History{i1, r1, i2, r2}
+	h[1].ret.Res = resultset.NewFromResult(driverResult(1))
+	notes := map[int]string{2: "s2 waits for the lock held by s1", 3: "deadlock\ndetected", 9: "ignored"}
+	annotated := h.AnnotateWith(notes)
+	require.Empty(t, h.Annotations())
+	delete(notes, 9)
+	require.Equal(t, notes, annotated.Annotations())
+	require.Contains(t, annotated.Text(TextDumpOptions{}), strings.Join([]string{
+		"-- note: s2 waits for the lock held by s1",
+		"/* s2 */ update t set v = 2 where id = 1",
+		"-- note: deadlock",
+		"-- note: detected",
+		"-- s2 >> E1213: Deadlock found",
+	}, "\n"))
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, annotated.DumpJson(buf, JsonDumpOptions{}))
+	require.Contains(t, buf.String(), `"note":"deadlock\ndetected"`)
+	var loaded History
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &loaded))
+	require.Equal(t, notes, loaded.Annotations())
+	for i := range h {
+		ok, msg := loaded[i].EqualTo(h[i])
+		require.True(t, ok, msg)
+	}
+	d1, err := h.Digest(resultset.DigestOptions{})
+	require.NoError(t, err)
+	d2, err := loaded.Digest(resultset.DigestOptions{})
+	require.NoError(t, err)
+	require.Equal(t, d1, d2)
+	require.Empty(t, annotated.AnnotateWith(map[int]string{2: "", 3: ""}).Annotations())
+}
+
+func TestHistoryErrorCodes(t *testing.T) {
+	i1, r1 := newInvRet("s1", "update t set v = 1", &Error{1213, "Deadlock found"})
+	i2, r2 := newInvRet("s2", "update t set v = 2", &Error{1213, "Deadlock found"})
+	i3, r3 := newInvRet("s1", "select 1", errors.New("bad connection"))
+	i4, r4 := newInvRet("s2", "select 2", nil)
+	h := History{i1, r1, i2, r2, i3, r3, i4, r4}
+	require.Equal(t, map[int]int{1213: 2, -1: 1}, h.ErrorCodes())
+	require.Empty(t, History{i4, r4}.ErrorCodes())
+}
+
+func TestHistoryContentionRank(t *testing.T) {
+	h := History{
+		NewBlockEvent("s1"), NewResumeEvent("s1"),
+		NewBlockEvent("s2"), NewResumeEvent("s2"),
+		NewBlockEvent("s3"), NewResumeEvent("s3"),
+		NewBlockEvent("s2"), NewResumeEvent("s2"),
+	}
+	require.Equal(t, []SessionContention{{"s2", 2}, {"s1", 1}, {"s3", 1}}, h.ContentionRank())
+	s, n := h.MostContended()
+	require.Equal(t, "s2", s)
+	require.Equal(t, 2, n)
+
+	i1, r1 := newInvRet("s1", "select 1", nil)
+	require.Empty(t, History{i1, r1}.ContentionRank())
+	s, n = History{i1, r1}.MostContended()
+	require.Empty(t, s)
+	require.Zero(t, n)
+}
+
+func TestMerge(t *testing.T) {
+	t0 := time.Now()
+	at := func(s string, sql string, ms int) (Event, Event) {
+		i, r := newInvRet(s, sql, nil)
+		r.ret.T = [2]time.Time{t0, t0.Add(time.Duration(ms) * time.Millisecond)}
+		return i, r
+	}
+	i1, r1 := at("s1", "select 1", 10)
+	i2, r2 := at("s1", "select 2", 30)
+	i3, r3 := at("s2", "select 3", 20)
+	i4, r4 := at("s2", "select 4", 30)
+	h1, h2 := History{i1, r1, i2, r2}, History{i3, r3, i4, r4, NewBlockEvent("s2")}
+	require.Equal(t, History{i1, r1, i3, r3, i2, r2, i4, r4, NewBlockEvent("s2")}, Merge(h1, h2))
+
+	// sequence numbers take precedence over timestamps
+	seq := func(h History, seqs ...int64) History {
+		out := append(History{}, h...)
+		for i := range out {
+			out[i].Seq = seqs[i]
+		}
+		return out
+	}
+	h1, h2 = seq(h1, 5, 6, 7, 8), seq(History{i3, r3}, 1, 2)
+	require.Equal(t, History{h2[0], h2[1], h1[0], h1[1], h1[2], h1[3]}, Merge(h1, h2))
+	require.Empty(t, Merge())
+}
+
+func TestHistoryDumpGridMixedWidth(t *testing.T) {
+	i1, r1 := newInvRet("会话1", "select '中文'", nil)
+	i2, r2 := newInvRet("s2", "select 1", nil)
+	h := History{i1, r1, i2, r2}
+	require.Equal(t, strings.Join([]string{
+		"会话1         | s2",
+		"--------------+-------------",
+		"select '中文' |",
+		">> <digest>   |",
+		"              | select 1",
+		"              | >> <digest>",
+		"",
+	}, "\n"), h.Text(TextDumpOptions{Grid: true}))
+}
+
+type driverResult int64
+
+func (r driverResult) LastInsertId() (int64, error) { return 0, errors.New("unsupported") }
+
+func (r driverResult) RowsAffected() (int64, error) { return int64(r), nil }
+
+func TestHistoryEventsBetween(t *testing.T) {
+	i1, r1 := newInvRet("s1", "begin", &Error{0, "ok"})
+	i2, r2 := newInvRet("s2", "select 1", &Error{0, "ok"})
+	h := History{i1, r1, NewBlockEvent("s1"), i2, r2, NewResumeEvent("s1")}
+
+	require.Equal(t, History{i2, r2}, h.EventsBetween(NewBlockEvent("s1"), NewResumeEvent("s1")))
+	require.Equal(t, History{r1, NewBlockEvent("s1")}, h.EventsBetween(i1, i2))
+	require.Equal(t, History{}, h.EventsBetween(i2, i2))
+	require.Equal(t, History{}, h.EventsBetween(NewResumeEvent("s1"), NewBlockEvent("s1")))
+	require.Equal(t, History{}, h.EventsBetween(NewBlockEvent("s2"), NewResumeEvent("s1")))
+}
+
+func TestHistoryThroughput(t *testing.T) {
+	t0 := time.Unix(100, 0)
+	ret := func(s string, from time.Duration, to time.Duration) Event {
+		return NewReturnEvent(s, Return{Stmt: Stmt{Sess: s}, T: [2]time.Time{t0.Add(from), t0.Add(to)}})
+	}
+	ms := time.Millisecond
+	h := History{
+		NewInvokeEvent("s1", Invoke{Stmt{Sess: "s1"}}),
+		ret("s1", 0, 20*ms),
+		ret("s2", 10*ms, 90*ms),
+		ret("s1", 30*ms, 320*ms),
+		ret("s2", 100*ms, 350*ms),
+	}
+	throughput := func(h History, bucket time.Duration) []ThroughputPoint {
+		points, err := h.Throughput(bucket)
+		require.NoError(t, err)
+		return points
+	}
+	_, err := h.Throughput(0)
+	require.EqualError(t, err, "invalid bucket: 0s")
+	require.Nil(t, throughput(History{NewBlockEvent("s1")}, time.Second))
+	require.Equal(t, []ThroughputPoint{
+		{t0, 2},
+		{t0.Add(100 * ms), 0},
+		{t0.Add(200 * ms), 0},
+		{t0.Add(300 * ms), 2},
+	}, throughput(h, 100*ms))
+
+	// a return without invocation time starts from its completion, spans too long for the bucket are rejected
+	noStart := NewReturnEvent("s3", Return{Stmt: Stmt{Sess: "s3"}, T: [2]time.Time{{}, t0.Add(50 * ms)}})
+	require.Equal(t, throughput(h, 100*ms)[0].Start, throughput(append(h, noStart), 100*ms)[0].Start)
+	require.Equal(t, 3, throughput(append(h, noStart), 100*ms)[0].Count)
+	require.Equal(t, []ThroughputPoint{{t0.Add(50 * ms), 1}}, throughput(History{noStart}, time.Second))
+	_, err = h.Throughput(time.Nanosecond)
+	require.EqualError(t, err, "350ms spans more than 65536 buckets of 1ns")
+	_, err = h.Throughput(-time.Second)
+	require.EqualError(t, err, "invalid bucket: -1s")
+}
+
+func TestHistoryLatencies(t *testing.T) {
+	t0 := time.Unix(100, 0)
+	ret := func(s string, lat time.Duration, err error) Event {
+		return NewReturnEvent(s, Return{Stmt: Stmt{Sess: s}, Err: err, T: [2]time.Time{t0, t0.Add(lat)}})
+	}
+	ms := time.Millisecond
+	h := History{
+		NewInvokeEvent("s1", Invoke{Stmt{Sess: "s1"}}),
+		ret("s1", 30*ms, nil),
+		ret("s2", 5*ms, errors.New("oops")),
+		NewBlockEvent("s1"),
+		ret("s1", 10*ms, nil),
+	}
+	require.Equal(t, []time.Duration{30 * ms, -1, 10 * ms}, h.InvokeReturnLatencies())
+	require.Equal(t, 20*ms, h.MedianLatency())
+	h = append(h, ret("s2", 15*ms, nil))
+	require.Equal(t, 15*ms, h.MedianLatency())
+	require.Nil(t, History{NewBlockEvent("s1")}.InvokeReturnLatencies())
+	require.Equal(t, time.Duration(0), History{ret("s1", ms, errors.New("oops"))}.MedianLatency())
+}
+
+func TestHistoryComputeMetrics(t *testing.T) {
+	t0 := time.Unix(100, 0)
+	ret := func(s string, lat time.Duration, err error) Event {
+		return NewReturnEvent(s, Return{Stmt: Stmt{Sess: s}, Err: err, T: [2]time.Time{t0, t0.Add(lat)}})
+	}
+	ms := time.Millisecond
+	h := History{
+		NewInvokeEvent("s1", Invoke{Stmt{Sess: "s1"}}),
+		ret("s1", 30*ms, nil),
+		NewInvokeEvent("s2", Invoke{Stmt{Sess: "s2"}}),
+		ret("s2", 5*ms, errors.New("oops")),
+		NewInvokeEvent("s1", Invoke{Stmt{Sess: "s1"}}),
+		NewBlockEvent("s1"),
+		NewResumeEvent("s1"),
+		ret("s1", 10*ms, nil),
+		NewSkipEvent("s3", Invoke{Stmt{Sess: "s3"}}),
+	}
+	require.Equal(t, EventMetrics{
+		TotalEvents:  9,
+		TotalInvokes: 3,
+		TotalReturns: 3,
+		TotalErrors:  1,
+		TotalBlocks:  1,
+		TotalResumes: 1,
+		Sessions:     3,
+		TotalLatency: 40 * ms,
+		MaxLatency:   30 * ms,
+		MinLatency:   10 * ms,
+	}, h.ComputeMetrics())
+	require.Equal(t, EventMetrics{}, History{}.ComputeMetrics())
+	require.Equal(t, EventMetrics{TotalEvents: 1, TotalReturns: 1, TotalErrors: 1, Sessions: 1}, History{ret("s1", ms, errors.New("oops"))}.ComputeMetrics())
+}
+
+func TestHistoryDigest(t *testing.T) {
+	digest := func(h History, opts resultset.DigestOptions) string {
+		d, err := h.Digest(opts)
+		require.NoError(t, err)
+		return d
+	}
+	i1, r1 := newInvRet("s1", "insert into t values (1)", nil)
+	i2, r2 := newInvRet("s2", "insert into t values (2)", &Error{Code: 1062, Message: "dup"})
+	q := newQueryRetEvent(t, "s3", `{"columns":[{"name":"a","type":"INT"}],"rows":[[1],[2]]}`)
+	h := History{i1, r1, i2, r2, q}
+	d := digest(h, resultset.DigestOptions{})
+	require.Len(t, d, 40)
+
+	// timestamps are excluded
+	r1.ret.T = [2]time.Time{time.Now(), time.Now().Add(time.Second)}
+	require.Equal(t, d, digest(History{i1, r1, i2, r2, q}, resultset.DigestOptions{}))
+	// messages of errors with codes are ignored, like EqualTo does
+	_, r2x := newInvRet("s2", "insert into t values (2)", &Error{Code: 1062, Message: "Duplicate entry"})
+	require.Equal(t, d, digest(History{i1, r1, i2, r2x, q}, resultset.DigestOptions{}))
+
+	// reordering independent sessions changes the digest
+	require.NotEqual(t, d, digest(History{i2, r2, i1, r1, q}, resultset.DigestOptions{}))
+	_, r2y := newInvRet("s2", "insert into t values (2)", &Error{Code: 1213, Message: "dup"})
+	require.NotEqual(t, d, digest(History{i1, r1, i2, r2y, q}, resultset.DigestOptions{}))
+	q2 := newQueryRetEvent(t, "s3", `{"columns":[{"name":"a","type":"INT"}],"rows":[[2],[1]]}`)
+	require.NotEqual(t, d, digest(History{i1, r1, i2, r2, q2}, resultset.DigestOptions{}))
+	require.Equal(t, digest(h, resultset.DigestOptions{Sort: true}), digest(History{i1, r1, i2, r2, q2}, resultset.DigestOptions{Sort: true}))
+
+	require.True(t, strings.HasPrefix(digest(h, resultset.DigestOptions{Hash: resultset.DigestSHA256}), "sha256:"))
+	require.Equal(t, digest(History{}, resultset.DigestOptions{}), digest(History(nil), resultset.DigestOptions{}))
+	_, err := h.Digest(resultset.DigestOptions{Hash: "md5"})
+	require.EqualError(t, err, "unknown digest algorithm: md5")
+
+	// rows affected of exec results matter
+	stmt := Stmt{Sess: "s1", SQL: "update t set a = 1"}
+	exec := func(n int64) History {
+		h, err := NewHistoryBuilder().Invoke(stmt).Exec(stmt, n, -1).Build()
+		require.NoError(t, err)
+		return h
+	}
+	require.Equal(t, digest(exec(1), resultset.DigestOptions{}), digest(exec(1), resultset.DigestOptions{}))
+	require.NotEqual(t, digest(exec(1), resultset.DigestOptions{}), digest(exec(2), resultset.DigestOptions{}))
+	require.NotEqual(t, digest(exec(-1), resultset.DigestOptions{}), digest(exec(0), resultset.DigestOptions{}))
+
+	// digest-only results are folded as they are, so they must be recorded by the same algorithm
+	sel := Stmt{Sess: "s3", SQL: "select * from t", Flags: S_QUERY}
+	rs := q.Return().Res
+	withDigest := func(opts resultset.DigestOptions) History {
+		return History{NewReturnEvent("s3", Return{Stmt: sel, Digest: rs.DataDigest(opts)})}
+	}
+	require.Equal(t, digest(History{q}, resultset.DigestOptions{}), digest(withDigest(resultset.DigestOptions{}), resultset.DigestOptions{}))
+	sha256 := resultset.DigestOptions{Hash: resultset.DigestSHA256}
+	require.Equal(t, digest(History{q}, sha256), digest(withDigest(sha256), sha256))
+	_, err = withDigest(resultset.DigestOptions{}).Digest(sha256)
+	require.EqualError(t, err, "event #0: expect digest algorithm sha256, got sha1")
+	_, err = withDigest(sha256).Digest(resultset.DigestOptions{Hash: resultset.DigestSHA1})
+	require.EqualError(t, err, "event #0: expect digest algorithm sha1, got sha256")
+	_, err = withDigest(resultset.DigestOptions{}).Digest(resultset.DigestOptions{ShapeOnly: true})
+	require.EqualError(t, err, "event #0: digest options cannot be applied to a digest-only result")
+}
+
+func TestHistorySplitAtBarrier(t *testing.T) {
+	i1, r1 := newInvRet("s1", "begin", nil)
+	i2, r2 := newInvRet("s2", "update t set v = 2 where id = 1", nil)
+	i3, r3 := newInvRet("s1", "update t set v = 1 where id = 1", nil)
+	i4, r4 := newInvRet("s2", "commit", nil)
+	b2, u2 := NewBlockEvent("s2"), NewResumeEvent("s2")
+	h := History{i1, r1, i2, b2, i3, r3, u2, r2, i4, r4}
+
+	phases := h.SplitAtBarrier("s2")
+	require.Equal(t, []History{{i1, r1, i2, b2, i3, r3, u2}, {r2, i4, r4}}, phases)
+	require.Equal(t, []History{h}, h.SplitAtBarrier("s1"))
+	require.Equal(t, []History{{i1, r1, i2, b2, i3, r3, u2}}, h[:7].SplitAtBarrier("s2"))
+	// a block never resumed is not a barrier
+	require.Equal(t, []History{h[:4]}, h[:4].SplitAtBarrier("s2"))
+	require.Equal(t, []History{{}}, History{}.SplitAtBarrier("s1"))
+}
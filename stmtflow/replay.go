@@ -0,0 +1,141 @@
+package stmtflow
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type ReplayOptions struct {
+	EvalOptions
+	// DryRun validates the history instead of executing it: every session must be resolvable (see
+	// EvalOptions.SessionMap), every SQL must pass a lightweight syntax check and blocks must be paired with resumes.
+	// No query is sent to the database.
+	DryRun bool
+}
+
+// ReplayError lists all problems found by a dry run.
+type ReplayError struct {
+	Problems []string
+}
+
+func (e *ReplayError) Error() string {
+	return fmt.Sprintf("%d problem(s) found:\n  %s", len(e.Problems), strings.Join(e.Problems, "\n  "))
+}
+
+// Stmts returns statements invoked (or skipped) in the history in order.
+func (h History) Stmts() []Stmt {
+	var stmts []Stmt
+	for _, e := range h {
+		if (e.Kind == EventInvoke || e.Kind == EventSkip) && e.inv != nil {
+			stmts = append(stmts, e.inv.Stmt)
+		}
+	}
+	return stmts
+}
+
+// Replay evaluates statements of the history again and returns the new history. With DryRun set, it only validates
+// the history and returns it as is, problems are reported as a *ReplayError.
+func (h History) Replay(ctx context.Context, db *sql.DB, opts ReplayOptions) (History, error) {
+	stmts := h.Stmts()
+	if opts.DryRun {
+		if err := h.validate(db, stmts, opts.SessionMap); err != nil {
+			return nil, err
+		}
+		return h, nil
+	}
+	var out History
+	callback := opts.Callback
+	opts.Callback = func(e Event) {
+		out.Collect(e)
+		if callback != nil {
+			callback(e)
+		}
+	}
+	err := Run(ctx, db, stmts, opts.EvalOptions)
+	return out, err
+}
+
+func (h History) validate(db *sql.DB, stmts []Stmt, sessionMap func(string) (*sql.DB, error)) error {
+	var problems []string
+	seen := make(map[string]bool)
+	for _, stmt := range stmts {
+		if seen[stmt.Sess] {
+			continue
+		}
+		seen[stmt.Sess] = true
+		if _, err := sessionDB(db, sessionMap, stmt.Sess); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	for _, stmt := range stmts {
+		if err := validateSQL(stmt.SQL); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %q: %v", stmt.Sess, stmt.SQL, err))
+		}
+	}
+	for _, issue := range h.CheckConsistency() {
+		switch issue.Kind {
+		case IssueUnresumedBlock, IssueUnexpectedBlock, IssueUnexpectedResume:
+			problems = append(problems, issue.String())
+		}
+	}
+	if len(problems) > 0 {
+		return &ReplayError{problems}
+	}
+	return nil
+}
+
+// validateSQL is a lightweight syntax check, it makes sure that sql starts with a keyword (possibly parenthesized),
+// quotes and comments are terminated and parentheses are balanced.
+func validateSQL(sql string) error {
+	if leadingKeyword(strings.TrimLeft(sql, "( \t\r\n")) == "" {
+		return errors.New("missing leading keyword")
+	}
+	depth := 0
+	for i := 0; i < len(sql); i++ {
+		switch c := sql[i]; c {
+		case '\'', '"', '`':
+			for i++; i < len(sql) && sql[i] != c; i++ {
+				if sql[i] == '\\' && c != '`' {
+					i++
+				}
+			}
+			if i >= len(sql) {
+				return fmt.Errorf("unterminated quote %c", c)
+			}
+		case '/':
+			if i+1 < len(sql) && sql[i+1] == '*' {
+				end := strings.Index(sql[i+2:], "*/")
+				if end < 0 {
+					return errors.New("unterminated comment")
+				}
+				i += end + 3
+			}
+		case '#':
+			i = lineEnd(sql, i)
+		case '-':
+			if strings.HasPrefix(sql[i:], "-- ") || strings.HasPrefix(sql[i:], "--\n") || sql[i:] == "--" {
+				i = lineEnd(sql, i)
+			}
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth < 0 {
+				return errors.New("unbalanced parentheses")
+			}
+		}
+	}
+	if depth != 0 {
+		return errors.New("unbalanced parentheses")
+	}
+	return nil
+}
+
+func lineEnd(s string, i int) int {
+	if end := strings.IndexByte(s[i:], '\n'); end >= 0 {
+		return i + end
+	}
+	return len(s)
+}
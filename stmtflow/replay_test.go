@@ -0,0 +1,82 @@
+package stmtflow
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSQL(t *testing.T) {
+	for _, sql := range []string{
+		"select 1",
+		"select ')' from t where a = (1) -- (",
+		"/* ( */ insert into `t(` values ('a\\'b', \"c\")",
+		"select 1 # )",
+		"select 1--1",
+	} {
+		require.NoError(t, validateSQL(sql), sql)
+	}
+	for sql, msg := range map[string]string{
+		"":                     "missing leading keyword",
+		"-- select 1":          "missing leading keyword",
+		"(select 1":            "unbalanced parentheses",
+		"select 1)":            "unbalanced parentheses",
+		"select 'a":            "unterminated quote '",
+		"select `a from t":     "unterminated quote `",
+		"select 1 /* comment ": "unterminated comment",
+	} {
+		require.EqualError(t, validateSQL(sql), msg, sql)
+	}
+}
+
+func TestReplayDryRun(t *testing.T) {
+	h := History{
+		NewInvokeEvent("s1", Invoke{Stmt{Sess: "s1", SQL: "select 1", Flags: S_QUERY}}),
+		NewBlockEvent("s1"),
+		NewInvokeEvent("s2", Invoke{Stmt{Sess: "s2", SQL: "update t set a = (1"}}),
+		NewReturnEvent("s2", Return{Stmt: Stmt{Sess: "s2", SQL: "update t set a = (1"}}),
+		NewResumeEvent("s2"),
+	}
+	var called []string
+	out, err := h.Replay(context.Background(), nil, ReplayOptions{DryRun: true, EvalOptions: EvalOptions{
+		SessionMap: func(s string) (*sql.DB, error) {
+			called = append(called, s)
+			if s == "s2" {
+				return nil, errors.New("unknown session")
+			}
+			return nil, nil
+		},
+	}})
+	require.Nil(t, out)
+	var rerr *ReplayError
+	require.True(t, errors.As(err, &rerr))
+	require.Equal(t, []string{"s1", "s2"}, called)
+	require.Equal(t, []string{
+		"map session s1: no database",
+		"map session s2: unknown session",
+		`s2: "update t set a = (1": unbalanced parentheses`,
+		"#4 s2: UnexpectedResume: resumed without being blocked",
+		"#1 s1: UnresumedBlock: never resumed",
+	}, rerr.Problems)
+
+	db, err := sql.Open("stmtflow-fake", "")
+	require.NoError(t, err)
+	defer db.Close()
+	h = History{
+		NewInvokeEvent("s1", Invoke{Stmt{Sess: "s1", SQL: "select 1", Flags: S_QUERY}}),
+		NewReturnEvent("s1", Return{Stmt: Stmt{Sess: "s1", SQL: "select 1", Flags: S_QUERY}}),
+		NewSkipEvent("s2", Invoke{Stmt{Sess: "s2", SQL: "insert into t values (1)"}}),
+	}
+	out, err = h.Replay(context.Background(), db, ReplayOptions{DryRun: true})
+	require.NoError(t, err)
+	require.Equal(t, h, out)
+
+	out, err = h.Replay(context.Background(), db, ReplayOptions{})
+	require.NoError(t, err)
+	require.Len(t, out, 4)
+	require.Equal(t, []Stmt{h[0].inv.Stmt, h[2].inv.Stmt}, out.Stmts())
+	require.Equal(t, EventReturn, out[3].Kind)
+}
@@ -0,0 +1,39 @@
+package stmtflow
+
+import "strings"
+
+// SessionConfig describes how the connection of a session is set up before a flow starts, see EvalOptions.Sessions.
+type SessionConfig struct {
+	Name string
+	// Isolation is the transaction isolation level of the session like "READ COMMITTED" or "SERIALIZABLE", empty
+	// keeps the server default.
+	Isolation string
+	ReadOnly  bool
+	// AutoCommit enables or disables autocommit of the session if it's set, nil keeps the server default.
+	AutoCommit *bool
+	// InitSQL are executed (in the session, whatever their Sess are) after the settings above are applied.
+	InitSQL []Stmt
+}
+
+// Stmts returns statements setting up the session in order.
+func (c SessionConfig) Stmts() []Stmt {
+	var stmts []Stmt
+	if len(c.Isolation) > 0 {
+		stmts = append(stmts, Stmt{Sess: c.Name, SQL: "SET SESSION TRANSACTION ISOLATION LEVEL " + strings.ToUpper(c.Isolation)})
+	}
+	if c.ReadOnly {
+		stmts = append(stmts, Stmt{Sess: c.Name, SQL: "SET SESSION TRANSACTION READ ONLY"})
+	}
+	if c.AutoCommit != nil {
+		if *c.AutoCommit {
+			stmts = append(stmts, Stmt{Sess: c.Name, SQL: "SET SESSION autocommit = 1"})
+		} else {
+			stmts = append(stmts, Stmt{Sess: c.Name, SQL: "SET SESSION autocommit = 0"})
+		}
+	}
+	for _, stmt := range c.InitSQL {
+		stmt.Sess = c.Name
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
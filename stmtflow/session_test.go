@@ -0,0 +1,54 @@
+package stmtflow
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionConfig(t *testing.T) {
+	db, err := sql.Open("stmtflow-fake", "")
+	require.NoError(t, err)
+	defer db.Close()
+	stmts := []Stmt{
+		{Sess: "s1", SQL: "select 1", Flags: S_QUERY},
+		{Sess: "s2", SQL: "select 2", Flags: S_QUERY},
+	}
+	on, off := true, false
+	var h History
+	require.NoError(t, Run(context.Background(), db, stmts, EvalOptions{
+		Callback: h.Collect,
+		Sessions: []SessionConfig{
+			{Name: "s1", Isolation: "serializable", ReadOnly: true, AutoCommit: &off, InitSQL: []Stmt{{SQL: "set @x = 1"}}},
+			{Name: "s2", AutoCommit: &on},
+			{Name: "s3", AutoCommit: &on},
+		},
+		SessionInit: map[string][]string{"s2": {"set @y = 2"}},
+	}))
+	var invoked []string
+	for _, e := range h {
+		if e.Kind == EventInvoke {
+			invoked = append(invoked, e.Session+": "+e.Invoke().SQL)
+		}
+	}
+	require.Equal(t, []string{
+		"s1: SET SESSION TRANSACTION ISOLATION LEVEL SERIALIZABLE",
+		"s1: SET SESSION TRANSACTION READ ONLY",
+		"s1: SET SESSION autocommit = 0",
+		"s1: set @x = 1",
+		"s2: SET SESSION autocommit = 1",
+		"s2: set @y = 2",
+		"s1: select 1",
+		"s2: select 2",
+	}, invoked)
+	require.Empty(t, h.CheckConsistency())
+}
+
+func TestSessionConfigStmts(t *testing.T) {
+	require.Empty(t, SessionConfig{Name: "s1"}.Stmts())
+	require.Equal(t, []Stmt{{Sess: "s1", SQL: "set @x = 1"}}, SessionConfig{Name: "s1", InitSQL: []Stmt{{Sess: "s2", SQL: "set @x = 1"}}}.Stmts())
+	on := true
+	require.Equal(t, []Stmt{{Sess: "s1", SQL: "SET SESSION autocommit = 1"}}, SessionConfig{Name: "s1", AutoCommit: &on}.Stmts())
+}
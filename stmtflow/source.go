@@ -0,0 +1,40 @@
+package stmtflow
+
+import (
+	"context"
+	"io"
+)
+
+// EventSource provides events one by one, Next returns io.EOF once there are no more events.
+type EventSource interface {
+	Next(ctx context.Context) (Event, error)
+}
+
+// ChanEventSource is an event source fed by a channel, it ends when the channel is closed.
+type ChanEventSource <-chan Event
+
+func (c ChanEventSource) Next(ctx context.Context) (Event, error) {
+	select {
+	case e, ok := <-c:
+		if !ok {
+			return Event{}, io.EOF
+		}
+		return e, nil
+	case <-ctx.Done():
+		return Event{}, ctx.Err()
+	}
+}
+
+// FromSource appends events from src to the history until it's drained. Events read before an error are kept.
+func (h *History) FromSource(ctx context.Context, src EventSource) error {
+	for {
+		e, err := src.Next(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		*h = append(*h, e)
+	}
+}
@@ -0,0 +1,32 @@
+package stmtflow
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryFromSource(t *testing.T) {
+	inv, ret := newInvRet("s1", "select 1", nil)
+	ch := make(chan Event, 4)
+	ch <- inv
+	ch <- NewBlockEvent("s1")
+	ch <- NewResumeEvent("s1")
+	ch <- ret
+	close(ch)
+
+	h := History{NewBlockEvent("s0")}
+	require.NoError(t, h.FromSource(context.Background(), ChanEventSource(ch)))
+	require.Equal(t, History{NewBlockEvent("s0"), inv, NewBlockEvent("s1"), NewResumeEvent("s1"), ret}, h)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pending := make(chan Event)
+	var h2 History
+	go func() {
+		pending <- NewBlockEvent("s2")
+		cancel()
+	}()
+	require.Equal(t, context.Canceled, h2.FromSource(ctx, ChanEventSource(pending)))
+	require.Equal(t, History{NewBlockEvent("s2")}, h2)
+}
@@ -0,0 +1,83 @@
+package stmtflow
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type stableOrder struct {
+	lock       sync.Mutex
+	downstream func(Event)
+	window     time.Duration
+	now        func() time.Time
+	start      time.Time
+	buf        []Event
+	timer      *time.Timer
+	// batch identifies the buffered batch, so that the timer of a flushed batch cannot flush a later one.
+	batch int
+}
+
+// StableOrderHandler returns an event handler that buffers events arriving within window since the first buffered one
+// and passes them to downstream ordered by session name. Events buffered together are taken as happening at the same
+// time, events of a session keep their arrival order. It makes the output of concurrent sessions deterministic, since
+// real clocks hardly ever tie. If all buffered events have sequence numbers (see EventMeta.Seq), they are ordered by
+// them instead. Buffered events are flushed once the window elapses, or explicitly by calling the returned flush
+// function, which should be done after the flow ends.
+func StableOrderHandler(downstream func(Event), window time.Duration) (func(Event), func()) {
+	h := &stableOrder{downstream: downstream, window: window, now: time.Now}
+	return h.handle, h.Flush
+}
+
+func (h *stableOrder) handle(e Event) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	now := h.now()
+	if len(h.buf) > 0 && now.Sub(h.start) > h.window {
+		h.flush()
+	}
+	if len(h.buf) == 0 {
+		if h.timer != nil {
+			h.timer.Stop()
+		}
+		h.batch++
+		h.start = now
+		batch := h.batch
+		h.timer = time.AfterFunc(h.window, func() {
+			h.lock.Lock()
+			defer h.lock.Unlock()
+			if h.batch == batch {
+				h.flush()
+			}
+		})
+	}
+	h.buf = append(h.buf, e)
+}
+
+func (h *stableOrder) Flush() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.flush()
+}
+
+func (h *stableOrder) flush() {
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+	}
+	withSeq := true
+	for _, e := range h.buf {
+		withSeq = withSeq && e.Seq > 0
+	}
+	sort.SliceStable(h.buf, func(i, j int) bool {
+		x, y := h.buf[i], h.buf[j]
+		if withSeq {
+			return x.Seq < y.Seq
+		}
+		return x.Session < y.Session
+	})
+	for _, e := range h.buf {
+		h.downstream(e)
+	}
+	h.buf = h.buf[:0]
+}
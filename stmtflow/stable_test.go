@@ -0,0 +1,110 @@
+package stmtflow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStableOrderHandler(t *testing.T) {
+	i1, r1 := newInvRet("s1", "select 1", nil)
+	i2, r2 := newInvRet("s2", "select 2", nil)
+
+	var out History
+	now := time.Now()
+	h := &stableOrder{downstream: out.Collect, window: time.Hour, now: func() time.Time { return now }}
+	h.handle(i2)
+	h.handle(i1)
+	now = now.Add(time.Millisecond)
+	h.handle(r2)
+	require.Empty(t, out)
+	h.Flush()
+	require.Equal(t, History{i1, i2, r2}, out)
+	h.Flush()
+	require.Len(t, out, 3)
+
+	// events within the window are ties whenever they arrive, events of a session keep their order
+	out = nil
+	ic, rc := newInvRet("s2", "commit", nil)
+	h = &stableOrder{downstream: out.Collect, window: time.Hour, now: func() time.Time { return now }}
+	h.handle(ic)
+	now = now.Add(time.Millisecond)
+	h.handle(rc)
+	now = now.Add(time.Millisecond)
+	h.handle(NewResumeEvent("s1"))
+	h.Flush()
+	require.Equal(t, History{NewResumeEvent("s1"), ic, rc}, out)
+
+	out = nil
+	h = &stableOrder{downstream: out.Collect, window: time.Hour, now: func() time.Time { return now }}
+	h.handle(i2)
+	h.handle(i1)
+	now = now.Add(2 * time.Hour)
+	h.handle(r2)
+	h.handle(r1)
+	require.Equal(t, History{i1, i2}, out)
+	h.Flush()
+	require.Equal(t, History{i1, i2, r1, r2}, out)
+
+	out = nil
+	now = time.Now()
+	h = &stableOrder{downstream: out.Collect, window: time.Millisecond, now: func() time.Time { return now }}
+	h.handle(i2)
+	h.handle(i1)
+	require.Eventually(t, func() bool {
+		h.lock.Lock()
+		defer h.lock.Unlock()
+		return len(out) == 2
+	}, time.Second, time.Millisecond)
+	require.Equal(t, History{i1, i2}, out)
+}
+
+func TestStableOrderHandlerRealClock(t *testing.T) {
+	i1, r1 := newInvRet("s1", "select 1", nil)
+	i2, r2 := newInvRet("s2", "select 2", nil)
+	for k := 0; k < 10; k++ {
+		var out History
+		handle, flush := StableOrderHandler(out.Collect, time.Hour)
+		handle(i2)
+		time.Sleep(time.Millisecond)
+		handle(i1)
+		handle(r2)
+		time.Sleep(time.Millisecond)
+		handle(r1)
+		flush()
+		require.Equal(t, History{i1, r1, i2, r2}, out)
+	}
+}
+
+func TestStableOrderHandlerSeq(t *testing.T) {
+	i1, r1 := newInvRet("s1", "select 1", nil)
+	i1.Seq, r1.Seq = 1, 2
+
+	var out History
+	now := time.Now()
+	h := &stableOrder{downstream: out.Collect, window: time.Hour, now: func() time.Time { return now }}
+	h.handle(r1)
+	now = now.Add(time.Millisecond)
+	h.handle(i1)
+	h.Flush()
+	require.Equal(t, History{i1, r1}, out)
+
+	// sequence numbers take precedence over sessions
+	i2, r2 := newInvRet("s2", "select 2", nil)
+	i2.Seq, r2.Seq = 3, 4
+	out = nil
+	h.handle(r2)
+	h.handle(i2)
+	h.handle(r1)
+	h.handle(i1)
+	h.Flush()
+	require.Equal(t, History{i1, r1, i2, r2}, out)
+
+	// events without sequence numbers disable it for the batch
+	out = nil
+	h.handle(r2)
+	h.handle(NewResumeEvent("s1"))
+	h.Flush()
+	require.Equal(t, History{NewResumeEvent("s1"), r2}, out)
+}
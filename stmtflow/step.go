@@ -0,0 +1,88 @@
+package stmtflow
+
+import (
+	"context"
+	"sync"
+)
+
+// StepController pauses a flow (see EvalOptions.StepController) before invoking each statement until Step or Continue
+// is called, so that a flow can be stepped through interactively. Events are still reported to the callback as
+// statements execute. A nil controller never pauses, the zero value is ready to use.
+type StepController struct {
+	lock    sync.Mutex
+	pending *Stmt
+	steps   int
+	free    bool
+	notify  chan struct{}
+}
+
+func NewStepController() *StepController { return &StepController{} }
+
+// Pending returns the statement the flow is paused before, ok is false if the flow is not paused.
+func (c *StepController) Pending() (stmt Stmt, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.pending == nil {
+		return Stmt{}, false
+	}
+	return *c.pending, true
+}
+
+// Step lets the flow invoke one more statement. Steps granted while the flow is running are consumed by the following
+// statements.
+func (c *StepController) Step() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.steps++
+	c.wakeup()
+}
+
+// Continue lets the flow run without pausing until Pause is called.
+func (c *StepController) Continue() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.free = true
+	c.wakeup()
+}
+
+// Pause makes the flow pause again before the next statement, it drops steps not consumed yet.
+func (c *StepController) Pause() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.free, c.steps = false, 0
+}
+
+func (c *StepController) wakeup() {
+	if c.notify != nil {
+		close(c.notify)
+		c.notify = nil
+	}
+}
+
+func (c *StepController) wait(ctx context.Context, stmt Stmt) error {
+	if c == nil {
+		return nil
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer func() { c.pending = nil }()
+	for !c.free && c.steps == 0 {
+		c.pending = &stmt
+		if c.notify == nil {
+			c.notify = make(chan struct{})
+		}
+		notify := c.notify
+		c.lock.Unlock()
+		select {
+		case <-notify:
+			c.lock.Lock()
+		case <-ctx.Done():
+			c.lock.Lock()
+			return ctx.Err()
+		}
+	}
+	if !c.free {
+		c.steps--
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package stmtflow
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStepController(t *testing.T) {
+	db, err := sql.Open("stmtflow-fake", "")
+	require.NoError(t, err)
+	defer db.Close()
+	stmts := []Stmt{
+		{Sess: "s1", SQL: "begin"},
+		{Sess: "s2", SQL: "select 1", Flags: S_QUERY},
+		{Sess: "s1", SQL: "commit"},
+	}
+
+	var (
+		lock sync.Mutex
+		h    History
+	)
+	collected := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(h)
+	}
+	c := NewStepController()
+	pausedAt := func(stmt Stmt) func() bool {
+		return func() bool {
+			pending, ok := c.Pending()
+			return ok && pending == stmt
+		}
+	}
+	_, ok := c.Pending()
+	require.False(t, ok)
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(context.Background(), db, stmts, EvalOptions{
+			Callback: func(e Event) {
+				lock.Lock()
+				defer lock.Unlock()
+				h.Collect(e)
+			},
+			StepController: c,
+		})
+	}()
+	require.Eventually(t, pausedAt(stmts[0]), time.Second, time.Millisecond)
+	require.Zero(t, collected())
+	c.Step()
+	require.Eventually(t, pausedAt(stmts[1]), time.Second, time.Millisecond)
+	require.Equal(t, 2, collected())
+	c.Continue()
+	require.NoError(t, <-done)
+	require.Equal(t, 6, collected())
+	_, ok = c.Pending()
+	require.False(t, ok)
+
+	c = NewStepController()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { done <- Run(ctx, db, stmts, EvalOptions{StepController: c}) }()
+	require.Eventually(t, pausedAt(stmts[0]), time.Second, time.Millisecond)
+	cancel()
+	require.Equal(t, context.Canceled, <-done)
+
+	// the zero value works, and pending statements are interpolated
+	vars := NewVars()
+	vars.Set("n", "1")
+	c = &StepController{}
+	c.Step()
+	go func() {
+		done <- Run(context.Background(), db, []Stmt{{Sess: "s1", SQL: "begin"}, {Sess: "s1", SQL: "update t set v = ${n}"}},
+			EvalOptions{StepController: c, Vars: vars})
+	}()
+	require.Eventually(t, pausedAt(Stmt{Sess: "s1", SQL: "update t set v = 1"}), time.Second, time.Millisecond)
+	c.Continue()
+	require.NoError(t, <-done)
+}
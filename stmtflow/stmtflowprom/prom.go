@@ -0,0 +1,118 @@
+// Package stmtflowprom exports metrics of stmtflow events to Prometheus, it's kept apart from stmtflow so that users of
+// stmtflow don't depend on the Prometheus client.
+package stmtflowprom
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zyguan/sqlz/stmtflow"
+)
+
+type promCollectors struct {
+	invokes *prometheus.CounterVec
+	returns *prometheus.CounterVec
+	errors  *prometheus.CounterVec
+	blocks  *prometheus.CounterVec
+	latency *prometheus.HistogramVec
+}
+
+// PromHandler returns an event handler that exports statement counters and latencies to reg. Collectors are registered
+// on the first event, those already registered by an earlier handler are reused. Like prometheus.MustRegister, the
+// handler panics if they cannot be registered or a collector of another kind is registered under the same name. It's
+// safe to call the handler concurrently.
+func PromHandler(reg prometheus.Registerer) func(stmtflow.Event) {
+	var (
+		once sync.Once
+		cs   promCollectors
+	)
+	return func(e stmtflow.Event) {
+		once.Do(func() {
+			var err error
+			if cs, err = registerCollectors(reg); err != nil {
+				panic(err)
+			}
+		})
+		switch e.Kind {
+		case stmtflow.EventInvoke:
+			cs.invokes.WithLabelValues(e.Session).Inc()
+		case stmtflow.EventReturn:
+			cs.returns.WithLabelValues(e.Session).Inc()
+			ret := e.Return()
+			if ret.Err != nil {
+				code := stmtflow.WrapError(ret.Err).(*stmtflow.Error).Code
+				cs.errors.WithLabelValues(e.Session, strconv.Itoa(code)).Inc()
+			}
+			if t := ret.T; !t[0].IsZero() && !t[1].IsZero() {
+				cs.latency.WithLabelValues(e.Session).Observe(t[1].Sub(t[0]).Seconds())
+			}
+		case stmtflow.EventBlock:
+			cs.blocks.WithLabelValues(e.Session).Inc()
+		}
+	}
+}
+
+func registerCollectors(reg prometheus.Registerer) (promCollectors, error) {
+	var (
+		cs  promCollectors
+		err error
+	)
+	if cs.invokes, err = registerCounterVec(reg, prometheus.CounterOpts{
+		Namespace: "stmtflow", Name: "invokes_total", Help: "Number of statements invoked.",
+	}, "session"); err != nil {
+		return cs, err
+	}
+	if cs.returns, err = registerCounterVec(reg, prometheus.CounterOpts{
+		Namespace: "stmtflow", Name: "returns_total", Help: "Number of statements returned.",
+	}, "session"); err != nil {
+		return cs, err
+	}
+	if cs.errors, err = registerCounterVec(reg, prometheus.CounterOpts{
+		Namespace: "stmtflow", Name: "errors_total", Help: "Number of statements returned with an error.",
+	}, "session", "code"); err != nil {
+		return cs, err
+	}
+	if cs.blocks, err = registerCounterVec(reg, prometheus.CounterOpts{
+		Namespace: "stmtflow", Name: "blocks_total", Help: "Number of statements being blocked.",
+	}, "session"); err != nil {
+		return cs, err
+	}
+	c, err := registerCollector(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "stmtflow", Name: "statement_duration_seconds", Help: "Latency of statements.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 16),
+	}, []string{"session"}))
+	if err != nil {
+		return cs, err
+	}
+	var ok bool
+	if cs.latency, ok = c.(*prometheus.HistogramVec); !ok {
+		return cs, fmt.Errorf("unexpected collector of stmtflow_statement_duration_seconds: %T", c)
+	}
+	return cs, nil
+}
+
+func registerCounterVec(reg prometheus.Registerer, opts prometheus.CounterOpts, labels ...string) (*prometheus.CounterVec, error) {
+	c, err := registerCollector(reg, prometheus.NewCounterVec(opts, labels))
+	if err != nil {
+		return nil, err
+	}
+	vec, ok := c.(*prometheus.CounterVec)
+	if !ok {
+		return nil, fmt.Errorf("unexpected collector of %s_%s: %T", opts.Namespace, opts.Name, c)
+	}
+	return vec, nil
+}
+
+func registerCollector(reg prometheus.Registerer, c prometheus.Collector) (prometheus.Collector, error) {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			return are.ExistingCollector, nil
+		}
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,83 @@
+package stmtflowprom
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"github.com/zyguan/sqlz/stmtflow"
+)
+
+func TestPromHandler(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	h1, h2 := PromHandler(reg), PromHandler(reg)
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	require.Empty(t, mfs)
+
+	now := time.Now()
+	stmt := stmtflow.Stmt{Sess: "s1", SQL: "update t set v = 1"}
+	events := []stmtflow.Event{
+		stmtflow.NewInvokeEvent("s1", stmtflow.Invoke{Stmt: stmt}),
+		stmtflow.NewBlockEvent("s1"),
+		stmtflow.NewResumeEvent("s1"),
+		stmtflow.NewReturnEvent("s1", stmtflow.Return{Stmt: stmt, Err: &mysql.MySQLError{Number: 1213, Message: "deadlock"}, T: [2]time.Time{now, now.Add(time.Second)}}),
+		stmtflow.NewReturnEvent("s2", stmtflow.Return{Stmt: stmtflow.Stmt{Sess: "s2"}, Err: errors.New("oops"), T: [2]time.Time{now, now.Add(time.Millisecond)}}),
+	}
+	var wg sync.WaitGroup
+	for _, h := range []func(stmtflow.Event){h1, h2} {
+		wg.Add(1)
+		go func(h func(stmtflow.Event)) {
+			defer wg.Done()
+			for _, e := range events {
+				h(e)
+			}
+		}(h)
+	}
+	wg.Wait()
+
+	mfs, err = reg.Gather()
+	require.NoError(t, err)
+	require.Len(t, mfs, 5)
+
+	values := make(map[string]float64)
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			key := mf.GetName()
+			for _, l := range m.GetLabel() {
+				key += "," + l.GetName() + "=" + l.GetValue()
+			}
+			if h := m.GetHistogram(); h != nil {
+				values[key] = float64(h.GetSampleCount())
+			} else {
+				values[key] = m.GetCounter().GetValue()
+			}
+		}
+	}
+	require.Equal(t, map[string]float64{
+		"stmtflow_invokes_total,session=s1":              2,
+		"stmtflow_blocks_total,session=s1":               2,
+		"stmtflow_returns_total,session=s1":              2,
+		"stmtflow_returns_total,session=s2":              2,
+		"stmtflow_errors_total,code=1213,session=s1":     2,
+		"stmtflow_errors_total,code=-1,session=s2":       2,
+		"stmtflow_statement_duration_seconds,session=s1": 2,
+		"stmtflow_statement_duration_seconds,session=s2": 2,
+	}, values)
+}
+
+func TestPromHandlerConflict(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: "stmtflow", Name: "blocks_total"}, []string{"session"}))
+	h := PromHandler(reg)
+	require.Panics(t, func() { h(stmtflow.NewBlockEvent("s1")) })
+
+	reg = prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: "stmtflow", Name: "returns_total"}, []string{"sess"}))
+	h = PromHandler(reg)
+	require.Panics(t, func() { h(stmtflow.NewBlockEvent("s1")) })
+}
@@ -0,0 +1,70 @@
+// Package stmtflowtest provides utilities for testing code built on top of stmtflow without a database.
+package stmtflowtest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/zyguan/sqlz/stmtflow"
+)
+
+// MockPlayer plays a scripted history to an event handler as if the events were produced by stmtflow.Eval, so that
+// handlers (formatting, metrics, etc.) can be tested deterministically.
+type MockPlayer struct {
+	// Interval is the time to wait before emitting each event, zero means no wait.
+	Interval time.Duration
+	// Pause is called before emitting the i-th event, it can be used to step through events (e.g. by receiving from a
+	// channel) or to inspect the handler in the middle of a flow.
+	Pause func(i int, e stmtflow.Event)
+
+	script stmtflow.History
+}
+
+func NewMockPlayer(script stmtflow.History) *MockPlayer {
+	return &MockPlayer{script: script}
+}
+
+// Play emits events of the script to handler in order. The script is checked before playing: a block must happen
+// during a statement and be resumed before the blocked statement returns, otherwise Play returns an error without
+// emitting anything. Play stops with ctx.Err() once ctx is done.
+func (p *MockPlayer) Play(ctx context.Context, handler func(stmtflow.Event)) error {
+	var problems []string
+	for _, issue := range p.script.CheckConsistency() {
+		switch issue.Kind {
+		case stmtflow.IssueUnresumedBlock, stmtflow.IssueUnexpectedBlock, stmtflow.IssueUnexpectedResume:
+			problems = append(problems, issue.String())
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New("bad script: " + strings.Join(problems, "; "))
+	}
+	for i, e := range p.script {
+		if err := p.wait(ctx); err != nil {
+			return err
+		}
+		if p.Pause != nil {
+			p.Pause(i, e)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		handler(e)
+	}
+	return nil
+}
+
+func (p *MockPlayer) wait(ctx context.Context) error {
+	if p.Interval <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(p.Interval)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
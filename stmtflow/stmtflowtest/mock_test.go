@@ -0,0 +1,57 @@
+package stmtflowtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zyguan/sqlz/stmtflow"
+)
+
+func invRet(s string, sql string) (stmtflow.Event, stmtflow.Event) {
+	stmt := stmtflow.Stmt{Sess: s, SQL: sql}
+	return stmtflow.NewInvokeEvent(s, stmtflow.Invoke{Stmt: stmt}), stmtflow.NewReturnEvent(s, stmtflow.Return{Stmt: stmt})
+}
+
+func TestMockPlayer(t *testing.T) {
+	i1, r1 := invRet("s1", "commit")
+	i2, r2 := invRet("s2", "update t set v = 1")
+	script := stmtflow.History{i2, stmtflow.NewBlockEvent("s2"), i1, r1, stmtflow.NewResumeEvent("s2"), r2}
+
+	var out stmtflow.History
+	require.NoError(t, NewMockPlayer(script).Play(context.Background(), out.Collect))
+	require.Equal(t, script, out)
+
+	out = nil
+	paused, step := make(chan int), make(chan struct{})
+	p := NewMockPlayer(script)
+	p.Pause = func(i int, e stmtflow.Event) {
+		paused <- i
+		<-step
+	}
+	done := make(chan error, 1)
+	go func() { done <- p.Play(context.Background(), out.Collect) }()
+	for i := range script {
+		require.Equal(t, i, <-paused)
+		require.Equal(t, script[:i].Len(), out.Len())
+		step <- struct{}{}
+	}
+	require.NoError(t, <-done)
+	require.Equal(t, script, out)
+
+	out = nil
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	p = NewMockPlayer(script)
+	p.Interval = 30 * time.Millisecond
+	require.Equal(t, context.DeadlineExceeded, p.Play(ctx, out.Collect))
+	require.Len(t, out, 1)
+
+	out = nil
+	bad := stmtflow.History{i2, r2, stmtflow.NewResumeEvent("s2")}
+	err := NewMockPlayer(bad).Play(context.Background(), out.Collect)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), string(stmtflow.IssueUnexpectedResume))
+	require.Empty(t, out)
+}
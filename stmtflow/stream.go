@@ -0,0 +1,86 @@
+package stmtflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+const streamBufferSize = 1024
+
+type eventStream struct {
+	lock sync.Mutex
+	subs map[chan []byte]struct{}
+}
+
+// HTTPStreamHandler returns an event handler together with an http.Handler that serves the events it receives as
+// Server-Sent Events, one JSON encoded event per message. Clients receive events from the time they connect on. A
+// client that falls too far behind is disconnected rather than slowing down the flow.
+func HTTPStreamHandler() (func(Event), http.Handler) {
+	s := &eventStream{subs: make(map[chan []byte]struct{})}
+	return s.publish, s
+}
+
+func (s *eventStream) publish(e Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for ch := range s.subs {
+		select {
+		case ch <- data:
+		default:
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (s *eventStream) subscribe() chan []byte {
+	ch := make(chan []byte, streamBufferSize)
+	s.lock.Lock()
+	s.subs[ch] = struct{}{}
+	s.lock.Unlock()
+	return ch
+}
+
+func (s *eventStream) unsubscribe(ch chan []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.subs[ch]; ok {
+		delete(s.subs, ch)
+		close(ch)
+	}
+}
+
+func (s *eventStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ch := s.subscribe()
+	defer s.unsubscribe(ch)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case data, ok := <-ch:
+			if !ok {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
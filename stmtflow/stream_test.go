@@ -0,0 +1,52 @@
+package stmtflow
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPStreamHandler(t *testing.T) {
+	handler, h := HTTPStreamHandler()
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	connect := func() (*bufio.Reader, func()) {
+		resp, err := http.Get(srv.URL)
+		require.NoError(t, err)
+		require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		return bufio.NewReader(resp.Body), func() { resp.Body.Close() }
+	}
+	next := func(r *bufio.Reader) Event {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(line, "data: "))
+		blank, err := r.ReadString('\n')
+		require.NoError(t, err)
+		require.Equal(t, "\n", blank)
+		var e Event
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e))
+		return e
+	}
+
+	handler(NewBlockEvent("s0"))
+	c1, close1 := connect()
+	defer close1()
+	handler(NewBlockEvent("s1"))
+	c2, close2 := connect()
+	defer close2()
+	inv, _ := newInvRet("s2", "select 1", nil)
+	handler(inv)
+
+	require.Equal(t, NewBlockEvent("s1"), next(c1))
+	e := next(c1)
+	require.Equal(t, "select 1", e.Invoke().SQL)
+	e = next(c2)
+	ok, msg := e.EqualTo(inv)
+	require.True(t, ok, msg)
+}
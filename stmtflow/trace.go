@@ -0,0 +1,25 @@
+package stmtflow
+
+import "context"
+
+type traceKey struct{}
+
+type traceMeta struct {
+	traceID string
+	spanID  string
+}
+
+// WithTrace returns a context carrying trace metadata, which is then recorded in events created by
+// NewInvokeEventWithContext, as well as all events of flows evaluated with the context.
+func WithTrace(ctx context.Context, traceID string, spanID string) context.Context {
+	return context.WithValue(ctx, traceKey{}, traceMeta{traceID, spanID})
+}
+
+// TraceFromContext returns the trace metadata set by WithTrace, they are empty if there is none.
+func TraceFromContext(ctx context.Context) (traceID string, spanID string) {
+	if ctx == nil {
+		return "", ""
+	}
+	meta, _ := ctx.Value(traceKey{}).(traceMeta)
+	return meta.traceID, meta.spanID
+}
@@ -0,0 +1,49 @@
+package stmtflow
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewInvokeEventWithContext(t *testing.T) {
+	inv := Invoke{Stmt{Sess: "s1", SQL: "select 1"}}
+	plain := NewInvokeEvent("s1", inv)
+	require.Empty(t, plain.TraceID)
+	require.Empty(t, plain.SpanID)
+
+	ctx := WithTrace(context.Background(), "t-1", "s-1")
+	traced := NewInvokeEventWithContext(ctx, "s1", inv)
+	require.Equal(t, "t-1", traced.TraceID)
+	require.Equal(t, "s-1", traced.SpanID)
+	ok, _ := plain.EqualTo(traced)
+	require.True(t, ok)
+
+	js, err := json.Marshal(traced)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"kind":"Invoke","session":"s1","trace_id":"t-1","span_id":"s-1","stmt":{"s":"s1","q":"select 1"},"t":null}`, string(js))
+	var loaded Event
+	require.NoError(t, json.Unmarshal(js, &loaded))
+	require.Equal(t, traced.EventMeta, loaded.EventMeta)
+	js, err = json.Marshal(plain)
+	require.NoError(t, err)
+	require.NotContains(t, string(js), "trace_id")
+}
+
+func TestEvalWithTrace(t *testing.T) {
+	db, err := sql.Open("stmtflow-fake", "")
+	require.NoError(t, err)
+	defer db.Close()
+	stmts := []Stmt{{Sess: "s1", SQL: "select 1", Flags: S_QUERY}, {Sess: "s2", SQL: "fail"}}
+	var h History
+	ctx := WithTrace(context.Background(), "t-1", "s-1")
+	require.NoError(t, Run(ctx, db, stmts, EvalOptions{Callback: h.Collect}))
+	require.Len(t, h, 4)
+	for _, e := range h {
+		require.Equal(t, "t-1", e.TraceID)
+		require.Equal(t, "s-1", e.SpanID)
+	}
+}
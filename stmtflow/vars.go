@@ -0,0 +1,66 @@
+package stmtflow
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+var varRef = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// Vars stores values captured from returns of a flow (see Stmt.Capture and EvalOptions.Vars). Statements reference
+// them as ${name}, which is replaced with the value as it is, so string values need to be quoted by the statement.
+type Vars struct {
+	lock sync.RWMutex
+	vals map[string]string
+}
+
+func NewVars() *Vars { return &Vars{vals: make(map[string]string)} }
+
+func (v *Vars) Get(name string) (string, bool) {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	val, ok := v.vals[name]
+	return val, ok
+}
+
+func (v *Vars) Set(name string, val string) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.vals[name] = val
+}
+
+// Interpolate replaces references in sql with values of variables, it fails if any of them is undefined.
+func (v *Vars) Interpolate(sql string) (string, error) {
+	var err error
+	out := varRef.ReplaceAllStringFunc(sql, func(ref string) string {
+		name := varRef.FindStringSubmatch(ref)[1]
+		val, ok := v.Get(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("undefined variable: %s", name)
+		}
+		return val
+	})
+	if err != nil {
+		return sql, err
+	}
+	return out, nil
+}
+
+// capture stores the value captured by the statement of ret, that is, the first cell of a query result or the last
+// insert id of an exec result. Nothing is captured if the value is NULL or missing.
+func (v *Vars) capture(ret Return) {
+	if v == nil || len(ret.Stmt.Capture) == 0 || ret.Err != nil || ret.Res == nil {
+		return
+	}
+	if ret.Res.IsExecResult() {
+		if id, ok := ret.Res.LastInsertId(); ok {
+			v.Set(ret.Stmt.Capture, strconv.FormatInt(id, 10))
+		}
+		return
+	}
+	if val, ok := ret.Res.RawValue(0, 0); ok && val != nil {
+		v.Set(ret.Stmt.Capture, string(val))
+	}
+}
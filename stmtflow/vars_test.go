@@ -0,0 +1,22 @@
+package stmtflow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVarsInterpolate(t *testing.T) {
+	vars := NewVars()
+	vars.Set("id", "42")
+	vars.Set("name", "foo")
+	sql, err := vars.Interpolate("update t set name = '${name}' where id = ${id} or id = ${id}+1")
+	require.NoError(t, err)
+	require.Equal(t, "update t set name = 'foo' where id = 42 or id = 42+1", sql)
+	sql, err = vars.Interpolate("select '$id', '${}', '${ id }'")
+	require.NoError(t, err)
+	require.Equal(t, "select '$id', '${}', '${ id }'", sql)
+	sql, err = vars.Interpolate("select ${id}, ${x}")
+	require.EqualError(t, err, "undefined variable: x")
+	require.Equal(t, "select ${id}, ${x}", sql)
+}